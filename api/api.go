@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage"
+)
+
+// Store is what the API reads from.
+type Store interface {
+	storage.PropertyStore
+	storage.OwnerStore
+}
+
+// Handler serves the /v1/api endpoints backed by a Store. Responses are
+// JSON, with the result under "data".
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+// Property serves one property with its children.
+func (h *Handler) Property(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "property id must be a number", http.StatusBadRequest)
+		return
+	}
+	p, err := h.store.Property(r.Context(), int32(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "property not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, p)
+}
+
+// Properties serves the properties matching the neighborhood, street,
+// address and occupancy query parameters in id order, without their
+// children. limit and offset page through them, or after to carry on past
+// a property id.
+func (h *Handler) Properties(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := storage.PropertyFilter{
+		Neighborhood: q.Get("neighborhood"),
+		Street:       q.Get("street"),
+		Address:      q.Get("address"),
+		Occupancy:    occupancy.Class(q.Get("occupancy")),
+	}
+	if f.Occupancy != "" && !f.Occupancy.Valid() {
+		http.Error(w, fmt.Sprintf("occupancy must be one of %v", occupancy.Classes), http.StatusBadRequest)
+		return
+	}
+	var ok bool
+	if f.Limit, ok = intParam(w, r, "limit"); !ok {
+		return
+	}
+	if f.Offset, ok = intParam(w, r, "offset"); !ok {
+		return
+	}
+	after, ok := intParam(w, r, "after")
+	if !ok {
+		return
+	}
+	f.AfterID = int32(after)
+	props, err := h.store.List(r.Context(), f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, props)
+}
+
+// Owner serves an owner's portfolio: how many properties it holds, their
+// total appraised value and where they are.
+func (h *Handler) Owner(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		http.Error(w, "owner id must be a number", http.StatusBadRequest)
+		return
+	}
+	p, err := h.store.Portfolio(r.Context(), int32(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "owner not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, p)
+}
+
+// TopOwners serves the owners with the most properties in the neighborhood
+// query parameter, at most limit of them.
+func (h *Handler) TopOwners(w http.ResponseWriter, r *http.Request) {
+	neighborhood := r.URL.Query().Get("neighborhood")
+	if neighborhood == "" {
+		http.Error(w, "neighborhood is required", http.StatusBadRequest)
+		return
+	}
+	limit, ok := intParam(w, r, "limit")
+	if !ok {
+		return
+	}
+	owners, err := h.store.TopOwners(r.Context(), neighborhood, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, owners)
+}
+
+// intParam reads an optional non-negative query parameter, answering the
+// request itself when it's malformed.
+func intParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		http.Error(w, name+" must be a non-negative number", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": v})
+}
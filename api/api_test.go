@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+func testRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	ms := storage.NewMemory()
+	if err := ms.Save(context.Background(), []tax.PropertyRecord{
+		{PropertyID: "1", OwnerID: "77", OwnerName: "DOE, JOHN", Neighborhood: "Downtown", RollValue: []tax.RollValue{{Year: "2021", Appraised: "100"}},
+			Address: "1 MAIN ST\nNEW BRAUNFELS, TX 78130", OwnerMailingAddress: "1 MAIN ST\nNEW BRAUNFELS, TX 78130"},
+		{PropertyID: "2", OwnerID: "77", OwnerName: "DOE, JOHN", Neighborhood: "Oak Hills", RollValue: []tax.RollValue{{Year: "2021", Appraised: "250"}},
+			Address: "2 OAK DR\nNEW BRAUNFELS, TX 78130", OwnerMailingAddress: "1 MAIN ST\nNEW BRAUNFELS, TX 78130"},
+		{PropertyID: "3", OwnerID: "88", OwnerName: "ROE, JANE", Neighborhood: "Downtown"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(ms)
+	r := mux.NewRouter()
+	r.HandleFunc("/properties", h.Properties)
+	r.HandleFunc("/owners", h.TopOwners)
+	r.HandleFunc("/owners/{id}", h.Owner)
+	return r
+}
+
+func get(t *testing.T, r *mux.Router, url string, data interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+	if rec.Code == 200 {
+		body := struct{ Data interface{} }{data}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s: %v: %s", url, err, rec.Body)
+		}
+	}
+	return rec.Code
+}
+
+func Test_Owner(t *testing.T) {
+	r := testRouter(t)
+	var p storage.Portfolio
+	if code := get(t, r, "/owners/77", &p); code != 200 {
+		t.Fatalf("GET /owners/77 = %d", code)
+	}
+	if p.Name != "DOE, JOHN" || p.Properties != 2 || p.TotalAppraised != 350 || len(p.Holdings) != 2 {
+		t.Errorf("GET /owners/77 = %+v", p)
+	}
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/owners/99", 404},
+		{"/owners/abc", 400},
+		{"/owners", 400},
+		{"/owners?neighborhood=Downtown&limit=x", 400},
+	}
+	for _, tt := range tests {
+		if code := get(t, r, tt.url, nil); code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.url, code, tt.want)
+		}
+	}
+}
+
+func Test_TopOwners(t *testing.T) {
+	r := testRouter(t)
+	tests := []struct {
+		url  string
+		want []int32
+	}{
+		{"/owners?neighborhood=downtown", []int32{77, 88}},
+		{"/owners?neighborhood=downtown&limit=1", []int32{77}},
+		{"/owners?neighborhood=Oak+Hills", []int32{77}},
+		{"/owners?neighborhood=Nowhere", nil},
+	}
+	for _, tt := range tests {
+		var owners []storage.OwnerHoldings
+		if code := get(t, r, tt.url, &owners); code != 200 {
+			t.Fatalf("GET %s = %d", tt.url, code)
+		}
+		var got []int32
+		for _, o := range owners {
+			got = append(got, o.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("GET %s = %v, want %v", tt.url, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("GET %s = %v, want %v", tt.url, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func Test_Properties(t *testing.T) {
+	r := testRouter(t)
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"/properties", []string{"1", "2", "3"}},
+		{"/properties?occupancy=owner-occupied", []string{"1"}},
+		{"/properties?occupancy=local-absentee", []string{"2"}},
+		{"/properties?occupancy=unknown&neighborhood=downtown", []string{"3"}},
+		{"/properties?occupancy=out-of-state", nil},
+		{"/properties?limit=1&after=1", []string{"2"}},
+	}
+	for _, tt := range tests {
+		var props []storage.Property
+		if code := get(t, r, tt.url, &props); code != 200 {
+			t.Fatalf("GET %s = %d", tt.url, code)
+		}
+		var got []string
+		for _, p := range props {
+			got = append(got, p.PropertyID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET %s = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+	for _, url := range []string{"/properties?occupancy=rented", "/properties?offset=-1"} {
+		if code := get(t, r, url, nil); code != 400 {
+			t.Errorf("GET %s = %d, want 400", url, code)
+		}
+	}
+}
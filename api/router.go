@@ -0,0 +1,25 @@
+package api
+
+import (
+	"database/sql"
+
+	"github.com/gorilla/mux"
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/quality"
+	"github.com/jason-costello/taxcollector/storage"
+)
+
+// NewRouter serves the /v1/api endpoints from db, counting every request in
+// the HTTP metrics.
+func NewRouter(db *sql.DB) *mux.Router {
+	h := NewHandler(storage.NewSQL(db))
+	r := mux.NewRouter()
+	r.Use(metrics.Middleware)
+	v1 := r.PathPrefix("/v1/api").Subrouter()
+	v1.HandleFunc("/property/{id}", h.Property)
+	v1.HandleFunc("/properties", h.Properties)
+	v1.HandleFunc("/owners", h.TopOwners)
+	v1.HandleFunc("/owners/{id}", h.Owner)
+	v1.Handle("/quality", quality.Handler(db))
+	return r
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+func Test_NewRouter(t *testing.T) {
+	db := migratetest.Open(t)
+	if err := storage.NewSQL(db).Save(context.Background(), []tax.PropertyRecord{
+		{PropertyID: "1", OwnerID: "77", OwnerName: "DOE, JOHN", Neighborhood: "Downtown", RollValue: []tax.RollValue{{Year: "2021", Appraised: "100"}},
+			Address: "1 MAIN ST\nNEW BRAUNFELS, TX 78130", OwnerMailingAddress: "1 MAIN ST\nNEW BRAUNFELS, TX 78130"},
+		{PropertyID: "2", OwnerID: "77", OwnerName: "DOE, JOHN", Neighborhood: "Oak Hills", RollValue: []tax.RollValue{{Year: "2021", Appraised: "250"}},
+			Address: "2 OAK DR\nNEW BRAUNFELS, TX 78130", OwnerMailingAddress: "1 MAIN ST\nNEW BRAUNFELS, TX 78130"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(db)
+
+	var p storage.Property
+	if code := get(t, r, "/v1/api/property/2", &p); code != 200 || p.Neighborhood != "Oak Hills" {
+		t.Errorf("GET /v1/api/property/2 = %d %+v", code, p)
+	}
+	var props []storage.Property
+	if code := get(t, r, "/v1/api/properties?occupancy=local-absentee", &props); code != 200 || len(props) != 1 || props[0].PropertyID != "2" {
+		t.Errorf("GET /v1/api/properties?occupancy=local-absentee = %d %+v", code, props)
+	}
+	var top []storage.OwnerHoldings
+	if code := get(t, r, "/v1/api/owners?neighborhood=downtown", &top); code != 200 || len(top) != 1 || top[0].ID != 77 {
+		t.Errorf("GET /v1/api/owners?neighborhood=downtown = %d %+v", code, top)
+	}
+	var portfolio storage.Portfolio
+	if code := get(t, r, "/v1/api/owners/77", &portfolio); code != 200 || portfolio.TotalAppraised != 350 {
+		t.Errorf("GET /v1/api/owners/77 = %d %+v", code, portfolio)
+	}
+	// The quality report isn't wrapped in "data".
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/api/quality?format=text", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "roll values") {
+		t.Errorf("GET /v1/api/quality = %d %s", rec.Code, rec.Body)
+	}
+
+	for url, want := range map[string]int{
+		"/v1/api/property/99": 404,
+		"/v1/api/owners/99":   404,
+		"/v1/api/nothing":     404,
+	} {
+		if code := get(t, r, url, nil); code != want {
+			t.Errorf("GET %s = %d, want %d", url, code, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and never touches the network.
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to the real transport and appends every
+	// request/response pair to the cassette.
+	ModeRecord
+)
+
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches request")
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+func Load(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Transport is an http.RoundTripper that either records traffic into a
+// cassette or replays a previously recorded one. Hand it to the scraper as
+// the Transport of the *http.Client passed to scraper.NewScraper.
+type Transport struct {
+	mode     Mode
+	path     string
+	next     http.RoundTripper
+	mu       sync.Mutex
+	cassette *Cassette
+	played   []bool
+}
+
+// NewRecorder returns a Transport that sends requests through next (or
+// http.DefaultTransport when nil) and records them. Call Save to write the
+// cassette to path.
+func NewRecorder(path string, next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		mode:     ModeRecord,
+		path:     path,
+		next:     next,
+		cassette: &Cassette{},
+	}
+}
+
+// NewReplayer loads the cassette at path and serves its responses.
+func NewReplayer(path string) (*Transport, error) {
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromCassette(c), nil
+}
+
+// FromCassette replays an in-memory cassette, which is handy for tests that
+// build their fixtures programmatically.
+func FromCassette(c *Cassette) *Transport {
+	return &Transport{
+		mode:     ModeReplay,
+		cassette: c,
+		played:   make([]bool, len(c.Interactions)),
+	}
+}
+
+func (t *Transport) Cassette() *Cassette {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cassette
+}
+
+func (t *Transport) Save() error {
+	if t.path == "" {
+		return errors.New("cassette: no path set")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cassette.Save(t.path)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == ModeRecord {
+		return t.record(req)
+	}
+	return t.replay(req)
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   reqBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header.Clone(),
+			Body:       respBody,
+		},
+	})
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay matches on method and URL. Interactions are handed out in recorded
+// order; once every match has been played the last one is reused, so a
+// cassette with a single warm-up response serves any number of jobs.
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := -1
+	for i, in := range t.cassette.Interactions {
+		if in.Request.Method != req.Method || in.Request.URL != req.URL.String() {
+			continue
+		}
+		last = i
+		if !t.played[i] {
+			t.played[i] = true
+			return in.Response.toHTTP(req), nil
+		}
+	}
+	if last >= 0 {
+		return t.cassette.Interactions[last].Response.toHTTP(req), nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	status := r.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,75 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RecordAndReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html>%s call %d</html>", r.URL.Path, calls)
+	}))
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec := NewRecorder(path, nil)
+	hc := &http.Client{Transport: rec}
+
+	for _, p := range []string{"/one", "/two", "/one"} {
+		resp, err := hc.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hc = &http.Client{Transport: rep}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/one", "<html>/one call 1</html>"},
+		{"/one", "<html>/one call 3</html>"},
+		{"/two", "<html>/two call 2</html>"},
+		// every /one has been played, so the last one is reused
+		{"/one", "<html>/one call 3</html>"},
+	}
+	for _, tt := range tests {
+		resp, err := hc.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("replay %s got = %q, want %q", tt.path, b, tt.want)
+		}
+		if resp.Header.Get("Content-Type") != "text/html" {
+			t.Errorf("replay %s lost Content-Type header", tt.path)
+		}
+	}
+
+	_, err = hc.Get(srv.URL + "/missing")
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("unrecorded request error = %v, want %v", err, ErrNoInteraction)
+	}
+}
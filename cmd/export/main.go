@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/export"
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `usage: export [flags]
+
+Streams properties and their children out of the database. csv and parquet
+write one file per table into the -out directory; jsonl and geojson write
+the -out file, nesting children unless -flat is set.
+`
+
+func main() {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage+"\nflags:\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "jsonl", "output format: "+strings.Join(export.Formats, ", "))
+	out := fs.String("out", "", "output file for jsonl and geojson, output directory for csv and parquet")
+	flat := fs.Bool("flat", false, "jsonl: one table row per line; geojson: leave children out")
+	neighborhood := fs.String("neighborhood", "", "only properties in this neighborhood")
+	street := fs.String("street", "", "only properties on this street")
+	bbox := fs.String("bbox", "", "only properties inside minLon,minLat,maxLon,maxLat")
+	since := fs.String("updated-since", "", "only properties scraped since this date or RFC 3339 time")
+	occ := fs.String("occupancy", "", "only properties of this occupancy class: "+fmt.Sprint(occupancy.Classes))
+	limit := fs.Int("limit", 0, "export at most this many properties (0 for all)")
+	pageSize := fs.Int("page-size", 500, "properties loaded per query")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f := storage.PropertyFilter{Neighborhood: *neighborhood, Street: *street, Occupancy: occupancy.Class(*occ), Limit: *limit}
+	if f.Occupancy != "" && !f.Occupancy.Valid() {
+		log.Fatalf("unknown occupancy %q, want one of %v", *occ, occupancy.Classes)
+	}
+	if *bbox != "" {
+		if f.Within, err = export.ParseBoundingBox(*bbox); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *since != "" {
+		if f.ScrapedSince, err = export.ParseSince(*since); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := migrate.Check(ctx, db, cfg.DB.Driver); err != nil {
+		log.Fatal(err)
+	}
+
+	w, err := export.Open(*format, *out, *flat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	n, err := export.Run(ctx, storage.NewSQL(db), f, *pageSize, w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Fatalf("exported %d properties: %v", n, err)
+	}
+	log.Printf("exported %d properties to %s", n, *out)
+}
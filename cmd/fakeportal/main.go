@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jason-costello/taxcollector/fakeportal"
+)
+
+func main() {
+	addr := flag.String("addr", ":8056", "listen address")
+	fixtures := flag.String("fixtures", "test_data", "directory of <prop_id>.html detail pages")
+	cid := flag.String("cid", "56", "county client id to answer to")
+	delay := flag.Duration("delay", 0, "how long timeout faults stall a request (default 1m)")
+	sessionRequests := flag.Int("session-requests", 0, "expire sessions after this many detail requests (0 = never)")
+	faults := flag.String("faults", "", "random fault rates, e.g. 403=0.05,429=0.1,timeout=0.01,truncated=0.02,expired=0.02")
+	robotsFile := flag.String("robots", "", "file to serve as /robots.txt (default none)")
+	flag.Parse()
+
+	rates, err := parseFaultRates(*faults)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var robots []byte
+	if *robotsFile != "" {
+		if robots, err = os.ReadFile(*robotsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	p := fakeportal.New(fakeportal.Options{
+		FixturesDir:     *fixtures,
+		ClientID:        *cid,
+		Delay:           *delay,
+		SessionRequests: *sessionRequests,
+		FaultRates:      rates,
+		Robots:          string(robots),
+	})
+
+	log.Printf("fake portal serving %s on %s (cid=%s)", *fixtures, *addr, *cid)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
+
+func parseFaultRates(s string) (map[fakeportal.Fault]float64, error) {
+	rates := make(map[fakeportal.Fault]float64)
+	if s == "" {
+		return rates, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid fault rate %q, want name=rate", part)
+		}
+		f, err := fakeportal.ParseFault(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		rate, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		rates[f] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `usage: migrate [flags] <command>
+
+commands:
+  up        apply every pending migration; a database set up from the old
+            schema.sql is taken to be at version 1
+  down [n]  roll back the newest n migrations (default 1)
+  status    list migrations and whether the database has them
+  cleanup [-dry-run]
+            remove orphaned child rows and duplicate roll values and land,
+            which keep migration 6 from applying
+`
+
+func main() {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage+"\nflags:\n")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	m, err := migrate.New(db, cfg.DB.Driver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch cmd := fs.Arg(0); cmd {
+	case "up":
+		ran, err := m.Up(ctx)
+		for _, mig := range ran {
+			log.Printf("applied %d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(ran) == 0 {
+			log.Printf("already at version %d", m.Latest())
+		}
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			if steps, err = strconv.Atoi(fs.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("invalid number of migrations %q", fs.Arg(1))
+			}
+		}
+		ran, err := m.Down(ctx, steps)
+		for _, mig := range ran {
+			log.Printf("rolled back %d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, st := range list {
+			applied := "pending"
+			switch {
+			case st.Applied && st.Up == "":
+				applied = st.AppliedAt.Format(time.RFC3339) + " (unknown to this build)"
+			case st.Applied:
+				applied = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		tw.Flush()
+	case "cleanup":
+		cleanup(ctx, db, fs.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", cmd)
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// cleanup removes, or with -dry-run only reports, the child rows the foreign
+// and natural keys of migration 6 reject, in a single transaction.
+func cleanup(ctx context.Context, db *sql.DB, args []string) {
+	fs := flag.NewFlagSet("cleanup", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be removed")
+	fs.Parse(args)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+	counts, err := pgdb.NewFor(db).InTx(tx).Cleanup(ctx, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !*dryRun {
+		if err := tx.Commit(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "TABLE\tPROBLEM\tROWS\n")
+	var total int64
+	for _, c := range counts {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", c.Table, c.Problem, c.Rows)
+		total += c.Rows
+	}
+	tw.Flush()
+	log.Printf("%s %d rows", verb, total)
+}
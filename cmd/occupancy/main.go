@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `usage: occupancy [flags]
+
+Classifies every stored property as owner-occupied, local absentee,
+out-of-county or out-of-state owned by comparing its address with the
+owner's mailing address, and prints how many fall in each class. Saving a
+property classifies it against the ZIP codes of the county seen so far, so
+properties saved before the county was known need classifying again. scrape
+does that itself after scraping into the database; run this after anything
+else that saves properties, or to check the counts.
+`
+
+func main() {
+	fs := flag.NewFlagSet("occupancy", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage+"\nflags:\n")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := migrate.Check(ctx, db, cfg.DB.Driver); err != nil {
+		log.Fatal(err)
+	}
+
+	counts, err := storage.NewSQL(db).Reclassify(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "OCCUPANCY\tPROPERTIES\t\n")
+	for _, c := range occupancy.Classes {
+		fmt.Fprintf(tw, "%s\t%d\t\n", c, counts[c])
+	}
+	tw.Flush()
+}
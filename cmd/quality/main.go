@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/quality"
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usage = `usage: quality [flags]
+
+Reports how complete each stored field is, counts rows with suspicious
+values and summarises numeric columns. Keep the JSON output to compare runs
+over time.
+`
+
+func main() {
+	fs := flag.NewFlagSet("quality", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage+"\nflags:\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output format: text or json")
+	out := fs.String("out", "", "output file (default stdout)")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown format %q, want text or json", *format)
+	}
+
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := migrate.Check(ctx, db, cfg.DB.Driver); err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := quality.Generate(ctx, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		err = r.WriteJSON(w)
+	} else {
+		err = r.WriteText(w)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/storage/migrate"
+)
+
+// runGet fetches one property and prints it as JSON:
+//
+//	scrape get [-year 2021] [-html page.html] [-persist] <property-id>
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	year := fs.Int("year", 0, "tax year to fetch (default the portal's current year)")
+	htmlFile := fs.String("html", "", "also save the raw detail page to this file")
+	persist := fs.Bool("persist", false, "store the record in the database like a normal scrape")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: scrape get [flags] <property-id>\n")
+		fs.PrintDefaults()
+	}
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	propertyID := fs.Arg(0)
+	if *persist && *year != 0 {
+		return errors.New("-persist can't be combined with -year: properties are stored by id, so an old year would replace the current record")
+	}
+
+	// The lookup needs the database to persist or to take a proxy from the
+	// proxies table; with -proxy-source static or direct it runs without one.
+	var db *sql.DB
+	if *persist || cfg.Proxies.Source == "db" {
+		db, err = cfg.DB.Open()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if err := migrate.Check(context.Background(), db, cfg.DB.Driver); err != nil {
+			return err
+		}
+	}
+	s, closeScraper, err := newScraper(cfg, db)
+	if err != nil {
+		return err
+	}
+	defer closeScraper()
+
+	// Keep stdout for nothing but the JSON.
+	s.SetLog(os.Stderr)
+	pr, page, err := s.Get(propertyID, *year)
+
+	if *htmlFile != "" && page != nil {
+		if werr := os.WriteFile(*htmlFile, page, 0o644); werr != nil {
+			log.Println(werr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if *persist {
+		if err := s.Save(pr); err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(pr)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/proxies"
+	"github.com/jason-costello/taxcollector/scraper"
+)
+
+const proxiesUsage = `usage: scrape proxies <command> [flags] [args]
+
+commands:
+  list                      show every proxy with its uses, last use, health and score
+  add <proxy>...            add proxies given as host:port, user:pass@host:port or scheme://...
+  import <file>...          add proxies from list or JSON files
+  retire <host:port>...     take proxies out of rotation for good
+  reset [-all] <host:port>  put retired or benched proxies back into rotation
+  remove <host:port>...     delete proxies
+  probe [-loop]             check every proxy against -probe-url
+  stats [log]...            per-proxy job results from scraper run logs (default stdin)
+`
+
+// runProxies administers the proxies table:
+//
+//	scrape proxies list
+//	scrape proxies reset -all
+//	scrape proxies stats scrape.log
+func runProxies(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, proxiesUsage)
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "list":
+		runProxiesList(args)
+	case "add":
+		runProxiesAdd(args)
+	case "import":
+		runProxiesImport(args)
+	case "retire":
+		runProxiesRetire(args)
+	case "reset":
+		runProxiesReset(args)
+	case "remove":
+		runProxiesRemove(args)
+	case "probe":
+		runProxiesProbe(args)
+	case "stats":
+		runProxiesStats(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown proxies command %q\n\n%s", cmd, proxiesUsage)
+		os.Exit(2)
+	}
+}
+
+// loadProxiesConfig loads the config for a proxies command and opens the
+// database. needArgs makes positional arguments mandatory.
+func loadProxiesConfig(fs *flag.FlagSet, args []string, needArgs bool) (*config.Config, *sql.DB, *proxies.ProxyClient) {
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if needArgs && fs.NArg() == 0 {
+		fmt.Fprint(os.Stderr, proxiesUsage)
+		os.Exit(2)
+	}
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg, db, proxies.NewProxyClient(db)
+}
+
+func runProxiesList(args []string) {
+	fs := flag.NewFlagSet("proxies list", flag.ExitOnError)
+	_, db, pc := loadProxiesConfig(fs, args, false)
+	defer db.Close()
+
+	list, err := pc.List(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROXY\tSCHEME\tSTATUS\tUSES\tLAST USED\tOK\tFAILED\tLATENCY\tSCORE")
+	for _, pr := range list {
+		lastUsed := "never"
+		if !pr.LastUsed.IsZero() {
+			lastUsed = now.Sub(pr.LastUsed).Round(time.Second).String() + " ago"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\t%d\t%s\t%.2f\n",
+			pr.IP, pr.ProxyURL().Scheme, proxyStatus(pr, now), pr.Uses, lastUsed,
+			pr.Successes, pr.Failures, pr.Latency.Round(time.Millisecond), pr.Score)
+	}
+	tw.Flush()
+}
+
+func proxyStatus(pr proxies.Proxy, now time.Time) string {
+	switch {
+	case pr.IsBad:
+		return "retired"
+	case pr.CooldownUntil.After(now):
+		return "benched " + pr.CooldownUntil.Sub(now).Round(time.Second).String()
+	case pr.LeasedUntil.After(now):
+		return "leased"
+	}
+	return "ok"
+}
+
+func runProxiesAdd(args []string) {
+	fs := flag.NewFlagSet("proxies add", flag.ExitOnError)
+	_, db, pc := loadProxiesConfig(fs, args, true)
+	defer db.Close()
+
+	var list []proxies.Proxy
+	for _, s := range fs.Args() {
+		pr, err := proxies.ParseProxy(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		list = append(list, pr)
+	}
+	if err := pc.Import(context.Background(), list); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("added %d proxies", len(list))
+}
+
+func runProxiesImport(args []string) {
+	fs := flag.NewFlagSet("proxies import", flag.ExitOnError)
+	_, db, pc := loadProxiesConfig(fs, args, true)
+	defer db.Close()
+
+	var list []proxies.Proxy
+	for _, path := range fs.Args() {
+		prs, err := proxies.ParseFile(path)
+		if err != nil {
+			log.Fatalf("%s: %s", path, err)
+		}
+		list = append(list, prs...)
+	}
+	if err := pc.Import(context.Background(), list); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("imported %d proxies", len(list))
+}
+
+func runProxiesRetire(args []string) {
+	fs := flag.NewFlagSet("proxies retire", flag.ExitOnError)
+	_, db, pc := loadProxiesConfig(fs, args, true)
+	defer db.Close()
+
+	for _, ip := range fs.Args() {
+		if _, err := pc.Get(ip); err != nil {
+			log.Fatalf("%s: %s", ip, err)
+		}
+		if err := pc.MarkProxyAsBad(ip); err != nil {
+			log.Fatalf("%s: %s", ip, err)
+		}
+	}
+}
+
+func runProxiesReset(args []string) {
+	fs := flag.NewFlagSet("proxies reset", flag.ExitOnError)
+	all := fs.Bool("all", false, "reset every retired or benched proxy")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *all == (fs.NArg() > 0) {
+		log.Fatal("proxies reset needs either -all or proxies to reset")
+	}
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	pc := proxies.NewProxyClient(db)
+
+	if *all {
+		n, err := pc.ResetAll(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("reset %d proxies", n)
+		return
+	}
+	for _, ip := range fs.Args() {
+		if err := pc.Reset(context.Background(), ip); err != nil {
+			log.Fatalf("%s: %s", ip, err)
+		}
+	}
+}
+
+func runProxiesRemove(args []string) {
+	fs := flag.NewFlagSet("proxies remove", flag.ExitOnError)
+	_, db, pc := loadProxiesConfig(fs, args, true)
+	defer db.Close()
+
+	for _, ip := range fs.Args() {
+		if err := pc.Remove(context.Background(), ip); err != nil {
+			log.Fatalf("%s: %s", ip, err)
+		}
+	}
+}
+
+// runProxiesProbe checks every proxy against the configured probe URL once,
+// or every -probe-interval with -loop, and records the results.
+func runProxiesProbe(args []string) {
+	fs := flag.NewFlagSet("proxies probe", flag.ExitOnError)
+	loop := fs.Bool("loop", false, "keep probing every -probe-interval")
+	cfg, db, _ := loadProxiesConfig(fs, args, false)
+	defer db.Close()
+	if cfg.Proxies.ProbeURL == "" {
+		log.Fatal("proxies probe needs -probe-url")
+	}
+	p := newProber(cfg, db)
+
+	if *loop {
+		p.Run(context.Background(), cfg.Proxies.ProbeInterval.Duration)
+		return
+	}
+	results, err := p.ProbeAll(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("%-24s FAIL  %s\n", r.Proxy.IP, r.Err)
+			continue
+		}
+		fmt.Printf("%-24s ok    %s\n", r.Proxy.IP, r.Latency.Round(time.Millisecond))
+	}
+}
+
+// runProxiesStats summarises how jobs through each proxy went in scraper run
+// logs, i.e. the saved stdout of scrape.
+func runProxiesStats(args []string) {
+	fs := flag.NewFlagSet("proxies stats", flag.ExitOnError)
+	top := fs.Int("errors", 3, "most common errors to show per proxy")
+	fs.Parse(args)
+
+	stats := map[string]*scraper.ProxyRunStats{}
+	if fs.NArg() == 0 {
+		if err := scraper.ReadProxyStats(os.Stdin, stats); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = scraper.ReadProxyStats(f, stats)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", path, err)
+		}
+	}
+
+	var sorted []*scraper.ProxyRunStats
+	for _, st := range stats {
+		sorted = append(sorted, st)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Proxy < sorted[j].Proxy })
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROXY\tJOBS\tOK\tSUCCESS\tTOP ERRORS")
+	for _, st := range sorted {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f%%\t%s\n", st.Proxy, st.Jobs, st.Succeeded,
+			100*float64(st.Succeeded)/float64(st.Jobs), topErrors(st.Errors, *top))
+	}
+	tw.Flush()
+}
+
+func topErrors(errs map[string]int, n int) string {
+	type count struct {
+		msg string
+		n   int
+	}
+	var counts []count
+	for msg, c := range errs {
+		counts = append(counts, count{msg, c})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].n != counts[j].n {
+			return counts[i].n > counts[j].n
+		}
+		return counts[i].msg < counts[j].msg
+	})
+	if len(counts) > n {
+		counts = counts[:n]
+	}
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = fmt.Sprintf("%dx %s", c.n, c.msg)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func newProber(cfg *config.Config, db *sql.DB) *proxies.Prober {
+	return proxies.NewProber(proxies.NewProxyClient(db), proxies.ProbeOptions{
+		URL:         cfg.Proxies.ProbeURL,
+		Expect:      cfg.Proxies.ProbeExpect,
+		Timeout:     cfg.Proxies.ProbeTimeout.Duration,
+		Concurrency: cfg.Proxies.ProbeConcurrency,
+	})
+}
@@ -3,50 +3,304 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"sync"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jason-costello/taxcollector/storage/pgdb"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/politeness"
 	"github.com/jason-costello/taxcollector/proxies"
+	"github.com/jason-costello/taxcollector/refresh"
 	"github.com/jason-costello/taxcollector/scraper"
+	"github.com/jason-costello/taxcollector/sink"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate"
 	"github.com/jason-costello/taxcollector/useragents"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "get":
+			if err := runGet(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "proxies":
+			runProxies(os.Args[2:])
+			return
+		}
+	}
+
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	daemon := fs.Bool("daemon", false, "keep running and re-queue stale properties every -refresh-poll")
+	watch := fs.String("watch", "", "comma separated property IDs to flag for daily refreshes")
+	sinkKind := fs.String("sink", "sql", "where scraped records go: "+strings.Join(sink.Kinds, ", "))
+	out := fs.String("out", "", "output file for the ndjson sinks, output directory for csv and parquet")
+	ids := fs.String("ids", "", "comma separated property IDs to scrape instead of the pending_urls queue")
+	cfg, err := config.Load(fs, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Scraping a list of IDs straight to files doesn't touch the database,
+	// so it runs without one.
+	var db *sql.DB
+	if *sinkKind == "sql" || *ids == "" || *daemon || *watch != "" {
+		db, err = cfg.DB.Open()
+		if err != nil {
+			panic(err)
+		}
+		defer db.Close()
+		if err := migrate.Check(context.Background(), db, cfg.DB.Driver); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		"192.168.1.100", 5432, "postgres", "postgres", "tax")
+	rs, err := sink.Open(*sinkKind, *out, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := rs.Close(); err != nil {
+			log.Println("closing sink:", err)
+		}
+	}()
 
-	db, err := sql.Open("postgres", psqlInfo)
+	s, closeScraper, err := newScraper(cfg, db)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	defer closeScraper()
+	s.SetSink(rs)
+
+	if cfg.MetricsAddr != "" {
+		go func() {
+			log.Println(metrics.Serve(cfg.MetricsAddr))
+		}()
+	}
+
+	if *ids != "" && !*daemon {
+		var urls []string
+		for _, id := range strings.Split(*ids, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				urls = append(urls, s.DetailURL(id))
+			}
+		}
+		s.Scrape(urls)
+		if db == nil {
+			return
+		}
+		if *sinkKind == "sql" {
+			if err := reclassify(context.Background(), db); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
-	defer db.Close()
 
-	uac := &useragents.UserAgentClient{}
-	uac.LoadUserAgents("../../useragents.txt")
-	pc := proxies.NewProxyClient(db)
+	pdb := pgdb.NewFor(db)
+	go reportQueueDepth(pdb, 15*time.Second)
 
+	policy, err := refreshPolicy(cfg.Refresh)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	s := scraper.NewScraper(pc, uac, db, nil)
-	pdb := pgdb.New(db)
+	planner := refresh.NewPlanner(db, policy, s.DetailURL)
 
-	remainingCount, err := pdb.GetRemainingURLCount(context.Background())
+	if *watch != "" {
+		for _, id := range strings.Split(*watch, ",") {
+			pid, err := strconv.Atoi(strings.TrimSpace(id))
+			if err != nil {
+				log.Fatalf("invalid -watch property id %q", id)
+			}
+			if err := planner.Watch(context.Background(), int32(pid), 0); err != nil {
+				log.Fatalf("-watch: %v", err)
+			}
+		}
+	}
+
+	if !*daemon {
+		if *ids == "" {
+			n, err := scrapePending(context.Background(), s, pdb)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if n > 0 && *sinkKind == "sql" {
+				if err := reclassify(context.Background(), db); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		return
+	}
+
+	if cfg.Proxies.ProbeURL != "" {
+		go newProber(cfg, db).Run(context.Background(), cfg.Proxies.ProbeInterval.Duration)
+	}
+
+	for {
+		if _, err := planner.Enqueue(context.Background(), time.Now()); err != nil {
+			log.Println("refresh:", err)
+		}
+		n, err := scrapePending(context.Background(), s, pdb)
+		if err != nil {
+			log.Println("pending urls:", err)
+		}
+		if n > 0 && *sinkKind == "sql" {
+			if err := reclassify(context.Background(), db); err != nil {
+				log.Println("occupancy:", err)
+			}
+		}
+		time.Sleep(cfg.Refresh.PollInterval.Duration)
+	}
+}
+
+// newScraper builds a scraper from cfg. The returned func writes back proxy
+// stats still held in memory and should run before exit.
+func newScraper(cfg *config.Config, db *sql.DB) (*scraper.Scraper, func(), error) {
+	uap, err := useragents.New(cfg.Scraper.UserAgent, cfg.Scraper.UserAgentFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	pp, closeProxies, err := newProxyProvider(cfg, db)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
-	urls, err := pdb.GetRandomURLs(context.Background(), int32(remainingCount))
+	s := scraper.NewScraper(pp, uap, db, nil)
+	s.SetPortal(cfg.Scraper.PortalURL, cfg.Scraper.County)
+	s.SetWorkers(cfg.Scraper.Workers)
+	s.SetRateLimit(cfg.Scraper.JobsPerSecond, cfg.Scraper.WorkerDelay.Duration)
+	s.SetPipeline(cfg.Scraper.Parsers, cfg.Scraper.BatchSize, cfg.Scraper.FlushInterval.Duration)
+	if cfg.Politeness.Enabled {
+		pol := politeness.New(cfg.Politeness.ContactUserAgent, cfg.Politeness.DailyBudget)
+		// Without a database the budget only holds for this process.
+		if db != nil {
+			pol.SetDB(db)
+		}
+		s.SetPoliteness(pol)
+	}
+	return s, closeProxies, nil
+}
+
+// newProxyProvider returns the proxy source cfg asks for. The proxies table
+// needs a database; without one the scraper fetches directly.
+func newProxyProvider(cfg *config.Config, db *sql.DB) (proxies.ProxyProvider, func(), error) {
+	noop := func() {}
+	switch cfg.Proxies.Source {
+	case "static":
+		var list []proxies.Proxy
+		for _, s := range cfg.Proxies.Static {
+			pr, err := proxies.ParseProxy(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, pr)
+		}
+		if err := proxies.CheckConflicts(list); err != nil {
+			return nil, nil, err
+		}
+		return proxies.NewStatic(list), noop, nil
+	case "db":
+		if db == nil {
+			return proxies.Direct{}, noop, nil
+		}
+		pc := proxies.NewProxyClient(db)
+		if cfg.Proxies.PoolSync.Duration <= 0 {
+			return pc, noop, nil
+		}
+		pool, err := proxies.NewPool(context.Background(), pc)
+		if err != nil {
+			return nil, nil, err
+		}
+		go pool.Run(context.Background(), cfg.Proxies.PoolSync.Duration)
+		return pool, func() {
+			if err := pool.Sync(context.Background()); err != nil {
+				log.Println("proxy pool:", err)
+			}
+		}, nil
+	}
+	return proxies.Direct{}, noop, nil
+}
+
+// pendingBatch is how many queued URLs scrapePending loads at a time.
+const pendingBatch = 1000
+
+// scrapePending scrapes the pending_urls queue a batch at a time until it is
+// empty, or a batch leaves it no shorter because every URL in it failed and
+// stays queued for the next run. It returns how many URLs it scraped.
+func scrapePending(ctx context.Context, s *scraper.Scraper, pdb *pgdb.Queries) (int, error) {
+	scraped := 0
+	remaining, err := pdb.GetRemainingURLCount(ctx)
+	if err != nil {
+		return scraped, err
+	}
+	for remaining > 0 {
+		urls, err := pdb.GetRandomURLs(ctx, pendingBatch)
+		if err != nil {
+			return scraped, err
+		}
+		s.Scrape(urls)
+		scraped += len(urls)
+
+		left, err := pdb.GetRemainingURLCount(ctx)
+		if err != nil {
+			return scraped, err
+		}
+		if left >= remaining {
+			return scraped, nil
+		}
+		remaining = left
+	}
+	return scraped, nil
+}
+
+// reclassify settles the occupancy of properties saved before the scrape had
+// seen all of the county's ZIP codes.
+func reclassify(ctx context.Context, db *sql.DB) error {
+	counts, err := storage.NewSQL(db).Reclassify(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("occupancy: %v", counts)
+	return nil
+}
+
+func refreshPolicy(rc config.Refresh) (refresh.Policy, error) {
+	start, err := refresh.ParseMonthDay(rc.SeasonStart)
 	if err != nil {
-		panic(err)
+		return refresh.Policy{}, err
+	}
+	end, err := refresh.ParseMonthDay(rc.SeasonEnd)
+	if err != nil {
+		return refresh.Policy{}, err
+	}
+	return refresh.Policy{
+		WatchedInterval: rc.WatchedInterval.Duration,
+		DefaultInterval: rc.DefaultInterval.Duration,
+		SeasonInterval:  rc.SeasonInterval.Duration,
+		SeasonStart:     start,
+		SeasonEnd:       end,
+		BatchSize:       rc.BatchSize,
+	}, nil
+}
+
+func reportQueueDepth(pdb *pgdb.Queries, interval time.Duration) {
+	for {
+		count, err := pdb.GetRemainingURLCount(context.Background())
+		if err != nil {
+			log.Println("queue depth:", err)
+		} else {
+			metrics.PendingURLs.Set(float64(count))
+		}
+		time.Sleep(interval)
 	}
-	wg := new(sync.WaitGroup)
-	wg.Add(len(urls))
-	s.Scrape(urls)
-	wg.Wait()
 }
@@ -1,29 +1,43 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"flag"
+	"log"
 	"net/http"
+	"os"
 
-	"github.com/jason-costello/taxcollector/web"
+	"github.com/jason-costello/taxcollector/api"
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/storage/migrate"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 func main() {
 
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=disable",
-		"127.0.0.1", 5432, "postgres", "password", "tax")
+	cfg, err := config.Load(flag.NewFlagSet("web", flag.ExitOnError), os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	db, err := cfg.DB.Open()
 	if err != nil {
 		panic(err)
 	}
 	defer db.Close()
+	if err := migrate.Check(context.Background(), db, cfg.DB.Driver); err != nil {
+		log.Fatal(err)
+	}
 
-	hs := http.Server{}
-	server := web.NewServer(db, &hs)
+	if cfg.MetricsAddr != "" {
+		go func() {
+			log.Println(metrics.Serve(cfg.MetricsAddr))
+		}()
+	}
 
-	server.Serve()
+	hs := http.Server{Addr: cfg.ListenAddr, Handler: api.NewRouter(db)}
+	log.Println(hs.ListenAndServe())
 
 }
@@ -0,0 +1,501 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration wraps time.Duration so config files can say "1s" or "250ms".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+type DB struct {
+	Driver       string `json:"driver"`
+	DSN          string `json:"dsn"`
+	DSNFile      string `json:"dsnFile"`
+	Host         string `json:"host"`
+	Port         int    `json:"port"`
+	User         string `json:"user"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"passwordFile"`
+	Name         string `json:"name"`
+	SSLMode      string `json:"sslMode"`
+}
+
+type Scraper struct {
+	Workers int `json:"workers"`
+	// JobsPerSecond caps how many property jobs start per second across all
+	// workers. Zero means no global cap.
+	JobsPerSecond float64 `json:"jobsPerSecond"`
+	// WorkerDelay is how long each worker pauses between jobs.
+	WorkerDelay Duration `json:"workerDelay"`
+	// Parsers is how many goroutines turn fetched pages into records.
+	Parsers int `json:"parsers"`
+	// BatchSize is how many records the writer commits per transaction;
+	// FlushInterval bounds how long a partial batch waits.
+	BatchSize     int      `json:"batchSize"`
+	FlushInterval Duration `json:"flushInterval"`
+	PortalURL     string   `json:"portalURL"`
+	County        string   `json:"county"`
+	// UserAgent, when set, is sent on every request. Otherwise each job
+	// picks a random agent from UserAgentFile, or from the built-in list
+	// when that is empty too.
+	UserAgent     string `json:"userAgent"`
+	UserAgentFile string `json:"userAgentFile"`
+}
+
+// Refresh controls the daemon mode of cmd/scrape, which re-queues properties
+// once they go stale. SeasonStart and SeasonEnd are "MM-DD" dates.
+type Refresh struct {
+	WatchedInterval Duration `json:"watchedInterval"`
+	DefaultInterval Duration `json:"defaultInterval"`
+	SeasonInterval  Duration `json:"seasonInterval"`
+	SeasonStart     string   `json:"seasonStart"`
+	SeasonEnd       string   `json:"seasonEnd"`
+	BatchSize       int      `json:"batchSize"`
+	PollInterval    Duration `json:"pollInterval"`
+}
+
+// Politeness makes the scraper honour the portal's robots.txt, identify itself
+// with ContactUserAgent instead of rotating browser agents and stop for the
+// day after DailyBudget requests (0 = no limit). The budget is kept in the
+// database, shared by every process using it, when there is one.
+type Politeness struct {
+	Enabled          bool   `json:"enabled"`
+	ContactUserAgent string `json:"contactUserAgent"`
+	DailyBudget      int    `json:"dailyBudget"`
+}
+
+// Proxies chooses where the scraper gets its proxies. Source "db" uses the
+// proxies table, "static" the Static list (host:port, user:pass@host:port or
+// full URLs, from the config file or comma separated in TAX_PROXIES) and
+// "direct" no proxy at all. With the table, PoolSync > 0
+// leases proxies from memory and writes back that often instead of a
+// database round trip per job. An empty ProbeURL turns off the background
+// prober.
+type Proxies struct {
+	Source           string   `json:"source"`
+	Static           []string `json:"static"`
+	PoolSync         Duration `json:"poolSync"`
+	ProbeURL         string   `json:"probeURL"`
+	ProbeExpect      string   `json:"probeExpect"`
+	ProbeInterval    Duration `json:"probeInterval"`
+	ProbeTimeout     Duration `json:"probeTimeout"`
+	ProbeConcurrency int      `json:"probeConcurrency"`
+}
+
+type Geocoder struct {
+	MapQuestKey     string `json:"mapQuestKey"`
+	MapQuestKeyFile string `json:"mapQuestKeyFile"`
+}
+
+type Config struct {
+	DB          DB         `json:"db"`
+	Scraper     Scraper    `json:"scraper"`
+	Refresh     Refresh    `json:"refresh"`
+	Politeness  Politeness `json:"politeness"`
+	Proxies     Proxies    `json:"proxies"`
+	Geocoder    Geocoder   `json:"geocoder"`
+	ListenAddr  string     `json:"listenAddr"`
+	MetricsAddr string     `json:"metricsAddr"`
+}
+
+func Default() Config {
+	return Config{
+		DB: DB{
+			Driver:  "postgres",
+			Host:    "127.0.0.1",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "tax",
+			SSLMode: "disable",
+		},
+		Scraper: Scraper{
+			Workers:       runtime.NumCPU(),
+			WorkerDelay:   Duration{time.Second},
+			Parsers:       runtime.NumCPU(),
+			BatchSize:     50,
+			FlushInterval: Duration{2 * time.Second},
+			PortalURL:     "https://propaccess.trueautomation.com",
+			County:        "56",
+		},
+		Refresh: Refresh{
+			WatchedInterval: Duration{24 * time.Hour},
+			DefaultInterval: Duration{30 * 24 * time.Hour},
+			SeasonInterval:  Duration{7 * 24 * time.Hour},
+			SeasonStart:     "04-01",
+			SeasonEnd:       "06-15",
+			BatchSize:       500,
+			PollInterval:    Duration{time.Hour},
+		},
+		Proxies: Proxies{
+			Source:           "db",
+			ProbeInterval:    Duration{10 * time.Minute},
+			ProbeTimeout:     Duration{10 * time.Second},
+			ProbeConcurrency: 8,
+		},
+		ListenAddr: ":8888",
+	}
+}
+
+// defaultMetricsAddr is where each command serves metrics unless configured
+// otherwise, keyed by the name of the flag set it loads its config with, so
+// the scraper and the API can run side by side.
+var defaultMetricsAddr = map[string]string{
+	"scrape": ":2112",
+	"web":    ":2113",
+}
+
+// setting ties a config field to its environment variable and flag. Every
+// source goes through the same setter so values are parsed identically.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func setString(p func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*p(c) = v
+		return nil
+	}
+}
+
+func setDuration(p func(c *Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		p(c).Duration = d
+		return err
+	}
+}
+
+var settings = []setting{
+	{"db-driver", "TAX_DB_DRIVER", "database driver: postgres or sqlite3", setString(func(c *Config) *string { return &c.DB.Driver })},
+	{"db-dsn", "TAX_DB_DSN", "full data source name, overrides the other db-* settings", setString(func(c *Config) *string { return &c.DB.DSN })},
+	{"db-dsn-file", "TAX_DB_DSN_FILE", "file holding the data source name", setString(func(c *Config) *string { return &c.DB.DSNFile })},
+	{"db-host", "TAX_DB_HOST", "postgres host", setString(func(c *Config) *string { return &c.DB.Host })},
+	{"db-port", "TAX_DB_PORT", "postgres port", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.DB.Port = i
+		return err
+	}},
+	{"db-user", "TAX_DB_USER", "postgres user", setString(func(c *Config) *string { return &c.DB.User })},
+	{"db-password-file", "TAX_DB_PASSWORD_FILE", "file holding the postgres password", setString(func(c *Config) *string { return &c.DB.PasswordFile })},
+	{"db-name", "TAX_DB_NAME", "postgres database name", setString(func(c *Config) *string { return &c.DB.Name })},
+	{"db-sslmode", "TAX_DB_SSLMODE", "postgres sslmode", setString(func(c *Config) *string { return &c.DB.SSLMode })},
+	{"workers", "TAX_WORKERS", "number of scraper workers", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Scraper.Workers = i
+		return err
+	}},
+	{"rate-limit", "TAX_RATE_LIMIT", "max property jobs started per second across all workers (0 = unlimited)", func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		c.Scraper.JobsPerSecond = f
+		return err
+	}},
+	{"worker-delay", "TAX_WORKER_DELAY", "pause between jobs on each worker", setDuration(func(c *Config) *Duration { return &c.Scraper.WorkerDelay })},
+	{"parsers", "TAX_PARSERS", "number of goroutines parsing fetched pages", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Scraper.Parsers = i
+		return err
+	}},
+	{"batch-size", "TAX_BATCH_SIZE", "property records written per transaction", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Scraper.BatchSize = i
+		return err
+	}},
+	{"flush-interval", "TAX_FLUSH_INTERVAL", "max time a partial batch waits before it is written", setDuration(func(c *Config) *Duration { return &c.Scraper.FlushInterval })},
+	{"portal-url", "TAX_PORTAL_URL", "PropAccess base URL", setString(func(c *Config) *string { return &c.Scraper.PortalURL })},
+	{"county", "TAX_COUNTY", "PropAccess county client id (cid)", setString(func(c *Config) *string { return &c.Scraper.County })},
+	{"user-agent", "TAX_USER_AGENT", "User-Agent sent on every request instead of rotating browser agents", setString(func(c *Config) *string { return &c.Scraper.UserAgent })},
+	{"user-agents", "TAX_USER_AGENTS", "user agent list file, one per line (default built-in list)", setString(func(c *Config) *string { return &c.Scraper.UserAgentFile })},
+	{"refresh-watched", "TAX_REFRESH_WATCHED", "re-scrape watched properties after this long", setDuration(func(c *Config) *Duration { return &c.Refresh.WatchedInterval })},
+	{"refresh-default", "TAX_REFRESH_DEFAULT", "re-scrape other properties after this long", setDuration(func(c *Config) *Duration { return &c.Refresh.DefaultInterval })},
+	{"refresh-season", "TAX_REFRESH_SEASON", "re-scrape other properties after this long during appraisal-notice season", setDuration(func(c *Config) *Duration { return &c.Refresh.SeasonInterval })},
+	{"season-start", "TAX_SEASON_START", "first day of appraisal-notice season (MM-DD)", setString(func(c *Config) *string { return &c.Refresh.SeasonStart })},
+	{"season-end", "TAX_SEASON_END", "day appraisal-notice season ends, exclusive (MM-DD)", setString(func(c *Config) *string { return &c.Refresh.SeasonEnd })},
+	{"refresh-batch", "TAX_REFRESH_BATCH", "max properties queued per refresh pass", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Refresh.BatchSize = i
+		return err
+	}},
+	{"refresh-poll", "TAX_REFRESH_POLL", "how often the daemon looks for stale properties", setDuration(func(c *Config) *Duration { return &c.Refresh.PollInterval })},
+	{"polite", "TAX_POLITE", "honour robots.txt, send the contact user agent and enforce the daily budget (true/false)", func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		c.Politeness.Enabled = b
+		return err
+	}},
+	{"contact-user-agent", "TAX_CONTACT_USER_AGENT", "User-Agent sent in polite mode, with a way to reach you", setString(func(c *Config) *string { return &c.Politeness.ContactUserAgent })},
+	{"daily-budget", "TAX_DAILY_BUDGET", "max portal requests per day in polite mode (0 = unlimited)", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Politeness.DailyBudget = i
+		return err
+	}},
+	{"proxy-source", "TAX_PROXY_SOURCE", "where proxies come from: db, static or direct", setString(func(c *Config) *string { return &c.Proxies.Source })},
+	{"proxy-pool-sync", "TAX_PROXY_POOL_SYNC", "lease proxies from memory and sync them to the database this often (0 = lease from the database)", setDuration(func(c *Config) *Duration { return &c.Proxies.PoolSync })},
+	{"probe-url", "TAX_PROBE_URL", "URL fetched through each proxy by the background prober (empty disables)", setString(func(c *Config) *string { return &c.Proxies.ProbeURL })},
+	{"probe-expect", "TAX_PROBE_EXPECT", "text a probe response must contain", setString(func(c *Config) *string { return &c.Proxies.ProbeExpect })},
+	{"probe-interval", "TAX_PROBE_INTERVAL", "how often every proxy is probed", setDuration(func(c *Config) *Duration { return &c.Proxies.ProbeInterval })},
+	{"probe-timeout", "TAX_PROBE_TIMEOUT", "how long a single proxy probe may take", setDuration(func(c *Config) *Duration { return &c.Proxies.ProbeTimeout })},
+	{"probe-concurrency", "TAX_PROBE_CONCURRENCY", "proxies probed at once", func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		c.Proxies.ProbeConcurrency = i
+		return err
+	}},
+	{"mapquest-key-file", "TAX_MAPQUEST_KEY_FILE", "file holding the MapQuest geocoding key", setString(func(c *Config) *string { return &c.Geocoder.MapQuestKeyFile })},
+	{"listen", "TAX_LISTEN_ADDR", "API listen address", setString(func(c *Config) *string { return &c.ListenAddr })},
+	{"metrics-listen", "TAX_METRICS_ADDR", "metrics listen address, :2112 for scrape and :2113 for web by default (empty disables)", setString(func(c *Config) *string { return &c.MetricsAddr })},
+}
+
+// Secrets are only accepted from files or the environment, never flags, so
+// they don't show up in ps output or shell history.
+var secretEnv = []struct {
+	env string
+	set func(c *Config, v string)
+}{
+	{"TAX_DB_PASSWORD", func(c *Config, v string) { c.DB.Password = v }},
+	{"TAX_MAPQUEST_KEY", func(c *Config, v string) { c.Geocoder.MapQuestKey = v }},
+	// Static proxies can carry credentials.
+	{"TAX_PROXIES", func(c *Config, v string) {
+		c.Proxies.Static = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.Proxies.Static = append(c.Proxies.Static, p)
+			}
+		}
+	}},
+}
+
+// Load registers the shared flags on fs, parses args and builds the config
+// from, in increasing precedence: defaults, the JSON file named by -config or
+// TAX_CONFIG, TAX_* environment variables and finally flags. Commands can add
+// their own flags to fs before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	configFile := fs.String("config", os.Getenv("TAX_CONFIG"), "JSON config file")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flag] = fs.String(s.flag, "", s.usage+" (env "+s.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	c := Default()
+	c.MetricsAddr = defaultMetricsAddr[fs.Name()]
+	if *configFile != "" {
+		b, err := os.ReadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("config %s: %w", *configFile, err)
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := s.set(&c, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range secretEnv {
+		if v, ok := os.LookupEnv(s.env); ok {
+			s.set(&c, v)
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(&c, *flagValues[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := c.readSecrets(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (c *Config) readSecrets() error {
+	for _, s := range []struct {
+		file string
+		dst  *string
+	}{
+		{c.DB.PasswordFile, &c.DB.Password},
+		{c.DB.DSNFile, &c.DB.DSN},
+		{c.Geocoder.MapQuestKeyFile, &c.Geocoder.MapQuestKey},
+	} {
+		if s.file == "" {
+			continue
+		}
+		b, err := os.ReadFile(s.file)
+		if err != nil {
+			return err
+		}
+		*s.dst = strings.TrimSpace(string(b))
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []string
+	switch c.DB.Driver {
+	case "postgres":
+		if c.DB.DSN == "" && (c.DB.Host == "" || c.DB.Name == "") {
+			errs = append(errs, "postgres needs a dsn or a host and database name")
+		}
+	case "sqlite3":
+		if c.DB.DSN == "" {
+			errs = append(errs, "sqlite3 needs a dsn (database file path)")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported db driver %q", c.DB.Driver))
+	}
+	if c.Scraper.Workers < 1 {
+		errs = append(errs, "workers must be at least 1")
+	}
+	if c.Scraper.JobsPerSecond < 0 {
+		errs = append(errs, "rate limit can't be negative")
+	}
+	if c.Scraper.WorkerDelay.Duration < 0 {
+		errs = append(errs, "worker delay can't be negative")
+	}
+	if c.Scraper.Parsers < 1 {
+		errs = append(errs, "parsers must be at least 1")
+	}
+	if c.Scraper.BatchSize < 1 {
+		errs = append(errs, "batch size must be at least 1")
+	}
+	if c.Scraper.FlushInterval.Duration <= 0 {
+		errs = append(errs, "flush interval must be positive")
+	}
+	if _, err := strconv.Atoi(c.Scraper.County); err != nil {
+		errs = append(errs, fmt.Sprintf("county must be a numeric client id, got %q", c.Scraper.County))
+	}
+	if u, err := url.Parse(c.Scraper.PortalURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("invalid portal url %q", c.Scraper.PortalURL))
+	}
+	if c.Scraper.UserAgent != "" && c.Scraper.UserAgentFile != "" {
+		errs = append(errs, "set either a user agent or a user agent file, not both")
+	}
+	for _, md := range []string{c.Refresh.SeasonStart, c.Refresh.SeasonEnd} {
+		if _, err := time.Parse("01-02", md); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid season date %q, want MM-DD", md))
+		}
+	}
+	if c.Politeness.Enabled && strings.TrimSpace(c.Politeness.ContactUserAgent) == "" {
+		errs = append(errs, "polite mode needs a contact user agent")
+	}
+	if c.Politeness.DailyBudget < 0 {
+		errs = append(errs, "daily budget can't be negative")
+	}
+	if c.Proxies.ProbeURL != "" {
+		if u, err := url.Parse(c.Proxies.ProbeURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Sprintf("invalid probe url %q", c.Proxies.ProbeURL))
+		}
+	}
+	switch c.Proxies.Source {
+	case "db", "direct":
+	case "static":
+		if len(c.Proxies.Static) == 0 {
+			errs = append(errs, "static proxy source needs a list of proxies")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported proxy source %q", c.Proxies.Source))
+	}
+	if c.Proxies.PoolSync.Duration < 0 {
+		errs = append(errs, "proxy pool sync interval can't be negative")
+	}
+	if c.Proxies.ProbeInterval.Duration <= 0 {
+		errs = append(errs, "probe interval must be positive")
+	}
+	if c.Proxies.ProbeTimeout.Duration <= 0 {
+		errs = append(errs, "probe timeout must be positive")
+	}
+	if c.Proxies.ProbeConcurrency < 1 {
+		errs = append(errs, "probe concurrency must be at least 1")
+	}
+	if c.Refresh.BatchSize < 1 {
+		errs = append(errs, "refresh batch size must be at least 1")
+	}
+	if c.Refresh.PollInterval.Duration <= 0 {
+		errs = append(errs, "refresh poll interval must be positive")
+	}
+	if c.ListenAddr == "" {
+		errs = append(errs, "listen address is required")
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// DataSourceName returns the DSN to hand to sql.Open for the configured driver.
+// SQLite only enforces foreign keys, and so the cascading deletes of the
+// child tables, when the connection asks for it.
+func (d DB) DataSourceName() string {
+	if d.Driver == "sqlite3" {
+		if d.DSN == "" || strings.Contains(d.DSN, "_foreign_keys") || strings.Contains(d.DSN, "_fk=") {
+			return d.DSN
+		}
+		sep := "?"
+		if strings.Contains(d.DSN, "?") {
+			sep = "&"
+		}
+		return d.DSN + sep + "_foreign_keys=on"
+	}
+	if d.DSN != "" || d.Driver != "postgres" {
+		return d.DSN
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, d.Name, d.SSLMode)
+	if d.Password != "" {
+		dsn += fmt.Sprintf(" password='%s'", strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(d.Password))
+	}
+	return dsn
+}
+
+// Open opens the database. The caller must import the driver.
+func (d DB) Open() (*sql.DB, error) {
+	return sql.Open(d.Driver, d.DataSourceName())
+}
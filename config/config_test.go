@@ -0,0 +1,151 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func Test_LoadPrecedence(t *testing.T) {
+	file := writeFile(t, "tax.json", `{
+		"db": {"host": "10.0.0.5", "name": "fromfile"},
+		"scraper": {"workers": 3, "workerDelay": "250ms", "county": "12"}
+	}`)
+	password := writeFile(t, "pw", "s3cret\n")
+
+	t.Setenv("TAX_CONFIG", file)
+	t.Setenv("TAX_DB_NAME", "fromenv")
+	t.Setenv("TAX_WORKERS", "5")
+	t.Setenv("TAX_DB_PASSWORD_FILE", password)
+
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-workers", "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.DB.Host != "10.0.0.5" {
+		t.Errorf("DB.Host = %q, want value from file", c.DB.Host)
+	}
+	if c.DB.Name != "fromenv" {
+		t.Errorf("DB.Name = %q, want env to override file", c.DB.Name)
+	}
+	if c.Scraper.Workers != 7 {
+		t.Errorf("Scraper.Workers = %d, want flag to override env", c.Scraper.Workers)
+	}
+	if c.Scraper.WorkerDelay.Duration != 250*time.Millisecond {
+		t.Errorf("Scraper.WorkerDelay = %s, want 250ms", c.Scraper.WorkerDelay)
+	}
+	if c.DB.Password != "s3cret" {
+		t.Errorf("DB.Password = %q, want it read from the password file", c.DB.Password)
+	}
+	if c.Scraper.County != "12" {
+		t.Errorf("Scraper.County = %q, want 12", c.Scraper.County)
+	}
+	if c.ListenAddr != Default().ListenAddr {
+		t.Errorf("ListenAddr = %q, want default", c.ListenAddr)
+	}
+}
+
+func Test_LoadMetricsAddr(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"scrape", nil, ":2112"},
+		{"web", nil, ":2113"},
+		{"migrate", nil, ""},
+		{"web", []string{"-metrics-listen", ":9100"}, ":9100"},
+		{"scrape", []string{"-metrics-listen", ""}, ""},
+	}
+	for _, tt := range tests {
+		c, err := Load(flag.NewFlagSet(tt.command, flag.ContinueOnError), tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.MetricsAddr != tt.want {
+			t.Errorf("%s %v: MetricsAddr = %q, want %q", tt.command, tt.args, c.MetricsAddr, tt.want)
+		}
+	}
+}
+
+func Test_LoadStaticProxies(t *testing.T) {
+	t.Setenv("TAX_PROXIES", " 10.0.0.1:8080, user:pass@10.0.0.2:8080 ,")
+	c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-proxy-source", "static"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1:8080", "user:pass@10.0.0.2:8080"}
+	if len(c.Proxies.Static) != len(want) || c.Proxies.Static[0] != want[0] || c.Proxies.Static[1] != want[1] {
+		t.Errorf("Proxies.Static = %q, want %q", c.Proxies.Static, want)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"sqlite with path", func(c *Config) { c.DB.Driver = "sqlite3"; c.DB.DSN = "./foo.db" }, false},
+		{"sqlite without path", func(c *Config) { c.DB.Driver = "sqlite3" }, true},
+		{"unknown driver", func(c *Config) { c.DB.Driver = "mysql" }, true},
+		{"no workers", func(c *Config) { c.Scraper.Workers = 0 }, true},
+		{"negative rate", func(c *Config) { c.Scraper.JobsPerSecond = -1 }, true},
+		{"county not numeric", func(c *Config) { c.Scraper.County = "bexar" }, true},
+		{"relative portal url", func(c *Config) { c.Scraper.PortalURL = "propaccess" }, true},
+		{"polite without contact", func(c *Config) { c.Politeness.Enabled = true }, true},
+		{"polite with contact", func(c *Config) {
+			c.Politeness.Enabled = true
+			c.Politeness.ContactUserAgent = "taxcollector/1.0 (+mailto:ops@example.com)"
+		}, false},
+		{"probe url", func(c *Config) { c.Proxies.ProbeURL = "https://example.com/ip" }, false},
+		{"relative probe url", func(c *Config) { c.Proxies.ProbeURL = "example.com" }, true},
+		{"static proxies", func(c *Config) { c.Proxies.Source = "static"; c.Proxies.Static = []string{"10.0.0.1:8080"} }, false},
+		{"static without proxies", func(c *Config) { c.Proxies.Source = "static" }, true},
+		{"unknown proxy source", func(c *Config) { c.Proxies.Source = "tor" }, true},
+		{"no probe concurrency", func(c *Config) { c.Proxies.ProbeConcurrency = 0 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_DataSourceName(t *testing.T) {
+	d := Default().DB
+	d.Password = `it's`
+	want := `host=127.0.0.1 port=5432 user=postgres dbname=tax sslmode=disable password='it\'s'`
+	if got := d.DataSourceName(); got != want {
+		t.Errorf("DataSourceName() = %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct{ dsn, want string }{
+		{"./tax.db", "./tax.db?_foreign_keys=on"},
+		{"file:tax.db?cache=shared", "file:tax.db?cache=shared&_foreign_keys=on"},
+		{"./tax.db?_fk=0", "./tax.db?_fk=0"},
+		{"./tax.db?_foreign_keys=off", "./tax.db?_foreign_keys=off"},
+	} {
+		d := DB{Driver: "sqlite3", DSN: tc.dsn}
+		if got := d.DataSourceName(); got != tc.want {
+			t.Errorf("sqlite3 DataSourceName(%q) = %q, want %q", tc.dsn, got, tc.want)
+		}
+	}
+}
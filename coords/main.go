@@ -1,14 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
 	"time"
 
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -65,43 +73,40 @@ type CoordsResponse struct {
 }
 
 func main() {
-	db, err := sql.Open("sqlite3", "../foo.db")
+	cfg, err := config.Load(flag.NewFlagSet("coords", flag.ExitOnError), os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	if cfg.Geocoder.MapQuestKey == "" {
+		log.Fatal(errors.New("no MapQuest key: set TAX_MAPQUEST_KEY or TAX_MAPQUEST_KEY_FILE"))
+	}
 
-	query := "select id, address from properties where (latitude is null OR longitude is null) and address != '' ;"
+	db, err := cfg.DB.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
 
-	propertyMap := make(map[string]string)
-	rows, err := db.Query(query)
+	pdb := pgdb.NewFor(db)
+	rows, err := pdb.ListUngeocodedProperties(context.Background())
 	if err != nil {
 		panic(err)
 	}
 
-	for rows.Next() {
-
-		var addr, id string
-		if err := rows.Scan(&id, &addr); err != nil {
-			panic(err)
-		}
-		fmt.Printf("id=%s   addr=%s\n", id, addr)
-
-		if addr == "" {
-			continue
-		}
-
-		propertyMap[id] = addr
+	propertyMap := make(map[int32]string)
+	for _, r := range rows {
+		fmt.Printf("id=%d   addr=%s\n", r.ID, r.Address.String)
+		propertyMap[r.ID] = r.Address.String
 	}
 
 	for propertyID, address := range propertyMap {
 		time.Sleep(800 * time.Millisecond)
 		c := http.DefaultClient
 
-		url := fmt.Sprintf(`http://open.mapquestapi.com/geocoding/v1/address?key=cOzLbu1ePZ5tWvTYrJjad4yXmasrr1mk&location=%s`, address)
+		reqURL := fmt.Sprintf(`http://open.mapquestapi.com/geocoding/v1/address?key=%s&location=%s`, url.QueryEscape(cfg.Geocoder.MapQuestKey), url.QueryEscape(address))
 
-		fmt.Println("url: ", url)
-		resp, err := c.Get(url)
+		fmt.Println("location: ", address)
+		resp, err := c.Get(reqURL)
 		if err != nil {
 			continue
 		}
@@ -129,10 +134,13 @@ func main() {
 			}
 		}
 
-		query := "update properties set latitude = $1, longitude = $2 where id = $3"
-		if _, err := db.Exec(query, lat, lon, propertyID); err != nil {
+		err = pdb.UpdatePropertyCoordinates(context.Background(), pgdb.UpdatePropertyCoordinatesParams{
+			Latitude:  sql.NullFloat64{Float64: lat, Valid: true},
+			Longitude: sql.NullFloat64{Float64: lon, Valid: true},
+			ID:        propertyID,
+		})
+		if err != nil {
 			fmt.Println(err)
-
 		}
 	}
 }
@@ -0,0 +1,239 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jason-costello/taxcollector/sink"
+	"github.com/jason-costello/taxcollector/storage"
+)
+
+// Formats lists the formats Open knows about.
+var Formats = []string{"csv", "jsonl", "geojson", "parquet"}
+
+// Writer receives the exported properties a page at a time.
+type Writer interface {
+	Write(ctx context.Context, props []storage.Property) error
+	Close() error
+}
+
+// Open returns a writer for format. out is the output file for jsonl and
+// geojson and the output directory for csv and parquet. csv and parquet
+// always write one file per table; flat makes jsonl do the same in one
+// stream, one row per line, and leaves the children out of geojson
+// features.
+func Open(format, out string, flat bool) (Writer, error) {
+	if out == "" {
+		return nil, fmt.Errorf("%s export needs an output path", format)
+	}
+	var (
+		rs  sink.PropertySink
+		err error
+	)
+	switch format {
+	case "csv":
+		rs, err = sink.NewCSV(out)
+	case "parquet":
+		rs, err = sink.NewParquet(out)
+	case "jsonl":
+		if flat {
+			rs, err = sink.NewFlatNDJSON(out)
+		} else {
+			rs, err = sink.NewNDJSON(out)
+		}
+	case "geojson":
+		return NewGeoJSON(out, flat)
+	default:
+		return nil, fmt.Errorf("unknown format %q, want one of %v", format, Formats)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return records{rs}, nil
+}
+
+// records writes the properties to one of the file sinks.
+type records struct {
+	rs sink.PropertySink
+}
+
+func (r records) Write(ctx context.Context, props []storage.Property) error {
+	return r.rs.WriteProperties(ctx, props)
+}
+
+func (r records) Close() error {
+	return r.rs.Close()
+}
+
+// Run pages through the properties matching f in id order, pageSize at a
+// time with their children, and hands each page to w, so memory use stays
+// flat however many properties match. f.Limit caps the total rather than
+// the page, and 0 exports everything. It returns how many properties it
+// wrote; w is left open.
+func Run(ctx context.Context, ps storage.PropertyStore, f storage.PropertyFilter, pageSize int, w Writer) (int, error) {
+	if pageSize < 1 {
+		pageSize = storage.DefaultLimit
+	}
+	total := 0
+	for {
+		page := f
+		page.Children = true
+		page.Limit = pageSize
+		if f.Limit > 0 && f.Limit-total < pageSize {
+			page.Limit = f.Limit - total
+		}
+		props, err := ps.List(ctx, page)
+		if err != nil {
+			return total, err
+		}
+		if len(props) == 0 {
+			return total, nil
+		}
+		if err := w.Write(ctx, props); err != nil {
+			return total, err
+		}
+		total += len(props)
+		if len(props) < page.Limit || (f.Limit > 0 && total >= f.Limit) {
+			return total, nil
+		}
+		last, err := strconv.ParseInt(props[len(props)-1].PropertyID, 10, 32)
+		if err != nil {
+			return total, fmt.Errorf("paging past property %q: %w", props[len(props)-1].PropertyID, err)
+		}
+		// The offset only skips ahead of the first page.
+		f.Offset = 0
+		f.AfterID = int32(last)
+	}
+}
+
+// GeoJSON writes a FeatureCollection with a point feature per property, or
+// a null geometry for properties that haven't been geocoded. The file is
+// only valid once Close has ended the collection.
+type GeoJSON struct {
+	f     *os.File
+	w     *bufio.Writer
+	flat  bool
+	count int
+}
+
+func NewGeoJSON(path string, flat bool) (*GeoJSON, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.WriteString(`{"type":"FeatureCollection","features":[` + "\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &GeoJSON{f: f, w: w, flat: flat}, nil
+}
+
+type feature struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	Geometry   *point      `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+type point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func (g *GeoJSON) Write(ctx context.Context, props []storage.Property) error {
+	for _, p := range props {
+		ft := feature{Type: "Feature", ID: p.PropertyID, Properties: p.PropertyRecord}
+		if p.Latitude.Valid && p.Longitude.Valid {
+			ft.Geometry = &point{Type: "Point", Coordinates: [2]float64{p.Longitude.Float64, p.Latitude.Float64}}
+		}
+		if g.flat {
+			fields, err := withoutChildren(p)
+			if err != nil {
+				return err
+			}
+			ft.Properties = fields
+		}
+		b, err := json.Marshal(ft)
+		if err != nil {
+			return err
+		}
+		if g.count > 0 {
+			b = append([]byte(",\n"), b...)
+		}
+		g.count++
+		if _, err := g.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return g.w.Flush()
+}
+
+func (g *GeoJSON) Close() error {
+	if _, err := g.w.WriteString("\n]}\n"); err != nil {
+		g.f.Close()
+		return err
+	}
+	if err := g.w.Flush(); err != nil {
+		g.f.Close()
+		return err
+	}
+	return g.f.Close()
+}
+
+// withoutChildren returns the fields of a property record as they appear in
+// JSON, less its child lists.
+func withoutChildren(p storage.Property) (map[string]interface{}, error) {
+	b, err := json.Marshal(p.PropertyRecord)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for _, k := range []string{"rollValue", "land", "improvements", "jurisdictions"} {
+		delete(m, k)
+	}
+	return m, nil
+}
+
+// ParseBoundingBox reads a box given as minLon,minLat,maxLon,maxLat, the
+// order GeoJSON uses.
+func ParseBoundingBox(s string) (*storage.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bounding box %q: want minLon,minLat,maxLon,maxLat", s)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bounding box %q: %w", s, err)
+		}
+		v[i] = f
+	}
+	b := &storage.BoundingBox{MinLongitude: v[0], MinLatitude: v[1], MaxLongitude: v[2], MaxLatitude: v[3]}
+	if b.MinLongitude > b.MaxLongitude || b.MinLatitude > b.MaxLatitude {
+		return nil, fmt.Errorf("bounding box %q: minimum above maximum", s)
+	}
+	return b, nil
+}
+
+// ParseSince reads an RFC 3339 time or a date, taken as midnight UTC.
+func ParseSince(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,281 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// pagedStore counts the pages Run asks for and the largest one.
+type pagedStore struct {
+	storage.PropertyStore
+	pages, largest int
+}
+
+func (p *pagedStore) List(ctx context.Context, f storage.PropertyFilter) ([]storage.Property, error) {
+	props, err := p.PropertyStore.List(ctx, f)
+	p.pages++
+	if len(props) > p.largest {
+		p.largest = len(props)
+	}
+	return props, err
+}
+
+func testStore(t *testing.T, n int) *storage.Memory {
+	t.Helper()
+	var records []tax.PropertyRecord
+	for i := 1; i <= n; i++ {
+		neighborhood := "Downtown"
+		if i%2 == 0 {
+			neighborhood = "Westfield"
+		}
+		records = append(records, tax.PropertyRecord{
+			PropertyID:   strconv.Itoa(i),
+			Neighborhood: neighborhood,
+			RollValue:    []tax.RollValue{{Year: "2020"}, {Year: "2021"}},
+		})
+	}
+	ms := storage.NewMemory()
+	if err := ms.Save(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	return ms
+}
+
+func readLines(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func Test_Run(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    storage.PropertyFilter
+		pageSize  int
+		wantCount int
+		wantPages int
+	}{
+		{"everything", storage.PropertyFilter{}, 10, 25, 3},
+		{"exact pages", storage.PropertyFilter{}, 5, 25, 6},
+		{"filtered", storage.PropertyFilter{Neighborhood: "westfield"}, 5, 12, 3},
+		{"limited", storage.PropertyFilter{Limit: 7}, 5, 7, 2},
+		{"offset", storage.PropertyFilter{Offset: 20}, 10, 5, 1},
+		{"no match", storage.PropertyFilter{Neighborhood: "Oak Hills"}, 10, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := &pagedStore{PropertyStore: testStore(t, 25)}
+			path := filepath.Join(t.TempDir(), "out.jsonl")
+			w, err := Open("jsonl", path, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := Run(context.Background(), ps, tt.filter, tt.pageSize, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			lines := readLines(t, path)
+			if n != tt.wantCount || len(lines) != tt.wantCount {
+				t.Errorf("exported %d, wrote %d lines, want %d", n, len(lines), tt.wantCount)
+			}
+			if ps.pages != tt.wantPages || ps.largest > tt.pageSize {
+				t.Errorf("%d pages of up to %d, want %d of up to %d", ps.pages, ps.largest, tt.wantPages, tt.pageSize)
+			}
+			seen := map[string]bool{}
+			for _, l := range lines {
+				id, _ := l["propertyID"].(string)
+				if seen[id] {
+					t.Errorf("property %s exported twice", id)
+				}
+				seen[id] = true
+				if rv, _ := l["rollValue"].([]interface{}); len(rv) != 2 {
+					t.Errorf("property %s exported with roll values %v", id, l["rollValue"])
+				}
+			}
+		})
+	}
+}
+
+func Test_GeoJSON(t *testing.T) {
+	props := []storage.Property{
+		{
+			PropertyRecord: tax.PropertyRecord{PropertyID: "1", RollValue: []tax.RollValue{{Year: "2021"}}},
+			Latitude:       sql.NullFloat64{Float64: 32.5, Valid: true},
+			Longitude:      sql.NullFloat64{Float64: -97.25, Valid: true},
+		},
+		{PropertyRecord: tax.PropertyRecord{PropertyID: "2"}},
+	}
+	for _, flat := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "out.geojson")
+		w, err := Open("geojson", path, flat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Two pages, to check features are separated across writes.
+		for _, p := range props {
+			if err := w.Write(context.Background(), []storage.Property{p}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fc struct {
+			Type     string
+			Features []struct {
+				ID       string
+				Geometry *struct {
+					Type        string
+					Coordinates []float64
+				}
+				Properties map[string]interface{}
+			}
+		}
+		if err := json.Unmarshal(b, &fc); err != nil {
+			t.Fatalf("flat=%v: %v\n%s", flat, err, b)
+		}
+		if fc.Type != "FeatureCollection" || len(fc.Features) != 2 {
+			t.Fatalf("flat=%v: %s", flat, b)
+		}
+		f1, f2 := fc.Features[0], fc.Features[1]
+		if f1.ID != "1" || f1.Geometry == nil || f1.Geometry.Type != "Point" ||
+			f1.Geometry.Coordinates[0] != -97.25 || f1.Geometry.Coordinates[1] != 32.5 {
+			t.Errorf("flat=%v: first feature %+v", flat, f1)
+		}
+		if f2.Geometry != nil {
+			t.Errorf("flat=%v: feature without coordinates has geometry %+v", flat, f2.Geometry)
+		}
+		if _, ok := f1.Properties["rollValue"]; ok == flat {
+			t.Errorf("flat=%v: properties %v", flat, f1.Properties)
+		}
+	}
+}
+
+func Test_ParseFilters(t *testing.T) {
+	b, err := ParseBoundingBox("-97.5, 32, -97, 32.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *b != (storage.BoundingBox{MinLongitude: -97.5, MinLatitude: 32, MaxLongitude: -97, MaxLatitude: 32.5}) {
+		t.Errorf("ParseBoundingBox = %+v", b)
+	}
+	for _, bad := range []string{"1,2,3", "a,b,c,d", "-97,32,-97.5,32.5"} {
+		if _, err := ParseBoundingBox(bad); err == nil {
+			t.Errorf("ParseBoundingBox(%q) succeeded", bad)
+		}
+	}
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2021-03-04", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"2021-03-04T05:06:07Z", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got, err := ParseSince(tt.in); err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+	if _, err := ParseSince("yesterday"); err == nil {
+		t.Error("ParseSince(yesterday) succeeded")
+	}
+}
+
+func Test_StoredFields(t *testing.T) {
+	scraped := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	props := []storage.Property{{
+		PropertyRecord: tax.PropertyRecord{PropertyID: "1"},
+		LastScrapedAt:  scraped,
+		Street:         "MAIN ST",
+		Latitude:       sql.NullFloat64{Float64: 32.5, Valid: true},
+		Longitude:      sql.NullFloat64{Float64: -97.25, Valid: true},
+		Occupancy:      occupancy.OutOfState,
+	}}
+	want := map[string]string{
+		"street":          "MAIN ST",
+		"latitude":        "32.5",
+		"longitude":       "-97.25",
+		"last_scraped_at": "2022-05-01T12:00:00Z",
+		"occupancy":       "out-of-state",
+	}
+	write := func(format, out string, flat bool) {
+		w, err := Open(format, out, flat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(context.Background(), props); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	write("csv", dir, false)
+	f, err := os.Open(filepath.Join(dir, "properties.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("properties.csv = %v, %v", rows, err)
+	}
+	for i, col := range rows[0] {
+		if v, ok := want[col]; ok && rows[1][i] != v {
+			t.Errorf("csv %s = %q, want %q", col, rows[1][i], v)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "flat.jsonl")
+	write("jsonl", path, true)
+	line := readLines(t, path)[0]
+	for col, v := range want {
+		if line[col] != v {
+			t.Errorf("flat jsonl %s = %v, want %q", col, line[col], v)
+		}
+	}
+
+	path = filepath.Join(t.TempDir(), "nested.jsonl")
+	write("jsonl", path, false)
+	line = readLines(t, path)[0]
+	if line["street"] != "MAIN ST" || line["latitude"] != 32.5 || line["longitude"] != -97.25 ||
+		line["lastScrapedAt"] != "2022-05-01T12:00:00Z" || line["occupancy"] != "out-of-state" || line["propertyID"] != "1" {
+		t.Errorf("nested jsonl = %v", line)
+	}
+}
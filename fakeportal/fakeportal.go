@@ -0,0 +1,262 @@
+package fakeportal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault is a failure the portal can inject into a detail page response.
+type Fault int
+
+const (
+	FaultNone Fault = iota
+	// FaultTimeout holds the request open for Options.Delay before answering.
+	FaultTimeout
+	FaultForbidden
+	FaultTooManyRequests
+	// FaultTruncated serves only the first half of the fixture.
+	FaultTruncated
+	// FaultSessionExpired drops the caller's session, as the real portal does
+	// when a session sits idle, and redirects back to the county landing page.
+	FaultSessionExpired
+)
+
+var faultNames = map[Fault]string{
+	FaultNone:            "none",
+	FaultTimeout:         "timeout",
+	FaultForbidden:       "403",
+	FaultTooManyRequests: "429",
+	FaultTruncated:       "truncated",
+	FaultSessionExpired:  "expired",
+}
+
+func (f Fault) String() string {
+	if n, ok := faultNames[f]; ok {
+		return n
+	}
+	return "fault(" + strconv.Itoa(int(f)) + ")"
+}
+
+func ParseFault(s string) (Fault, error) {
+	for f, n := range faultNames {
+		if n == s {
+			return f, nil
+		}
+	}
+	return FaultNone, fmt.Errorf("unknown fault %q", s)
+}
+
+const sessionCookie = "ASP.NET_SessionId"
+
+type Options struct {
+	// FixturesDir holds detail pages named <prop_id>.html, e.g. test_data/.
+	FixturesDir string
+	// ClientID is the county cid the portal answers to. Defaults to "56".
+	ClientID string
+	// Delay is how long FaultTimeout stalls a request. Defaults to one minute.
+	Delay time.Duration
+	// SessionRequests expires a session after this many detail requests.
+	// Zero means sessions never expire on their own.
+	SessionRequests int
+	// FaultRates injects faults at random with the given probability per
+	// detail request, on top of any queued with InjectFault.
+	FaultRates map[Fault]float64
+	Seed       int64
+	// Robots is served as /robots.txt. Empty means the portal has none.
+	Robots string
+}
+
+type Portal struct {
+	opts     Options
+	mu       sync.Mutex
+	rnd      *mrand.Rand
+	sessions map[string]int
+	faults   map[string][]Fault
+	hits     map[string]int
+}
+
+func New(opts Options) *Portal {
+	if opts.ClientID == "" {
+		opts.ClientID = "56"
+	}
+	if opts.Delay == 0 {
+		opts.Delay = time.Minute
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	return &Portal{
+		opts:     opts,
+		rnd:      mrand.New(mrand.NewSource(opts.Seed)),
+		sessions: make(map[string]int),
+		faults:   make(map[string][]Fault),
+		hits:     make(map[string]int),
+	}
+}
+
+// NewServer starts the portal on an httptest.Server. Close the server when done.
+func NewServer(opts Options) (*Portal, *httptest.Server) {
+	p := New(opts)
+	return p, httptest.NewServer(p)
+}
+
+// InjectFault queues faults for a property. Each detail request for propID
+// consumes the next one; use "*" to target whichever property comes next.
+func (p *Portal) InjectFault(propID string, faults ...Fault) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.faults[propID] = append(p.faults[propID], faults...)
+}
+
+// ExpireSessions forgets every session, forcing clients to warm up again.
+func (p *Portal) ExpireSessions() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions = make(map[string]int)
+}
+
+// Hits reports how many requests the portal has answered for a path,
+// "/clientdb/" for warm-ups and "/clientdb/Property.aspx" for detail pages.
+func (p *Portal) Hits(path string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hits[path]
+}
+
+func (p *Portal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.hits[r.URL.Path]++
+	p.mu.Unlock()
+
+	if r.URL.Path == "/robots.txt" {
+		if p.opts.Robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, p.opts.Robots)
+		return
+	}
+
+	if r.URL.Query().Get("cid") != p.opts.ClientID {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/clientdb/", "/clientdb":
+		p.serveLanding(w, r)
+	case "/clientdb/Property.aspx":
+		p.serveProperty(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *Portal) serveLanding(w http.ResponseWriter, r *http.Request) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session := hex.EncodeToString(id)
+
+	p.mu.Lock()
+	p.sessions[session] = 0
+	p.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: session, Path: "/", HttpOnly: true})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<html><body><form id=\"propertySearch\" action=\"SearchResults.aspx?cid=%s\"></form></body></html>", p.opts.ClientID)
+}
+
+func (p *Portal) serveProperty(w http.ResponseWriter, r *http.Request) {
+	propID := r.URL.Query().Get("prop_id")
+	if _, err := strconv.Atoi(propID); err != nil {
+		http.Error(w, "invalid prop_id", http.StatusBadRequest)
+		return
+	}
+
+	fault := p.nextFault(propID)
+
+	session := ""
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		session = c.Value
+	}
+	if !p.useSession(session, fault == FaultSessionExpired) {
+		http.Redirect(w, r, "/clientdb/?cid="+p.opts.ClientID, http.StatusFound)
+		return
+	}
+
+	switch fault {
+	case FaultTimeout:
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(p.opts.Delay):
+		}
+	case FaultForbidden:
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	case FaultTooManyRequests:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	page, err := os.ReadFile(filepath.Join(p.opts.FixturesDir, propID+".html"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if fault == FaultTruncated {
+		page = page[:len(page)/2]
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// useSession counts a detail request against the session and reports whether
+// it is still valid.
+func (p *Portal) useSession(session string, expire bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n, ok := p.sessions[session]
+	if !ok {
+		return false
+	}
+	if expire || (p.opts.SessionRequests > 0 && n >= p.opts.SessionRequests) {
+		delete(p.sessions, session)
+		return false
+	}
+	p.sessions[session] = n + 1
+	return true
+}
+
+func (p *Portal) nextFault(propID string) Fault {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, key := range []string{propID, "*"} {
+		if q := p.faults[key]; len(q) > 0 {
+			p.faults[key] = q[1:]
+			return q[0]
+		}
+	}
+	for f := FaultTimeout; f <= FaultSessionExpired; f++ {
+		if rate, ok := p.opts.FaultRates[f]; ok && p.rnd.Float64() < rate {
+			return f
+		}
+	}
+	return FaultNone
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
@@ -14,34 +16,50 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/mux"
+	"github.com/jason-costello/taxcollector/api"
+	"github.com/jason-costello/taxcollector/config"
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/politeness"
 	"github.com/jason-costello/taxcollector/proxies"
 	"github.com/jason-costello/taxcollector/scraper"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate"
 	"github.com/jason-costello/taxcollector/storage/pgdb"
 	"github.com/jason-costello/taxcollector/useragents"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var taxDB *pgdb.Queries
+var (
+	taxDB         *pgdb.Queries
+	propertyStore storage.PropertyStore
+)
 
 func main() {
-	db, err := sql.Open("sqlite3", "./foo.db")
+	cfg, err := config.Load(flag.NewFlagSet("taxcollector", flag.ExitOnError), os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := cfg.DB.Open()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	taxDB = pgdb.New(db)
+	taxDB = pgdb.NewFor(db)
+	store := storage.NewSQL(db)
+	propertyStore = store
 	defer db.Close()
+	if err := migrate.Check(context.Background(), db, cfg.DB.Driver); err != nil {
+		log.Fatal(err)
+	}
 
 	handler := web.NewHandler(taxDB)
 
-	r := mux.NewRouter()
-	v1ApiRouter := r.PathPrefix("/v1/api").Subrouter()
-	v1ApiRouter.HandleFunc("/property/{id}", handler.GetProperty)
-
+	r := api.NewRouter(db)
 	r.HandleFunc("/version", handler.Version)
+	r.Handle("/metrics", metrics.Handler())
 
-	http.ListenAndServe(":8888", r)
+	http.ListenAndServe(cfg.ListenAddr, r)
 }
 
 func getPropertyByID(c *gin.Context) {
@@ -53,7 +71,11 @@ func getPropertyByID(c *gin.Context) {
 		c.Status(407)
 	}
 
-	property, err := taxDB.GetPropertyByID(context.Background(), int32(i))
+	property, err := propertyStore.Property(context.Background(), int32(i))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.Status(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		c.Error(err)
 		c.Status(407)
@@ -61,25 +83,27 @@ func getPropertyByID(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"data": property})
 }
-func loadScraper() (*scraper.Scraper, error) {
-
-	db, err := sql.Open("sqlite3", "./foo.db")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
+func loadScraper(cfg *config.Config, db *sql.DB) (*scraper.Scraper, error) {
 
 	pc := proxies.NewProxyClient(db)
 
-	ua := useragents.UserAgentClient{}
-	if err := ua.LoadUserAgents("useragents.txt"); err != nil {
-		log.Println(err)
-		os.Exit(1)
+	ua, err := useragents.New(cfg.Scraper.UserAgent, cfg.Scraper.UserAgentFile)
+	if err != nil {
+		return nil, err
 	}
 
 	hc := http.DefaultClient
 
-	scraper := scraper.NewScraper(pc, &ua, db, hc)
+	scraper := scraper.NewScraper(pc, ua, db, hc)
+	scraper.SetPortal(cfg.Scraper.PortalURL, cfg.Scraper.County)
+	scraper.SetWorkers(cfg.Scraper.Workers)
+	scraper.SetRateLimit(cfg.Scraper.JobsPerSecond, cfg.Scraper.WorkerDelay.Duration)
+	scraper.SetPipeline(cfg.Scraper.Parsers, cfg.Scraper.BatchSize, cfg.Scraper.FlushInterval.Duration)
+	if cfg.Politeness.Enabled {
+		pol := politeness.New(cfg.Politeness.ContactUserAgent, cfg.Politeness.DailyBudget)
+		pol.SetDB(db)
+		scraper.SetPoliteness(pol)
+	}
 
 	return scraper, nil
 
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "taxcollector"
+
+var (
+	ScrapeRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "requests_total",
+		Help:      "HTTP requests made to the appraisal portal by stage and status code.",
+	}, []string{"stage", "status"})
+
+	ParseFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "parse_failures_total",
+		Help:      "Property detail pages that could not be parsed.",
+	})
+
+	DBInsertFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "db_insert_failures_total",
+		Help:      "Property records that failed to be written to the database.",
+	})
+
+	ProxyCooldowns = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "proxy_cooldowns_total",
+		Help:      "Proxies benched for a cooldown after a failed request.",
+	})
+
+	ProxyProbes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "proxy_probes_total",
+		Help:      "Background proxy health checks by result.",
+	}, []string{"result"})
+
+	PendingURLs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "pending_urls",
+		Help:      "URLs remaining in the pending_urls queue.",
+	})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scraper",
+		Name:      "job_duration_seconds",
+		Help:      "Time taken to process a single property job.",
+		Buckets:   []float64{.25, .5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"result"})
+
+	APIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of API requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Handler returns the http.Handler that serves the /metrics endpoint.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes /metrics on its own listener. It blocks, so callers usually
+// run it in a goroutine alongside the scraper or API server.
+func Serve(addr string) error {
+	m := http.NewServeMux()
+	m.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, m)
+}
+
+// ObserveRequest records the status of a portal request. A nil response is
+// counted as an "error" so transport failures show up alongside HTTP codes.
+func ObserveRequest(stage string, resp *http.Response) {
+	status := "error"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	ScrapeRequests.WithLabelValues(stage, status).Inc()
+}
+
+// ObserveJob records how long a job took and whether it succeeded.
+func ObserveJob(start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	JobDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware times every request passing through a mux router. Requests are
+// labelled with the route template rather than the raw path so that property
+// IDs don't explode the label cardinality.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tmpl, err := cr.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+		APIRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,151 @@
+package occupancy
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Class says where a property's owner gets their mail relative to the
+// property itself.
+type Class string
+
+const (
+	// OwnerOccupied properties are mailed at their own address.
+	OwnerOccupied Class = "owner-occupied"
+	// LocalAbsentee owners are mailed elsewhere in the county. Owners using
+	// a local PO box land here too, occupied or not.
+	LocalAbsentee Class = "local-absentee"
+	OutOfCounty   Class = "out-of-county"
+	OutOfState    Class = "out-of-state"
+	// Unknown is for addresses too incomplete to compare.
+	Unknown Class = "unknown"
+)
+
+// Classes lists every class, in the order above.
+var Classes = []Class{OwnerOccupied, LocalAbsentee, OutOfCounty, OutOfState, Unknown}
+
+// Address is a postal address broken into the parts the comparison uses,
+// normalised so that the portal's spellings of one address compare equal.
+type Address struct {
+	// Street is the delivery line, with any unit or suite left off.
+	Street string
+	// Unit is what follows the unit designator, so "APT 2" and "#2" are
+	// both "2".
+	Unit  string
+	City  string
+	State string
+	// ZIP is the five digit ZIP code; ZIP+4 extensions are dropped.
+	ZIP string
+}
+
+// lastLine matches "NEW BRAUNFELS, TX 78130-5046".
+var lastLine = regexp.MustCompile(`^(.*?),?\s+([A-Z]{2})\s+(\d{5})(?:-?\d{4})?$`)
+
+// Parse reads an address the way the portal prints them: one line per part,
+// delivery lines first and "CITY, ST ZIP" last. Care-of and attention lines
+// are skipped. An address without a recognisable last line is taken to be
+// all delivery lines.
+func Parse(s string) Address {
+	var lines []string
+	for _, l := range strings.Split(strings.ToUpper(s), "\n") {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" || strings.HasPrefix(l, "C/O") || strings.HasPrefix(l, "ATTN") || strings.HasPrefix(l, "%") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	var a Address
+	if n := len(lines); n > 0 {
+		if m := lastLine.FindStringSubmatch(lines[n-1]); m != nil {
+			a.City, a.State, a.ZIP = normalize(m[1]), m[2], m[3]
+			lines = lines[:n-1]
+		}
+	}
+	a.Street, a.Unit = splitUnit(strings.Join(lines, " "))
+	return a
+}
+
+// abbreviations are the USPS standard abbreviations for common street
+// suffixes and directions.
+var abbreviations = map[string]string{
+	"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+	"NORTHEAST": "NE", "NORTHWEST": "NW", "SOUTHEAST": "SE", "SOUTHWEST": "SW",
+	"AVENUE": "AVE", "AV": "AVE", "BOULEVARD": "BLVD", "CIRCLE": "CIR", "COURT": "CT",
+	"DRIVE": "DR", "HIGHWAY": "HWY", "LANE": "LN", "PARKWAY": "PKWY", "PLACE": "PL",
+	"ROAD": "RD", "STREET": "ST", "TERRACE": "TER", "TRAIL": "TRL", "CROSSING": "XING",
+	"COVE": "CV",
+}
+
+// units start the part of a delivery line that names a unit within the
+// building. Spellings of an address disagree on the designator more often
+// than on the unit itself.
+var units = map[string]bool{
+	"APT": true, "APARTMENT": true, "UNIT": true, "STE": true, "SUITE": true,
+	"BLDG": true, "BUILDING": true, "RM": true, "ROOM": true, "SPC": true, "SPACE": true,
+}
+
+var punctuation = regexp.MustCompile(`[^A-Z0-9 #/]+`)
+
+func normalize(s string) string {
+	s, _ = splitUnit(s)
+	return s
+}
+
+// splitUnit normalises a delivery line and splits off the unit, if it
+// names one.
+func splitUnit(s string) (street, unit string) {
+	fields := strings.Fields(punctuation.ReplaceAllString(strings.ToUpper(s), " "))
+	var words []string
+	for i, w := range fields {
+		if units[w] || strings.HasPrefix(w, "#") {
+			if units[w] {
+				i++
+			}
+			unit = strings.Join(strings.Fields(strings.ReplaceAll(strings.Join(fields[i:], " "), "#", " ")), " ")
+			break
+		}
+		if a, ok := abbreviations[w]; ok {
+			w = a
+		}
+		words = append(words, w)
+	}
+	street = strings.Join(words, " ")
+	for _, box := range []string{"POST OFFICE BOX", "P O BOX", "POB"} {
+		if strings.HasPrefix(street, box+" ") {
+			street = "PO BOX" + strings.TrimPrefix(street, box)
+		}
+	}
+	return street, unit
+}
+
+// Classify compares a property's situs address with its owner's mailing
+// address. countyZIPs holds the ZIP codes that lie in the property's
+// county; a mailing address elsewhere in the state is out of the county.
+func Classify(situs, mailing string, countyZIPs map[string]bool) Class {
+	s, m := Parse(situs), Parse(mailing)
+	switch {
+	case s.Street == "" || m.Street == "":
+		return Unknown
+	case s.Street == m.Street && (s.ZIP == "" || m.ZIP == "" || s.ZIP == m.ZIP) &&
+		(s.Unit == "" || m.Unit == "" || s.Unit == m.Unit):
+		return OwnerOccupied
+	case s.State == "" || m.State == "":
+		return Unknown
+	case s.State != m.State:
+		return OutOfState
+	case countyZIPs[m.ZIP]:
+		return LocalAbsentee
+	default:
+		return OutOfCounty
+	}
+}
+
+// Valid reports whether c is one of Classes.
+func (c Class) Valid() bool {
+	for _, v := range Classes {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
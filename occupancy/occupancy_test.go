@@ -0,0 +1,54 @@
+package occupancy
+
+import "testing"
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Address
+	}{
+		{"1001 ASSEMBLY CIR\n   \n   SCHERTZ, TX 78154", Address{"1001 ASSEMBLY CIR", "", "SCHERTZ", "TX", "78154"}},
+		{"C/O THE ALBANO GROUP\n\nPO BOX 1240\n\nMANCHESTER, NH 03105-1240", Address{"PO BOX 1240", "", "MANCHESTER", "NH", "03105"}},
+		{"403 Magazine Avenue, Apt. 2\nNew Braunfels TX 781305046", Address{"403 MAGAZINE AVE", "2", "NEW BRAUNFELS", "TX", "78130"}},
+		{"12 North Oak Street #B\nAustin, TX 78701", Address{"12 N OAK ST", "B", "AUSTIN", "TX", "78701"}},
+		{"P.O. Box 9\nBoerne, TX 78006", Address{"PO BOX 9", "", "BOERNE", "TX", "78006"}},
+		{"403 MAGAZINE AVE", Address{Street: "403 MAGAZINE AVE"}},
+		{"9 ELM ST STE # 100 B", Address{Street: "9 ELM ST", Unit: "100 B"}},
+		{"", Address{}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.in); got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_Classify(t *testing.T) {
+	county := map[string]bool{"78130": true, "78132": true, "78154": true}
+	situs := "403 MAGAZINE AVE\nNEW BRAUNFELS, TX 78130"
+	tests := []struct {
+		name    string
+		situs   string
+		mailing string
+		want    Class
+	}{
+		{"same address", situs, "403 Magazine Avenue\nNew Braunfels, TX 78130-5046", OwnerOccupied},
+		{"unit on the mailing address only", situs, "403 MAGAZINE AVE APT 1\nNEW BRAUNFELS, TX 78130", OwnerOccupied},
+		{"same unit", "403 MAGAZINE AVE APT 2\nNEW BRAUNFELS, TX 78130", "403 Magazine Ave #2\nNew Braunfels, TX 78130", OwnerOccupied},
+		{"same address, other unit", "403 MAGAZINE AVE APT 2\nNEW BRAUNFELS, TX 78130", "403 MAGAZINE AVE APT 1\nNEW BRAUNFELS, TX 78130", LocalAbsentee},
+		{"situs without city", "403 MAGAZINE AVE", "403 MAGAZINE AVE\nNEW BRAUNFELS, TX 78130", OwnerOccupied},
+		{"same street, other zip", situs, "403 MAGAZINE AVE\nSAN MARCOS, TX 78666", OutOfCounty},
+		{"elsewhere in the county", situs, "254 E MILL ST\nNEW BRAUNFELS, TX 78130-5046", LocalAbsentee},
+		{"local po box", situs, "PO BOX 311\nNEW BRAUNFELS, TX 78132", LocalAbsentee},
+		{"elsewhere in the state", situs, "1 CONGRESS AVE\nAUSTIN, TX 78701", OutOfCounty},
+		{"out of state", situs, "C/O THE ALBANO GROUP\nPO BOX 1240\nMANCHESTER, NH 03105-1240", OutOfState},
+		{"no mailing address", situs, "", Unknown},
+		{"mailing address without state", situs, "254 E MILL ST", Unknown},
+		{"situs without state", "403 MAGAZINE AVE", "254 E MILL ST\nNEW BRAUNFELS, TX 78130", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.situs, tt.mailing, county); got != tt.want {
+			t.Errorf("%s: Classify(%q, %q) = %s, want %s", tt.name, tt.situs, tt.mailing, got, tt.want)
+		}
+	}
+}
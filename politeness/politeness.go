@@ -0,0 +1,213 @@
+package politeness
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+)
+
+var (
+	ErrDisallowed      = errors.New("disallowed by robots.txt")
+	ErrBudgetExhausted = errors.New("daily request budget exhausted")
+	ErrNoRobots        = errors.New("robots.txt has not been loaded")
+)
+
+// robotsTTL is how long a fetched robots.txt is trusted before it is fetched
+// again, the upper bound RFC 9309 suggests.
+const robotsTTL = 24 * time.Hour
+
+// Policy makes a crawler behave: it checks every request against
+// robots.txt, spaces requests at least Crawl-delay apart across all workers
+// and refuses to go over a daily request budget. The budget is counted in
+// memory, per process, unless SetDB shares it through the database.
+type Policy struct {
+	userAgent   string
+	dailyBudget int
+	now         func() time.Time
+	pdb         *pgdb.Queries
+
+	mu        sync.Mutex
+	rules     *Rules
+	fetchedAt time.Time
+	next      time.Time
+	day       string
+	used      int
+}
+
+// New returns a policy that identifies the crawler as userAgent, which should
+// carry a way to reach its operator, e.g.
+// "taxcollector/1.0 (+mailto:ops@example.com)". A dailyBudget of zero means
+// no limit.
+func New(userAgent string, dailyBudget int) *Policy {
+	return &Policy{
+		userAgent:   userAgent,
+		dailyBudget: dailyBudget,
+		now:         time.Now,
+	}
+}
+
+func (p *Policy) UserAgent() string {
+	return p.userAgent
+}
+
+// SetDB counts the daily budget in db's request_budget table, so that every
+// process crawling against db shares one budget and a restart doesn't reset
+// it.
+func (p *Policy) SetDB(db *sql.DB) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pdb = pgdb.NewFor(db)
+}
+
+// SetRules installs robots.txt rules directly instead of fetching them.
+func (p *Policy) SetRules(r *Rules) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = r
+	p.fetchedAt = p.now()
+}
+
+// LoadRobots fetches robots.txt from the host of baseURL unless a copy
+// younger than a day is already loaded. A missing robots.txt allows
+// everything; a server error disallows everything until the next attempt.
+func (p *Policy) LoadRobots(ctx context.Context, hc *http.Client, baseURL string) error {
+	p.mu.Lock()
+	fresh := p.rules != nil && p.now().Sub(p.fetchedAt) < robotsTTL
+	p.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	robotsURL := u.Scheme + "://" + u.Host + "/robots.txt"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", robotsURL, err)
+	}
+	defer resp.Body.Close()
+
+	var rules *Rules
+	switch {
+	case resp.StatusCode >= 500:
+		rules = DisallowAll
+	case resp.StatusCode >= 400:
+		rules = AllowAll
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		rules, err = ParseRobots(resp.Body, p.userAgent)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", robotsURL, err)
+		}
+	default:
+		return fmt.Errorf("fetching %s: %s", robotsURL, resp.Status)
+	}
+	p.SetRules(rules)
+	return nil
+}
+
+// Wait blocks until u may be requested and counts the request against the
+// daily budget. It fails without waiting if robots.txt disallows u or the
+// budget is spent.
+func (p *Policy) Wait(ctx context.Context, u *url.URL) error {
+	p.mu.Lock()
+	if p.rules == nil {
+		p.mu.Unlock()
+		return ErrNoRobots
+	}
+	if !p.rules.Allowed(u.RequestURI()) {
+		p.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrDisallowed, u.RequestURI())
+	}
+
+	now := p.now()
+	if ok, err := p.spend(ctx, now.Format("2006-01-02")); err != nil || !ok {
+		p.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("counting request budget: %w", err)
+		}
+		return ErrBudgetExhausted
+	}
+
+	// Reserve the next slot before sleeping so concurrent callers queue up
+	// behind each other instead of all waking at once.
+	wait := p.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	p.next = now.Add(wait + p.rules.CrawlDelay)
+	p.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// spend counts a request against day's budget, reporting false if it is
+// already spent. The caller holds p.mu.
+func (p *Policy) spend(ctx context.Context, day string) (bool, error) {
+	if p.dailyBudget <= 0 {
+		return true, nil
+	}
+	if p.pdb == nil {
+		if day != p.day {
+			p.day, p.used = day, 0
+		}
+		if p.used >= p.dailyBudget {
+			return false, nil
+		}
+		p.used++
+		return true, nil
+	}
+	if err := p.pdb.AddRequestBudgetDay(ctx, day); err != nil {
+		return false, err
+	}
+	n, err := p.pdb.SpendRequestBudget(ctx, pgdb.SpendRequestBudgetParams{Day: day, Budget: int32(p.dailyBudget)})
+	return n > 0, err
+}
+
+// Remaining reports how many requests are left in today's budget, or -1 if
+// there is no budget.
+func (p *Policy) Remaining(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dailyBudget <= 0 {
+		return -1, nil
+	}
+	day := p.now().Format("2006-01-02")
+	if p.pdb == nil {
+		if day != p.day {
+			return p.dailyBudget, nil
+		}
+		return p.dailyBudget - p.used, nil
+	}
+	used, err := p.pdb.GetRequestBudgetUsed(ctx, day)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p.dailyBudget, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return p.dailyBudget - int(used), nil
+}
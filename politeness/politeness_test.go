@@ -0,0 +1,191 @@
+package politeness
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+)
+
+const testRobots = `# portal robots
+User-agent: *
+Disallow: /clientdb/SearchResults.aspx
+Disallow: /*.pdf$
+Crawl-delay: 5
+
+User-agent: badbot
+Disallow: /
+
+User-agent: tax
+Disallow: /clientdb/Property.aspx?cid=56&prop_id=2
+
+User-agent: taxcollector
+User-agent: othercrawler
+Allow: /clientdb/Property.aspx
+Disallow: /clientdb/
+Crawl-delay: 0.5
+`
+
+func Test_ParseRobots(t *testing.T) {
+	tests := []struct {
+		agent     string
+		path      string
+		want      bool
+		wantDelay time.Duration
+	}{
+		{"Mozilla/5.0", "/clientdb/Property.aspx?cid=56&prop_id=1", true, 5 * time.Second},
+		{"Mozilla/5.0", "/clientdb/SearchResults.aspx?cid=56", false, 5 * time.Second},
+		{"Mozilla/5.0", "/docs/notice.pdf", false, 5 * time.Second},
+		{"Mozilla/5.0", "/docs/notice.pdf?x=1", true, 5 * time.Second},
+		{"BadBot/2.1", "/clientdb/", false, 0},
+		{"taxcollector/1.0 (+mailto:ops@example.com)", "/clientdb/Property.aspx?cid=56&prop_id=1", true, 500 * time.Millisecond},
+		{"taxcollector/1.0 (+mailto:ops@example.com)", "/clientdb/?cid=56", false, 500 * time.Millisecond},
+		{"taxcollector/1.0", "/robots.txt", true, 500 * time.Millisecond},
+		{"TaxCollector/1.0", "/clientdb/Property.aspx?cid=56&prop_id=1", true, 500 * time.Millisecond},
+		{"taxcollector/1.0", "/clientdb/Property.aspx?cid=56&prop_id=2", true, 500 * time.Millisecond},
+		{"tax/1.0", "/clientdb/Property.aspx?cid=56&prop_id=2", false, 0},
+		{"tax/1.0", "/clientdb/Property.aspx?cid=56&prop_id=1", true, 0},
+		{"collector/1.0", "/clientdb/Property.aspx?cid=56&prop_id=1", true, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.agent+" "+tt.path, func(t *testing.T) {
+			r, err := ParseRobots(strings.NewReader(testRobots), tt.agent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if r.CrawlDelay != tt.wantDelay {
+				t.Errorf("CrawlDelay = %s, want %s", r.CrawlDelay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func Test_LoadRobots(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		allowed bool
+	}{
+		{name: "rules", status: http.StatusOK, body: "User-agent: *\nDisallow: /clientdb/\n", allowed: false},
+		{name: "missing", status: http.StatusNotFound, allowed: true},
+		{name: "server error", status: http.StatusServiceUnavailable, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUA string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUA = r.UserAgent()
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := New("taxcollector/1.0 (+mailto:ops@example.com)", 0)
+			if err := p.LoadRobots(context.Background(), srv.Client(), srv.URL+"/clientdb"); err != nil {
+				t.Fatal(err)
+			}
+			if gotUA != p.UserAgent() {
+				t.Errorf("robots.txt fetched as %q", gotUA)
+			}
+			u, _ := url.Parse(srv.URL + "/clientdb/Property.aspx?prop_id=1")
+			err := p.Wait(context.Background(), u)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Wait() error = %v, allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func Test_WaitBudget(t *testing.T) {
+	now := time.Date(2022, 5, 1, 23, 0, 0, 0, time.UTC)
+	p := New("taxcollector/1.0", 2)
+	p.now = func() time.Time { return now }
+	p.SetRules(AllowAll)
+
+	u, _ := url.Parse("https://example.com/clientdb/Property.aspx?prop_id=1")
+	for i := 0; i < 2; i++ {
+		if err := p.Wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Wait(context.Background(), u); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("third request error = %v, want ErrBudgetExhausted", err)
+	}
+	if got, err := p.Remaining(context.Background()); err != nil || got != 0 {
+		t.Errorf("Remaining() = %d, %v, want 0", got, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if got, err := p.Remaining(context.Background()); err != nil || got != 2 {
+		t.Errorf("Remaining() next day = %d, %v, want 2", got, err)
+	}
+	if err := p.Wait(context.Background(), u); err != nil {
+		t.Fatalf("budget did not reset the next day: %v", err)
+	}
+}
+
+func Test_WaitBudgetShared(t *testing.T) {
+	db := migratetest.Open(t)
+
+	now := time.Date(2022, 5, 1, 23, 0, 0, 0, time.UTC)
+	open := func() *Policy {
+		p := New("taxcollector/1.0", 3)
+		p.now = func() time.Time { return now }
+		p.SetDB(db)
+		p.SetRules(AllowAll)
+		return p
+	}
+	a, b := open(), open()
+
+	u, _ := url.Parse("https://example.com/clientdb/Property.aspx?prop_id=1")
+	for _, p := range []*Policy{a, b, a} {
+		if err := p.Wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Wait(context.Background(), u); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("fourth request error = %v, want ErrBudgetExhausted", err)
+	}
+	// A restarted process picks up where the others left off.
+	if got, err := open().Remaining(context.Background()); err != nil || got != 0 {
+		t.Errorf("Remaining() = %d, %v, want 0", got, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if got, err := a.Remaining(context.Background()); err != nil || got != 3 {
+		t.Errorf("Remaining() next day = %d, %v, want 3", got, err)
+	}
+	if err := b.Wait(context.Background(), u); err != nil {
+		t.Fatalf("budget did not reset the next day: %v", err)
+	}
+}
+
+func Test_WaitCrawlDelay(t *testing.T) {
+	p := New("taxcollector/1.0", 0)
+	p.SetRules(&Rules{CrawlDelay: 50 * time.Millisecond})
+
+	u, _ := url.Parse("https://example.com/clientdb/Property.aspx?prop_id=1")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := p.Wait(context.Background(), u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three requests took %s, want at least two crawl delays", elapsed)
+	}
+
+	if err := New("x", 0).Wait(context.Background(), u); !errors.Is(err, ErrNoRobots) {
+		t.Errorf("Wait() before robots.txt is loaded = %v, want ErrNoRobots", err)
+	}
+}
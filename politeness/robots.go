@@ -0,0 +1,163 @@
+package politeness
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rules are the robots.txt directives that apply to one user agent.
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration
+}
+
+type rule struct {
+	pattern string
+	allow   bool
+}
+
+// AllowAll is used when a site has no robots.txt.
+var AllowAll = &Rules{}
+
+// DisallowAll is used when robots.txt can't be fetched because the server is
+// failing, as RFC 9309 asks.
+var DisallowAll = &Rules{rules: []rule{{pattern: "/", allow: false}}}
+
+// ParseRobots reads robots.txt and keeps the group for agent, falling back to
+// the "*" group. agent may be a full User-Agent header; only its product
+// token, e.g. "taxcollector" from "taxcollector/1.0 (...)", is matched, and
+// only against user-agent lines naming that whole token, ignoring case.
+func ParseRobots(r io.Reader, agent string) (*Rules, error) {
+	token := strings.ToLower(agent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		specific, wildcard *Rules
+		current            []*Rules
+		inRules            bool
+	)
+	group := func(ua string) *Rules {
+		ua = strings.ToLower(ua)
+		switch {
+		case ua == "*":
+			if wildcard == nil {
+				wildcard = &Rules{}
+			}
+			return wildcard
+		case token != "" && ua == token:
+			if specific == nil {
+				specific = &Rules{}
+			}
+			return specific
+		}
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group; consecutive
+			// ones share the group that follows.
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			if g := group(value); g != nil {
+				current = append(current, g)
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			for _, g := range current {
+				g.rules = append(g.rules, rule{pattern: value, allow: key == "allow"})
+			}
+		case "crawl-delay":
+			inRules = true
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			for _, g := range current {
+				g.CrawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if specific != nil {
+		return specific, nil
+	}
+	if wildcard != nil {
+		return wildcard, nil
+	}
+	return AllowAll, nil
+}
+
+// Allowed reports whether path (with its query string) may be fetched. The
+// longest matching rule wins and Allow wins a tie.
+func (r *Rules) Allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	best, allowed := -1, true
+	for _, ru := range r.rules {
+		if !match(ru.pattern, path) {
+			continue
+		}
+		if n := len(ru.pattern); n > best || (n == best && ru.allow) {
+			best, allowed = n, ru.allow
+		}
+	}
+	return allowed
+}
+
+// match reports whether a robots.txt path pattern matches path. "*" matches
+// any run of characters and a trailing "$" anchors the end.
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, p := range parts[1:] {
+		i := strings.Index(path[pos:], p)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(p)
+	}
+	if !anchored {
+		return true
+	}
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		return true
+	}
+	if len(parts) == 1 {
+		return pos == len(path)
+	}
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
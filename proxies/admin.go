@@ -0,0 +1,40 @@
+package proxies
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnknownProxy means no proxy in the table has the given host:port.
+var ErrUnknownProxy = errors.New("unknown proxy")
+
+// Reset puts a retired or benched proxy straight back into rotation. Its
+// success and failure counts are kept.
+func (p *ProxyClient) Reset(ctx context.Context, ip string) error {
+	n, err := p.pdb.ResetProxy(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUnknownProxy
+	}
+	return nil
+}
+
+// ResetAll resets every retired or benched proxy and returns how many there
+// were.
+func (p *ProxyClient) ResetAll(ctx context.Context) (int64, error) {
+	return p.pdb.ResetAllProxies(ctx)
+}
+
+// Remove deletes a proxy from the pool.
+func (p *ProxyClient) Remove(ctx context.Context, ip string) error {
+	n, err := p.pdb.DeleteProxy(ctx, ip)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUnknownProxy
+	}
+	return nil
+}
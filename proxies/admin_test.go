@@ -0,0 +1,47 @@
+package proxies
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func Test_ResetAndRemove(t *testing.T) {
+	ctx := context.Background()
+	p := openTestClient(t, "10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080")
+	if err := p.MarkProxyAsBad("10.0.0.1:8080"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RecordFailure("10.0.0.2:8080"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Reset(ctx, "10.0.0.1:8080"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := p.ResetAll(ctx); err != nil || n != 1 {
+		t.Fatalf("ResetAll() = %d, %v, want 1 benched proxy reset", n, err)
+	}
+	list, err := p.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pr := range list {
+		if pr.IsBad || pr.ConsecutiveFailures != 0 || !pr.CooldownUntil.IsZero() {
+			t.Errorf("%s not reset: %+v", pr.IP, pr)
+		}
+	}
+	if list[1].Failures != 1 {
+		t.Errorf("reset dropped the failure count of %s", list[1].IP)
+	}
+
+	if err := p.Remove(ctx, "10.0.0.3:8080"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove(ctx, "10.0.0.3:8080"); !errors.Is(err, ErrUnknownProxy) {
+		t.Errorf("Remove() of a missing proxy = %v, want ErrUnknownProxy", err)
+	}
+	if err := p.Reset(ctx, "10.9.9.9:8080"); !errors.Is(err, ErrUnknownProxy) {
+		t.Errorf("Reset() of a missing proxy = %v, want ErrUnknownProxy", err)
+	}
+}
@@ -0,0 +1,188 @@
+package proxies
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+)
+
+const (
+	// latencyAlpha weights the newest sample in the rolling latency average.
+	latencyAlpha = 0.2
+	// A failed proxy sits out baseCooldown, doubling with every further
+	// consecutive failure up to maxCooldown.
+	baseCooldown = 30 * time.Second
+	maxCooldown  = 6 * time.Hour
+	// probationWeight scales the score of a proxy that is back from a
+	// cooldown but hasn't succeeded since. One success rehabilitates it.
+	probationWeight = 0.25
+	// minScore keeps every available proxy selectable.
+	minScore = 0.01
+)
+
+// cooldown returns how long a proxy sits out after failures consecutive
+// failures.
+func cooldown(failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+	d := baseCooldown << uint(failures-1)
+	if d > maxCooldown || d <= 0 {
+		return maxCooldown
+	}
+	return d
+}
+
+// score rates a proxy between minScore and 1 from its smoothed success rate
+// and rolling latency. A proxy with no history scores 0.5.
+func score(pr Proxy) float64 {
+	rate := float64(pr.Successes+1) / float64(pr.Successes+pr.Failures+2)
+	s := rate / (1 + pr.Latency.Seconds())
+	if pr.ConsecutiveFailures > 0 {
+		s *= probationWeight
+	}
+	return math.Max(s, minScore)
+}
+
+func fromRow(r pgdb.Proxy) Proxy {
+	pr := Proxy{
+		IP:                  r.Ip,
+		Uses:                int(r.Uses.Int32),
+		IsBad:               r.IsBad.Valid && r.IsBad.Int32 != 0,
+		Successes:           int(r.Successes),
+		Failures:            int(r.Failures),
+		ConsecutiveFailures: int(r.ConsecutiveFailures),
+		Latency:             time.Duration(r.LatencyMs * float64(time.Millisecond)),
+	}
+	if r.CooldownUntil.Valid {
+		pr.CooldownUntil = r.CooldownUntil.Time
+	}
+	if r.LastUsedAt.Valid {
+		pr.LastUsed = r.LastUsedAt.Time
+	}
+	if r.LeasedUntil.Valid {
+		pr.LeasedUntil = r.LeasedUntil.Time
+	}
+	if r.Url.Valid {
+		if u, err := url.Parse(r.Url.String); err == nil {
+			pr.URL = u
+		}
+	}
+	pr.Score = score(pr)
+	return pr
+}
+
+func (p *ProxyClient) pick(candidates []Proxy) Proxy {
+	p.mu.Lock()
+	r := p.rnd.Float64()
+	p.mu.Unlock()
+	return pickWeighted(candidates, r)
+}
+
+// pickWeighted picks from candidates by score; r is uniform in [0, 1).
+func pickWeighted(candidates []Proxy, r float64) Proxy {
+	var total float64
+	for _, c := range candidates {
+		total += c.Score
+	}
+	r *= total
+	for _, c := range candidates {
+		if r < c.Score {
+			return c
+		}
+		r -= c.Score
+	}
+	return candidates[len(candidates)-1]
+}
+
+// Get returns a proxy with its health stats.
+func (p *ProxyClient) Get(ip string) (Proxy, error) {
+	r, err := p.pdb.GetProxyByIP(context.Background(), ip)
+	if err != nil {
+		return Proxy{}, err
+	}
+	return fromRow(r), nil
+}
+
+// List returns every proxy in the pool, retired ones included.
+func (p *ProxyClient) List(ctx context.Context) ([]Proxy, error) {
+	rows, err := p.pdb.ListProxies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Proxy, len(rows))
+	for i, r := range rows {
+		out[i] = fromRow(r)
+	}
+	return out, nil
+}
+
+// RecordSuccess counts a request that went through the proxy, folds its
+// latency into the rolling average and ends any probation.
+func (p *ProxyClient) RecordSuccess(ip string, latency time.Duration) error {
+	ms := float64(latency) / float64(time.Millisecond)
+	n, err := p.pdb.RecordProxySuccess(context.Background(), pgdb.RecordProxySuccessParams{
+		LatencyMs: ms,
+		Decay:     1 - latencyAlpha,
+		Sample:    latencyAlpha * ms,
+		Ip:        ip,
+	})
+	if err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// RecordFailure counts a failed request and benches the proxy for a cooldown
+// that doubles with each consecutive failure. Once the cooldown ends the
+// proxy is back in rotation on probation. The cooldown depends on the
+// failures before this one, so the update only applies while they are the
+// ones read, and is tried again if another outcome was recorded meanwhile.
+func (p *ProxyClient) RecordFailure(ip string) error {
+	for {
+		pr, err := p.Get(ip)
+		if err != nil {
+			return err
+		}
+		n, err := p.pdb.RecordProxyFailure(context.Background(), pgdb.RecordProxyFailureParams{
+			CooldownUntil:       nullTime(p.now().Add(cooldown(pr.ConsecutiveFailures + 1))),
+			Ip:                  ip,
+			ConsecutiveFailures: int32(pr.ConsecutiveFailures),
+		})
+		if err != nil || n > 0 {
+			return err
+		}
+	}
+}
+
+func succeed(pr *Proxy, latency time.Duration) {
+	pr.Successes++
+	pr.ConsecutiveFailures = 0
+	pr.CooldownUntil = time.Time{}
+	if pr.Latency == 0 {
+		pr.Latency = latency
+	} else {
+		pr.Latency = time.Duration(latencyAlpha*float64(latency) + (1-latencyAlpha)*float64(pr.Latency))
+	}
+	pr.Score = score(*pr)
+}
+
+func fail(pr *Proxy, now time.Time) {
+	pr.Failures++
+	pr.ConsecutiveFailures++
+	pr.CooldownUntil = now.Add(cooldown(pr.ConsecutiveFailures))
+	pr.Score = score(*pr)
+}
+
+// nullTime stores zero times as NULL and everything else in UTC, so SQLite's
+// text comparisons order timestamps correctly.
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,158 @@
+package proxies
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+)
+
+func openTestClient(t *testing.T, ips ...string) *ProxyClient {
+	t.Helper()
+	db := migratetest.Open(t)
+	for _, ip := range ips {
+		if _, err := db.Exec(`insert into proxies(ip, uses, is_bad) values($1, 0, 0)`, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewProxyClient(db)
+}
+
+func Test_cooldown(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{12, maxCooldown},
+		{80, maxCooldown},
+	}
+	for _, tt := range tests {
+		if got := cooldown(tt.failures); got != tt.want {
+			t.Errorf("cooldown(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func Test_FailureCooldownAndRehabilitation(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	pc := openTestClient(t, "10.0.0.1:8080")
+	pc.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := pc.RecordFailure("10.0.0.1:8080"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pc.Acquire(context.Background()); !errors.Is(err, ErrNoProxyAvailable) {
+		t.Fatalf("Acquire() during cooldown error = %v, want ErrNoProxyAvailable", err)
+	}
+
+	now = now.Add(cooldown(2) + time.Second)
+	lease, err := pc.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("proxy not back after its cooldown: %v", err)
+	}
+	pr := lease.Proxy
+	if pr.ConsecutiveFailures != 2 || pr.Score >= 0.5*probationWeight {
+		t.Errorf("proxy on probation = %+v, want reduced score", pr)
+	}
+
+	if err := lease.Release(Succeeded, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.RecordSuccess("10.0.0.1:8080", 400*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	pr, err = pc.Get("10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.ConsecutiveFailures != 0 || !pr.CooldownUntil.IsZero() {
+		t.Errorf("proxy not rehabilitated: %+v", pr)
+	}
+	if pr.Successes != 2 || pr.Failures != 2 {
+		t.Errorf("counters = %d/%d, want 2/2", pr.Successes, pr.Failures)
+	}
+	if want := 240 * time.Millisecond; pr.Latency != want {
+		t.Errorf("rolling latency = %s, want %s", pr.Latency, want)
+	}
+}
+
+// Test_ConcurrentOutcomes records outcomes from two clients at once, as a
+// scraper and the prober do, and checks none of them is lost.
+func Test_ConcurrentOutcomes(t *testing.T) {
+	a := openTestClient(t, "10.0.0.1:8080")
+	a.db.SetMaxOpenConns(1)
+	b := NewProxyClient(a.db)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*n)
+	for i := 0; i < n; i++ {
+		for _, pc := range []*ProxyClient{a, b} {
+			wg.Add(2)
+			go func(pc *ProxyClient) {
+				defer wg.Done()
+				errs <- pc.RecordSuccess("10.0.0.1:8080", 100*time.Millisecond)
+			}(pc)
+			go func(pc *ProxyClient) {
+				defer wg.Done()
+				errs <- pc.RecordFailure("10.0.0.1:8080")
+			}(pc)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pr, err := a.Get("10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Successes != 2*n || pr.Failures != 2*n {
+		t.Errorf("successes, failures = %d, %d, want %d each", pr.Successes, pr.Failures, 2*n)
+	}
+}
+
+func Test_AcquireWeighted(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080", "10.0.0.2:8080")
+	for i := 0; i < 20; i++ {
+		if err := pc.RecordSuccess("10.0.0.1:8080", 100*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A long-past failure streak leaves 10.0.0.2 available but on probation.
+	pc.now = func() time.Time { return time.Now().Add(-24 * time.Hour) }
+	for i := 0; i < 3; i++ {
+		if err := pc.RecordFailure("10.0.0.2:8080"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pc.now = time.Now
+
+	picks := map[string]int{}
+	for i := 0; i < 500; i++ {
+		lease, err := pc.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		picks[lease.Proxy.IP]++
+		if err := lease.Release(Unused, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if picks["10.0.0.1:8080"] < 400 || picks["10.0.0.2:8080"] == 0 {
+		t.Errorf("picks = %v, want the healthy proxy to dominate without starving the other", picks)
+	}
+}
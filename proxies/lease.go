@@ -0,0 +1,137 @@
+package proxies
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+)
+
+// Outcome is what a lease holder reports when it gives a proxy back.
+type Outcome int
+
+const (
+	// Unused means no request went through the proxy, so its health stats
+	// are left alone.
+	Unused Outcome = iota
+	Succeeded
+	Failed
+)
+
+const (
+	// leaseTTL bounds how long a lease holds a proxy. A worker that dies
+	// without releasing only keeps its proxy out of rotation until then.
+	leaseTTL = 5 * time.Minute
+	// leasePoll is how often Acquire looks again while every usable proxy is
+	// leased to someone else.
+	leasePoll = 250 * time.Millisecond
+)
+
+// Lease is exclusive use of a proxy until it is released or leaseTTL passes.
+type Lease struct {
+	Proxy   Proxy
+	release func(o Outcome, latency time.Duration) error
+	once    sync.Once
+}
+
+// Release gives the proxy back and records how the requests through it went.
+// latency only counts for Succeeded. Releasing a lease twice is a no-op.
+func (l *Lease) Release(o Outcome, latency time.Duration) error {
+	var err error
+	l.once.Do(func() { err = l.release(o, latency) })
+	return err
+}
+
+func newLeaseToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Acquire leases a proxy that isn't retired, cooling down or leased to
+// another worker, picked at random weighted by score. The claim is a single
+// conditional update, so two workers racing for the same proxy can't both
+// win. While every usable proxy is leased Acquire waits for one to be
+// released, until ctx is done.
+func (p *ProxyClient) Acquire(ctx context.Context) (*Lease, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		now := p.now().UTC()
+		rows, err := p.pdb.ListAvailableProxies(ctx, sql.NullTime{Time: now, Valid: true})
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, ErrNoProxyAvailable
+		}
+
+		var free []Proxy
+		for _, r := range rows {
+			if pr := fromRow(r); !pr.LeasedUntil.After(now) {
+				free = append(free, pr)
+			}
+		}
+		if len(free) == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(leasePoll):
+			}
+			continue
+		}
+
+		pr := p.pick(free)
+		token, err := newLeaseToken()
+		if err != nil {
+			return nil, err
+		}
+		claimed, err := p.pdb.ClaimProxy(ctx, pgdb.ClaimProxyParams{
+			LeaseToken:  sql.NullString{String: token, Valid: true},
+			LeasedUntil: sql.NullTime{Time: now.Add(leaseTTL), Valid: true},
+			LastUsedAt:  sql.NullTime{Time: now, Valid: true},
+			Ip:          pr.IP,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if claimed == 0 {
+			// Another worker got there first.
+			continue
+		}
+		pr.Uses++
+		pr.LastUsed = now
+		pr.LeasedUntil = now.Add(leaseTTL)
+		return &Lease{
+			Proxy: pr,
+			release: func(o Outcome, latency time.Duration) error {
+				return p.release(pr.IP, token, o, latency)
+			},
+		}, nil
+	}
+}
+
+func (p *ProxyClient) release(ip, token string, o Outcome, latency time.Duration) error {
+	// Release first, so a proxy whose stats can't be written isn't left
+	// leased until the lease runs out.
+	if _, err := p.pdb.ReleaseProxy(context.Background(), pgdb.ReleaseProxyParams{
+		Ip:         ip,
+		LeaseToken: sql.NullString{String: token, Valid: true},
+	}); err != nil {
+		return err
+	}
+	switch o {
+	case Succeeded:
+		return p.RecordSuccess(ip, latency)
+	case Failed:
+		return p.RecordFailure(ip)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package proxies
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type acquirer interface {
+	Acquire(ctx context.Context) (*Lease, error)
+}
+
+// hammer has workers acquire and release leases concurrently and fails if a
+// proxy is ever held by two workers at once.
+func hammer(t *testing.T, a acquirer, workers, rounds int) map[string]int {
+	t.Helper()
+	var mu sync.Mutex
+	held := map[string]bool{}
+	uses := map[string]int{}
+	errs := make(chan error, workers)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				lease, err := a.Acquire(context.Background())
+				if err != nil {
+					errs <- err
+					return
+				}
+				ip := lease.Proxy.IP
+				mu.Lock()
+				if held[ip] {
+					mu.Unlock()
+					errs <- errors.New(ip + " leased twice")
+					return
+				}
+				held[ip] = true
+				uses[ip]++
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				held[ip] = false
+				mu.Unlock()
+				if err := lease.Release(Succeeded, 10*time.Millisecond); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	return uses
+}
+
+func Test_AcquireExclusive(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080")
+	pc.db.SetMaxOpenConns(1)
+
+	uses := hammer(t, pc, 6, 10)
+
+	for ip, n := range uses {
+		pr, err := pc.Get(ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pr.Uses != n || pr.Successes != n {
+			t.Errorf("%s uses = %d successes = %d, want %d", ip, pr.Uses, pr.Successes, n)
+		}
+		if !pr.LeasedUntil.IsZero() {
+			t.Errorf("%s still leased after release", ip)
+		}
+		if pr.LastUsed.IsZero() {
+			t.Errorf("%s last used not recorded", ip)
+		}
+	}
+}
+
+func Test_AcquireWaitsForRelease(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080")
+	first, err := pc.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pc.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() while leased error = %v, want deadline exceeded", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		first.Release(Unused, 0)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	second, err := pc.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() after release: %v", err)
+	}
+	if err := second.Release(Unused, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_AcquireExpiredLease(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	pc := openTestClient(t, "10.0.0.1:8080")
+	pc.now = func() time.Time { return now }
+
+	stale, err := pc.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(leaseTTL + time.Second)
+	fresh, err := pc.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("expired lease not reclaimed: %v", err)
+	}
+
+	// The stale holder giving the proxy back must not end the new lease.
+	if err := stale.Release(Unused, 0); err != nil {
+		t.Fatal(err)
+	}
+	pr, err := pc.Get("10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.LeasedUntil.IsZero() {
+		t.Error("stale release cleared the current lease")
+	}
+	if err := fresh.Release(Unused, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ReleaseWhenStatsFail(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080")
+	lease, err := pc.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.db.Exec(`create trigger no_stats before update of successes on proxies
+		begin select raise(abort, 'stats unavailable'); end`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lease.Release(Succeeded, time.Millisecond); err == nil || !strings.Contains(err.Error(), "stats unavailable") {
+		t.Fatalf("Release() error = %v, want the stats error", err)
+	}
+	pr, err := pc.Get("10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pr.LeasedUntil.IsZero() {
+		t.Error("proxy still leased after its stats failed to save")
+	}
+}
+
+func Test_PoolSync(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080", "10.0.0.2:8080")
+	pool, err := NewPool(context.Background(), pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uses := hammer(t, pool, 4, 25)
+
+	lease, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	failedIP := lease.Proxy.IP
+	uses[failedIP]++
+	if err := lease.Release(Failed, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reaches the table until Sync, and results recorded directly in
+	// the meantime, e.g. by the prober, are kept.
+	pr, err := pc.Get(failedIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Uses != 0 {
+		t.Fatalf("uses before sync = %d, want 0", pr.Uses)
+	}
+	if err := pc.RecordSuccess(failedIP, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := pc.Import(context.Background(), []Proxy{{IP: "10.0.0.3:8080"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for ip, n := range uses {
+		pr, err := pc.Get(ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The failed proxy's extra use is matched by the direct success.
+		if ip == failedIP {
+			if pr.Failures != 1 || pr.CooldownUntil.IsZero() {
+				t.Errorf("%s failures = %d cooldown = %s, want the pooled failure", ip, pr.Failures, pr.CooldownUntil)
+			}
+		}
+		if pr.Uses != n || pr.Successes != n {
+			t.Errorf("%s uses = %d successes = %d, want %d", ip, pr.Uses, pr.Successes, n)
+		}
+	}
+
+	// The failed proxy is cooling down, so only the other original and the
+	// newly imported proxy are handed out.
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		lease, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[lease.Proxy.IP] = true
+		lease.Release(Unused, 0)
+	}
+	if seen[failedIP] || !seen["10.0.0.3:8080"] {
+		t.Errorf("proxies handed out after sync = %v", seen)
+	}
+}
+
+func Test_PoolSyncRetry(t *testing.T) {
+	pc := openTestClient(t, "10.0.0.1:8080")
+	pool, err := NewPool(context.Background(), pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uses := hammer(t, pool, 1, 3)
+
+	// Usage gets written, health doesn't.
+	if _, err := pc.db.Exec(`create trigger no_stats before update of successes on proxies
+		begin select raise(abort, 'stats unavailable'); end`); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Sync(context.Background()); err == nil {
+		t.Fatal("Sync() succeeded with health updates failing")
+	}
+	if _, err := pc.db.Exec(`drop trigger no_stats`); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := pc.Get("10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := uses["10.0.0.1:8080"]; pr.Uses != n || pr.Successes != n {
+		t.Errorf("uses = %d successes = %d after a retried Sync, want %d", pr.Uses, pr.Successes, n)
+	}
+}
+
+func Test_StaticPool(t *testing.T) {
+	list, err := ParseList(strings.NewReader("10.0.0.1:8080\nsocks5://u:p@10.0.0.2:1080\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewStatic(list)
+
+	uses := hammer(t, pool, 4, 10)
+	if len(uses) != 2 {
+		t.Errorf("proxies used = %v, want both", uses)
+	}
+	if err := pool.Sync(context.Background()); err != nil {
+		t.Errorf("Sync() on a static pool = %v", err)
+	}
+
+	lease, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Proxy.URL == nil {
+		t.Error("static lease lost the proxy URL")
+	}
+}
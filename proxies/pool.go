@@ -0,0 +1,251 @@
+package proxies
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+)
+
+// Pool leases proxies from memory. Backed by the proxies table it saves a
+// busy scraper a database round trip per job: Sync writes usage and health
+// back and reloads the table, picking up proxies that were imported, retired
+// or probed in the meantime. Leases only exclude other workers of the same
+// Pool, so run one pooled scraper per proxies table. A static Pool has no
+// table and keeps its health stats to itself.
+type Pool struct {
+	// pc is nil for a static pool.
+	pc  *ProxyClient
+	now func() time.Time
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	proxies map[string]*poolProxy
+}
+
+// poolProxy is a proxy plus what has happened to it since the last Sync.
+type poolProxy struct {
+	Proxy
+	leased bool
+
+	pendingUses      int
+	pendingSuccesses int
+	pendingFailures  int
+	// touched means the health stats changed since the last Sync.
+	touched bool
+}
+
+// NewPool loads the proxies table into memory.
+func NewPool(ctx context.Context, pc *ProxyClient) (*Pool, error) {
+	p := newPool(func() time.Time { return pc.now() })
+	p.pc = pc
+	if err := p.reload(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewStatic returns a pool of a fixed list of proxies, for deployments
+// without a proxies table.
+func NewStatic(list []Proxy) *Pool {
+	p := newPool(time.Now)
+	for _, pr := range list {
+		pr.Score = score(pr)
+		p.proxies[pr.IP] = &poolProxy{Proxy: pr}
+	}
+	return p
+}
+
+func newPool(now func() time.Time) *Pool {
+	return &Pool{
+		now:     now,
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		proxies: map[string]*poolProxy{},
+	}
+}
+
+// Acquire leases a proxy like ProxyClient.Acquire, without touching the
+// database.
+func (p *Pool) Acquire(ctx context.Context) (*Lease, error) {
+	for {
+		now := p.now()
+		p.mu.Lock()
+		var free []Proxy
+		usable := 0
+		for _, pp := range p.proxies {
+			if pp.IsBad || pp.CooldownUntil.After(now) {
+				continue
+			}
+			usable++
+			if !pp.leased {
+				free = append(free, pp.Proxy)
+			}
+		}
+		if len(free) > 0 {
+			pp := p.proxies[pickWeighted(free, p.rnd.Float64()).IP]
+			pp.leased = true
+			pp.pendingUses++
+			pp.Uses++
+			pp.LastUsed = now
+			pr := pp.Proxy
+			p.mu.Unlock()
+			return &Lease{
+				Proxy: pr,
+				release: func(o Outcome, latency time.Duration) error {
+					p.release(pr.IP, o, latency)
+					return nil
+				},
+			}, nil
+		}
+		p.mu.Unlock()
+
+		if usable == 0 {
+			return nil, ErrNoProxyAvailable
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(leasePoll):
+		}
+	}
+}
+
+func (p *Pool) release(ip string, o Outcome, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pp, ok := p.proxies[ip]
+	if !ok {
+		// Removed from the table while leased.
+		return
+	}
+	pp.leased = false
+	switch o {
+	case Succeeded:
+		succeed(&pp.Proxy, latency)
+		pp.pendingSuccesses++
+		pp.touched = true
+	case Failed:
+		fail(&pp.Proxy, p.now())
+		pp.pendingFailures++
+		pp.touched = true
+	}
+}
+
+// Sync writes what has happened since the last Sync to the proxies table,
+// then reloads it. It does nothing for a static pool.
+func (p *Pool) Sync(ctx context.Context) error {
+	if p.pc == nil {
+		return nil
+	}
+	p.mu.Lock()
+	var dirty []poolProxy
+	for _, pp := range p.proxies {
+		if pp.pendingUses > 0 || pp.touched {
+			dirty = append(dirty, *pp)
+			pp.pendingUses, pp.pendingSuccesses, pp.pendingFailures, pp.touched = 0, 0, 0, false
+		}
+	}
+	p.mu.Unlock()
+
+	for i := range dirty {
+		if err := p.write(ctx, &dirty[i]); err != nil {
+			// Put back what didn't make it so the next Sync retries it.
+			p.mu.Lock()
+			for _, d := range dirty[i:] {
+				if pp, ok := p.proxies[d.IP]; ok {
+					pp.pendingUses += d.pendingUses
+					pp.pendingSuccesses += d.pendingSuccesses
+					pp.pendingFailures += d.pendingFailures
+					pp.touched = pp.touched || d.touched
+				}
+			}
+			p.mu.Unlock()
+			return err
+		}
+	}
+	return p.reload(ctx)
+}
+
+// write clears d's pending counts as they are written, so what is left after
+// an error is what still has to be.
+func (p *Pool) write(ctx context.Context, d *poolProxy) error {
+	if d.pendingUses > 0 {
+		err := p.pc.pdb.UpdateProxyUsage(ctx, pgdb.UpdateProxyUsageParams{
+			Uses:       int32(d.pendingUses),
+			LastUsedAt: nullTime(d.LastUsed),
+			Ip:         d.IP,
+		})
+		if err != nil {
+			return err
+		}
+		d.pendingUses = 0
+	}
+	if !d.touched {
+		return nil
+	}
+	// Counters are added to whatever the table holds, so results the prober
+	// recorded meanwhile survive; the rest reflects the latest outcome.
+	return p.pc.pdb.AddProxyHealth(ctx, pgdb.AddProxyHealthParams{
+		Successes:           int32(d.pendingSuccesses),
+		Failures:            int32(d.pendingFailures),
+		ConsecutiveFailures: int32(d.ConsecutiveFailures),
+		LatencyMs:           float64(d.Latency) / float64(time.Millisecond),
+		CooldownUntil:       nullTime(d.CooldownUntil),
+		Ip:                  d.IP,
+	})
+}
+
+func (p *Pool) reload(ctx context.Context) error {
+	rows, err := p.pc.pdb.ListProxies(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := make(map[string]*poolProxy, len(rows))
+	for _, r := range rows {
+		pr := fromRow(r)
+		pp, ok := p.proxies[pr.IP]
+		if !ok {
+			next[pr.IP] = &poolProxy{Proxy: pr}
+			continue
+		}
+		if pp.touched {
+			// Outcomes recorded during this Sync aren't in the table yet.
+			pr.Successes += pp.pendingSuccesses
+			pr.Failures += pp.pendingFailures
+			pr.ConsecutiveFailures = pp.ConsecutiveFailures
+			pr.CooldownUntil = pp.CooldownUntil
+			pr.Latency = pp.Latency
+			pr.Score = score(pr)
+		}
+		pr.Uses += pp.pendingUses
+		pp.Proxy = pr
+		next[pr.IP] = pp
+	}
+	p.proxies = next
+	return nil
+}
+
+// Run syncs every interval until ctx is cancelled, then syncs a last time.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := p.Sync(context.Background()); err != nil {
+				log.Println("proxy pool:", err)
+			}
+			return
+		case <-ticker.C:
+			if err := p.Sync(ctx); err != nil {
+				log.Println("proxy pool:", err)
+			}
+		}
+	}
+}
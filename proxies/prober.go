@@ -0,0 +1,171 @@
+package proxies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/metrics"
+)
+
+// ProbeOptions configures a Prober.
+type ProbeOptions struct {
+	// URL is fetched through every proxy. It should be cheap and reliable,
+	// and not the portal itself so probes don't eat into its rate limits.
+	URL string
+	// Expect, when set, must appear in the response body. It catches proxies
+	// that answer with a login or block page instead of relaying.
+	Expect string
+	// Timeout bounds a single probe.
+	Timeout time.Duration
+	// Concurrency is how many proxies are probed at once.
+	Concurrency int
+}
+
+// Prober checks the proxies in the pool in the background, so dead proxies
+// are benched before a job draws them and benched proxies that recover are
+// back in rotation without waiting out their cooldown.
+type Prober struct {
+	pc   *ProxyClient
+	opts ProbeOptions
+	// base is cloned for every probe; nil means http.DefaultTransport.
+	base *http.Transport
+}
+
+func NewProber(pc *ProxyClient, opts ProbeOptions) *Prober {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	return &Prober{pc: pc, opts: opts}
+}
+
+// ProbeResult is the outcome of probing one proxy.
+type ProbeResult struct {
+	Proxy   Proxy
+	Latency time.Duration
+	Err     error
+}
+
+// Probe fetches the probe URL through pr and returns how long the response
+// took to arrive. Each probe dials afresh so a pooled connection can't hide a
+// proxy that no longer accepts new ones.
+func (p *Prober) Probe(ctx context.Context, pr Proxy) (time.Duration, error) {
+	t, err := Transport(pr, p.base)
+	if err != nil {
+		return 0, err
+	}
+	defer t.CloseIdleConnections()
+	t.DisableKeepAlives = true
+
+	ctx, cancel := context.WithTimeout(ctx, p.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: t}).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return latency, errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return latency, err
+	}
+	if p.opts.Expect != "" && !strings.Contains(string(body), p.opts.Expect) {
+		return latency, fmt.Errorf("response does not contain %q", p.opts.Expect)
+	}
+	return latency, nil
+}
+
+// ProbeAll probes every proxy that hasn't been retired, including those
+// cooling down, and records each result in the proxy's health stats.
+func (p *Prober) ProbeAll(ctx context.Context) ([]ProbeResult, error) {
+	all, err := p.pc.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var active []Proxy
+	for _, pr := range all {
+		if !pr.IsBad {
+			active = append(active, pr)
+		}
+	}
+
+	results := make([]ProbeResult, len(active))
+	sem := make(chan struct{}, p.opts.Concurrency)
+	wg := &sync.WaitGroup{}
+	for i, pr := range active {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pr Proxy) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			latency, err := p.Probe(ctx, pr)
+			results[i] = ProbeResult{Proxy: pr, Latency: latency, Err: err}
+		}(i, pr)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if ctx.Err() != nil {
+			// Probes cut short by shutdown say nothing about the proxy.
+			return results, ctx.Err()
+		}
+		var err error
+		if r.Err == nil {
+			metrics.ProxyProbes.WithLabelValues("success").Inc()
+			err = p.pc.RecordSuccess(r.Proxy.IP, r.Latency)
+		} else {
+			metrics.ProxyProbes.WithLabelValues("failure").Inc()
+			err = p.pc.RecordFailure(r.Proxy.IP)
+		}
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Run probes the pool every interval until ctx is cancelled.
+func (p *Prober) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		results, err := p.ProbeAll(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println("proxy prober:", err)
+		}
+		healthy := 0
+		for _, r := range results {
+			if r.Err == nil {
+				healthy++
+			}
+		}
+		if ctx.Err() == nil {
+			log.Printf("proxy prober: %d of %d proxies healthy", healthy, len(results))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package proxies
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// forwardProxy relays plain HTTP requests, or answers them itself with body
+// when body is set, and counts what it sees.
+func forwardProxy(t *testing.T, body string) (addr string, hits *int32) {
+	t.Helper()
+	hits = new(int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if body != "" {
+			io.WriteString(w, body)
+			return
+		}
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String(), hits
+}
+
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func Test_ProberProbeAll(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"origin": "egress-ok"}`)
+	}))
+	defer target.Close()
+
+	good, _ := forwardProxy(t, "")
+	captive, _ := forwardProxy(t, "<html>please log in</html>")
+	retired, retiredHits := forwardProxy(t, "")
+	dead := deadAddr(t)
+
+	p := openTestClient(t, good, captive, retired, dead)
+	if err := p.MarkProxyAsBad(retired); err != nil {
+		t.Fatal(err)
+	}
+	// A benched proxy that has recovered should be back in rotation at once.
+	if err := p.RecordFailure(good); err != nil {
+		t.Fatal(err)
+	}
+
+	prober := NewProber(p, ProbeOptions{URL: target.URL, Expect: "egress-ok", Timeout: 2 * time.Second, Concurrency: 2})
+	results, err := prober.ProbeAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("probed %d proxies, want 3", len(results))
+	}
+	if atomic.LoadInt32(retiredHits) != 0 {
+		t.Error("retired proxy was probed")
+	}
+
+	tests := []struct {
+		ip       string
+		wantFail bool
+	}{
+		{good, false},
+		{captive, true},
+		{dead, true},
+	}
+	for _, tt := range tests {
+		pr, err := p.Get(tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if benched := !pr.CooldownUntil.IsZero(); benched != tt.wantFail {
+			t.Errorf("%s benched = %t, want %t", tt.ip, benched, tt.wantFail)
+		}
+		if !tt.wantFail && (pr.Successes != 1 || pr.Latency <= 0) {
+			t.Errorf("%s successes = %d latency = %s, want the probe recorded", tt.ip, pr.Successes, pr.Latency)
+		}
+	}
+}
+
+func Test_ProberRunStops(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	addr, hits := forwardProxy(t, "")
+	p := openTestClient(t, addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewProber(p, ProbeOptions{URL: target.URL}).Run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(hits) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if atomic.LoadInt32(hits) < 2 {
+		t.Errorf("proxy probed %d times, want repeated probes", atomic.LoadInt32(hits))
+	}
+}
@@ -0,0 +1,30 @@
+package proxies
+
+import (
+	"context"
+	"time"
+)
+
+// ProxyProvider hands out proxies to the scraper, one lease per job.
+//
+//   - Direct sends requests without a proxy.
+//   - NewStatic serves a fixed list, e.g. from the config file.
+//   - *ProxyClient leases from the proxies table, and NewPool does the same
+//     from memory with periodic syncs.
+type ProxyProvider interface {
+	Acquire(ctx context.Context) (*Lease, error)
+}
+
+var (
+	_ ProxyProvider = Direct{}
+	_ ProxyProvider = (*ProxyClient)(nil)
+	_ ProxyProvider = (*Pool)(nil)
+)
+
+// Direct is the ProxyProvider for running without proxies. Its leases carry
+// an empty Proxy.
+type Direct struct{}
+
+func (Direct) Acquire(ctx context.Context) (*Lease, error) {
+	return &Lease{release: func(Outcome, time.Duration) error { return nil }}, nil
+}
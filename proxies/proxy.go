@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/jason-costello/taxcollector/storage/pgdb"
@@ -15,82 +18,45 @@ type ProxyClient struct {
 	hc  *http.Client
 	pdb *pgdb.Queries
 	db  *sql.DB
+
+	// mu serialises the read-modify-write of health stats and guards rnd.
+	mu  sync.Mutex
+	rnd *rand.Rand
+	now func() time.Time
 }
 
 func NewProxyClient(db *sql.DB) *ProxyClient {
 
 	return &ProxyClient{
 		db:  db,
-		pdb: pgdb.New(db),
+		pdb: pgdb.NewFor(db),
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+		now: time.Now,
 	}
 
 }
 
 type Proxy struct {
-	IP       string    `json:"IP"`
-	LastUsed time.Time `json:"lastUsed"`
-	Uses     int       `json:"uses"`
-	IsBad    bool      `json:"is_bad"`
-}
-
-func (p *ProxyClient) GetNext() (Proxy, error) {
-
-	proxyRow, err := p.pdb.GetValidProxy(context.Background())
-	if err != nil {
-		return Proxy{}, err
-	}
-
-	if proxyRow.Ip == "" {
-		return Proxy{}, errors.New("no proxy ip found")
-	}
-
-	var uses int32 = 0
-	if proxyRow.Uses.Valid {
-		uses = proxyRow.Uses.Int32
-	}
-
-	proxy := Proxy{
-		IP:   proxyRow.Ip,
-		Uses: int(uses),
-	}
-
-	if err := p.UpdateLastUsed(&proxy); err != nil {
-		return Proxy{}, err
-	}
-
-	return proxy, nil
-
+	IP string `json:"IP"`
+	// URL carries the scheme and credentials; see ProxyURL.
+	URL                 *url.URL      `json:"-"`
+	LastUsed            time.Time     `json:"lastUsed"`
+	Uses                int           `json:"uses"`
+	IsBad               bool          `json:"is_bad"`
+	Successes           int           `json:"successes"`
+	Failures            int           `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	Latency             time.Duration `json:"latency"`
+	CooldownUntil       time.Time     `json:"cooldownUntil,omitempty"`
+	LeasedUntil         time.Time     `json:"leasedUntil,omitempty"`
+	Score               float64       `json:"score"`
 }
 
-func (p *ProxyClient) UpdateLastUsed(proxy *Proxy) error {
-	if proxy.IP == "" {
-		return errors.New("no IP provided")
-	}
-
-	params := pgdb.UpdateProxyLastUsedTimeParams{
-		Lastused: sql.NullString{String: time.Now().String(), Valid: true},
-		Uses:     sql.NullInt32{Int32: int32(proxy.Uses + 1), Valid: true},
-		Ip:       proxy.IP,
-	}
-	if err := p.pdb.UpdateProxyLastUsedTime(context.Background(), params); err != nil {
-		return err
-	}
-
-	return nil
-
-}
+// ErrNoProxyAvailable means every proxy is retired or cooling down.
+var ErrNoProxyAvailable = errors.New("no proxy available")
 
+// MarkProxyAsBad retires a proxy for good. Failed requests should go through
+// RecordFailure instead, which only benches the proxy for a while.
 func (p *ProxyClient) MarkProxyAsBad(proxyIP string) error {
-	updateQuery := `update proxies set is_bad = true where ip = ?`
-	updateStmt, err := p.db.Prepare(updateQuery)
-	if err != nil {
-		return err
-	}
-
-	_, err = updateStmt.Exec(proxyIP)
-	if err != nil {
-		return err
-	}
-	return nil
-
+	return p.pdb.MarkProxyBad(context.Background(), proxyIP)
 }
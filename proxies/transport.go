@@ -0,0 +1,42 @@
+package proxies
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/proxy"
+)
+
+// Transport returns a copy of base that sends every request through pr.
+// HTTP(S) proxies are set as the transport's Proxy; SOCKS5 proxies replace
+// its dialer. A nil base copies http.DefaultTransport.
+func Transport(pr Proxy, base *http.Transport) (*http.Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	u := pr.ProxyURL()
+
+	switch u.Scheme {
+	case "http", "https":
+		t.Proxy = http.ProxyURL(u)
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", u.Redacted(), err)
+		}
+		t.Proxy = nil
+		if cd, ok := d.(proxy.ContextDialer); ok {
+			t.DialContext = cd.DialContext
+		} else {
+			t.DialContext = func(_ context.Context, network, addr string) (net.Conn, error) {
+				return d.Dial(network, addr)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("proxy %s: unsupported scheme %q", u.Redacted(), u.Scheme)
+	}
+	return t, nil
+}
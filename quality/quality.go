@@ -0,0 +1,268 @@
+package quality
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report describes how complete and plausible the stored data is. It is
+// meant to be kept, so runs can be compared over time.
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Tables      []Table   `json:"tables"`
+	Checks      []Check   `json:"checks"`
+}
+
+type Table struct {
+	Name   string  `json:"name"`
+	Rows   int64   `json:"rows"`
+	Fields []Field `json:"fields"`
+}
+
+// Field counts the values of one column. Present values are neither null
+// nor empty text. Zero numbers count as present, but are listed separately
+// since the scraper stores a value it can't parse as 0.
+type Field struct {
+	Name         string   `json:"name"`
+	Present      int64    `json:"present"`
+	Null         int64    `json:"null"`
+	Empty        int64    `json:"empty,omitempty"`
+	Zero         int64    `json:"zero,omitempty"`
+	Completeness float64  `json:"completeness"`
+	Summary      *Summary `json:"summary,omitempty"`
+}
+
+// Summary describes the nonzero values of a numeric column.
+type Summary struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// Check counts rows with one kind of suspicious or missing data.
+type Check struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Rows        int64  `json:"rows"`
+}
+
+type kind int
+
+const (
+	text kind = iota
+	number
+	timestamp
+)
+
+type column struct {
+	name string
+	kind kind
+}
+
+// tables lists the columns the report covers. Ids and bookkeeping columns
+// are left out.
+var tables = []struct {
+	name    string
+	columns []column
+}{
+	{"properties", []column{
+		{"owner_id", number}, {"owner_name", text}, {"owner_mailing_address", text},
+		{"zoning", text}, {"neighborhood_cd", text}, {"neighborhood", text}, {"address", text},
+		{"legal_description", text}, {"geographic_id", text}, {"exemptions", text},
+		{"ownership_percentage", number}, {"mapsco_map_id", text}, {"latitude", number},
+		{"longitude", number}, {"street", text}, {"city", text}, {"last_scraped_at", timestamp},
+		{"occupancy", text}, {"situs_zip", text},
+	}},
+	{"owners", []column{
+		{"name", text}, {"mailing_address", text},
+	}},
+	{"roll_values", []column{
+		{"year", number}, {"improvements", number}, {"land_market", number}, {"ag_valuation", number},
+		{"appraised", number}, {"homestead_cap", number}, {"assessed", number},
+	}},
+	{"land", []column{
+		{"number", number}, {"land_type", text}, {"description", text}, {"acres", number},
+		{"square_feet", number}, {"eff_front", number}, {"eff_depth", number}, {"market_value", number},
+	}},
+	{"jurisdictions", []column{
+		{"entity", text}, {"description", text}, {"tax_rate", number}, {"appraised_value", number},
+		{"taxable_value", number}, {"estimated_tax", number},
+	}},
+	{"improvements", []column{
+		{"name", text}, {"description", text}, {"state_code", text}, {"living_area", number}, {"value", number},
+	}},
+	{"improvement_detail", []column{
+		{"improvement_type", text}, {"description", text}, {"class", text}, {"exterior_wall", text},
+		{"year_built", number}, {"square_feet", number},
+	}},
+}
+
+// checks are counted with "select count(*) from <table> where <where>". $1
+// is the current year.
+var checks = []struct {
+	name        string
+	description string
+	table       string
+	where       string
+}{
+	{"properties_without_address", "properties with no address", "properties",
+		"address is null or address = ''"},
+	{"properties_without_coordinates", "properties not geocoded", "properties",
+		"latitude is null or longitude is null"},
+	{"properties_never_scraped", "properties with no scrape time", "properties",
+		"last_scraped_at is null"},
+	{"properties_without_roll_values", "properties with no roll value history", "properties",
+		"not exists (select 1 from roll_values r where r.property_id = properties.id)"},
+	{"roll_values_missing_year", "roll values with no year, or 0", "roll_values",
+		"year is null or year = 0"},
+	{"roll_values_zero_appraised", "roll values appraised at 0", "roll_values",
+		"appraised = 0"},
+	{"land_without_size", "land with neither acres nor square feet", "land",
+		"(acres is null or acres = 0) and (square_feet is null or square_feet = 0)"},
+	{"improvements_without_details", "improvements with no detail rows", "improvements",
+		"not exists (select 1 from improvement_detail d where d.improvement_id = improvements.id)"},
+	{"improvement_details_implausible_year", "details built before 1800 or after this year", "improvement_detail",
+		"year_built <> 0 and (year_built < 1800 or year_built > $1)"},
+}
+
+// Generate builds a report with one query per table and check.
+func Generate(ctx context.Context, db *sql.DB) (Report, error) {
+	r := Report{GeneratedAt: time.Now().UTC()}
+	for _, t := range tables {
+		table, err := scanTable(ctx, db, t.name, t.columns)
+		if err != nil {
+			return r, fmt.Errorf("%s: %w", t.name, err)
+		}
+		r.Tables = append(r.Tables, table)
+	}
+	for _, c := range checks {
+		query := "select count(*) from " + c.table + " where " + c.where
+		var args []interface{}
+		if strings.Contains(c.where, "$1") {
+			args = append(args, r.GeneratedAt.Year())
+		}
+		var n int64
+		if err := db.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+			return r, fmt.Errorf("%s: %w", c.name, err)
+		}
+		r.Checks = append(r.Checks, Check{Name: c.name, Description: c.description, Rows: n})
+	}
+	return r, nil
+}
+
+func scanTable(ctx context.Context, db *sql.DB, name string, cols []column) (Table, error) {
+	exprs := []string{"count(*)"}
+	for _, c := range cols {
+		exprs = append(exprs, "count("+c.name+")")
+		switch c.kind {
+		case text:
+			exprs = append(exprs, "coalesce(sum(case when "+c.name+" = '' then 1 else 0 end), 0)")
+		case number:
+			exprs = append(exprs,
+				"coalesce(sum(case when "+c.name+" = 0 then 1 else 0 end), 0)",
+				"min(case when "+c.name+" <> 0 then "+c.name+" end)",
+				"max(case when "+c.name+" <> 0 then "+c.name+" end)",
+				"avg(case when "+c.name+" <> 0 then "+c.name+" end)")
+		}
+	}
+
+	t := Table{Name: name}
+	type numbers struct{ min, max, mean sql.NullFloat64 }
+	nonNull := make([]int64, len(cols))
+	blank := make([]int64, len(cols))
+	stats := make([]numbers, len(cols))
+	dest := []interface{}{&t.Rows}
+	for i, c := range cols {
+		dest = append(dest, &nonNull[i])
+		switch c.kind {
+		case text:
+			dest = append(dest, &blank[i])
+		case number:
+			dest = append(dest, &blank[i], &stats[i].min, &stats[i].max, &stats[i].mean)
+		}
+	}
+	query := "select " + strings.Join(exprs, ", ") + " from " + name
+	if err := db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		return t, err
+	}
+
+	for i, c := range cols {
+		f := Field{Name: c.name, Null: t.Rows - nonNull[i], Present: nonNull[i]}
+		switch c.kind {
+		case text:
+			f.Empty = blank[i]
+			f.Present -= blank[i]
+		case number:
+			f.Zero = blank[i]
+			if s := stats[i]; s.min.Valid {
+				f.Summary = &Summary{Min: s.min.Float64, Max: s.max.Float64, Mean: s.mean.Float64}
+			}
+		}
+		if t.Rows > 0 {
+			f.Completeness = float64(f.Present) / float64(t.Rows)
+		}
+		t.Fields = append(t.Fields, f)
+	}
+	return t, nil
+}
+
+// WriteText writes the report as aligned tables for reading in a terminal.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Data quality report, %s\n\n", r.GeneratedAt.Format(time.RFC3339))
+	for _, t := range r.Tables {
+		fmt.Fprintf(tw, "%s: %d rows\n", t.Name, t.Rows)
+		fmt.Fprintf(tw, "FIELD\tCOMPLETE\tNULL\tEMPTY\tZERO\tMIN\tMAX\tMEAN\t\n")
+		for _, f := range t.Fields {
+			min, max, mean := "-", "-", "-"
+			if s := f.Summary; s != nil {
+				min, max, mean = num(s.Min), num(s.Max), num(s.Mean)
+			}
+			fmt.Fprintf(tw, "%s\t%.1f%%\t%d\t%d\t%d\t%s\t%s\t%s\t\n",
+				f.Name, 100*f.Completeness, f.Null, f.Empty, f.Zero, min, max, mean)
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "CHECK\tROWS\t\n")
+	for _, c := range r.Checks {
+		fmt.Fprintf(tw, "%s\t%d\t\n", c.Description, c.Rows)
+	}
+	return tw.Flush()
+}
+
+func num(f float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", f), "0"), ".")
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Handler serves a fresh report from db, as JSON unless the request asks
+// for ?format=text.
+func Handler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r, err := Generate(req.Context(), db)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if req.URL.Query().Get("format") == "text" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			r.WriteText(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		r.WriteJSON(w)
+	})
+}
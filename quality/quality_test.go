@@ -0,0 +1,119 @@
+package quality
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := migratetest.Open(t)
+	for _, stmt := range []string{
+		`insert into properties (id, address, latitude, longitude, last_scraped_at) values
+			(1, '1 MAIN ST', 32.5, -97.5, '2024-01-01 00:00:00+00:00'),
+			(2, '', null, null, null),
+			(3, null, 32.7, -97.7, '2024-01-01 00:00:00+00:00')`,
+		`insert into roll_values (year, appraised, property_id) values
+			(2020, 100, 1), (2021, 300, 1), (0, 0, 2)`,
+		`insert into improvements (id, name, property_id) values (10, 'House', 1), (11, '', 2)`,
+		`insert into improvement_detail (improvement_id, year_built) values (10, 1998), (10, 3000)`,
+		`insert into land (number, acres, square_feet, property_id) values (1, 0, 0, 1), (1, 0.5, null, 2)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func (r Report) field(table, name string) Field {
+	for _, t := range r.Tables {
+		if t.Name == table {
+			for _, f := range t.Fields {
+				if f.Name == name {
+					return f
+				}
+			}
+		}
+	}
+	return Field{}
+}
+
+func Test_Generate(t *testing.T) {
+	r, err := Generate(context.Background(), openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := r.field("properties", "address")
+	if address.Present != 1 || address.Empty != 1 || address.Null != 1 {
+		t.Errorf("address = %+v", address)
+	}
+	appraised := r.field("roll_values", "appraised")
+	if appraised.Zero != 1 || appraised.Summary == nil || *appraised.Summary != (Summary{Min: 100, Max: 300, Mean: 200}) {
+		t.Errorf("appraised = %+v %+v", appraised, appraised.Summary)
+	}
+	if got := r.field("roll_values", "year").Completeness; got != 1 {
+		t.Errorf("year completeness = %v, want 1", got)
+	}
+	if got := r.field("jurisdictions", "tax_rate"); got.Summary != nil || got.Completeness != 0 {
+		t.Errorf("tax_rate of an empty table = %+v", got)
+	}
+
+	want := map[string]int64{
+		"properties_without_address":           2,
+		"properties_without_coordinates":       1,
+		"properties_never_scraped":             1,
+		"properties_without_roll_values":       1,
+		"roll_values_missing_year":             1,
+		"roll_values_zero_appraised":           1,
+		"land_without_size":                    1,
+		"improvements_without_details":         1,
+		"improvement_details_implausible_year": 1,
+	}
+	if len(r.Checks) != len(want) {
+		t.Fatalf("%d checks, want %d", len(r.Checks), len(want))
+	}
+	for _, c := range r.Checks {
+		if c.Rows != want[c.Name] {
+			t.Errorf("%s = %d, want %d", c.Name, c.Rows, want[c.Name])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"properties: 3 rows", "33.3%", "improvements with no detail rows"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("text report lacks %q:\n%s", s, buf.String())
+		}
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	h := Handler(openTestDB(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/api/quality", nil))
+	var r Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body)
+	}
+	if rec.Code != 200 || len(r.Tables) != len(tables) || r.GeneratedAt.IsZero() {
+		t.Errorf("GET quality = %d %+v", rec.Code, r)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/api/quality?format=text", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") || !strings.Contains(rec.Body.String(), "CHECK") {
+		t.Errorf("GET quality?format=text = %s\n%s", ct, rec.Body)
+	}
+}
@@ -0,0 +1,151 @@
+package refresh
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+)
+
+// MonthDay is a calendar day that recurs every year.
+type MonthDay struct {
+	Month time.Month
+	Day   int
+}
+
+// ParseMonthDay parses "MM-DD", e.g. "04-01" for April 1st.
+func ParseMonthDay(s string) (MonthDay, error) {
+	t, err := time.Parse("01-02", s)
+	if err != nil {
+		return MonthDay{}, fmt.Errorf("invalid month-day %q: %w", s, err)
+	}
+	return MonthDay{Month: t.Month(), Day: t.Day()}, nil
+}
+
+func (m MonthDay) reachedBy(t time.Time) bool {
+	return t.Month() > m.Month || (t.Month() == m.Month && t.Day() >= m.Day)
+}
+
+// Policy decides how stale a property may get before it is queued again.
+type Policy struct {
+	// WatchedInterval applies to properties flagged as watched.
+	WatchedInterval time.Duration
+	// DefaultInterval applies to everything else outside the season.
+	DefaultInterval time.Duration
+	// SeasonInterval replaces DefaultInterval between SeasonStart and
+	// SeasonEnd, when appraisal notices go out and values move.
+	SeasonInterval time.Duration
+	SeasonStart    MonthDay
+	SeasonEnd      MonthDay
+	// BatchSize caps how many properties a single Enqueue call queues.
+	BatchSize int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		WatchedInterval: 24 * time.Hour,
+		DefaultInterval: 30 * 24 * time.Hour,
+		SeasonInterval:  7 * 24 * time.Hour,
+		SeasonStart:     MonthDay{Month: time.April, Day: 1},
+		SeasonEnd:       MonthDay{Month: time.June, Day: 15},
+		BatchSize:       500,
+	}
+}
+
+// InSeason reports whether t falls inside the appraisal-notice season. The
+// end day is exclusive.
+func (p Policy) InSeason(t time.Time) bool {
+	return p.SeasonStart.reachedBy(t) && !p.SeasonEnd.reachedBy(t)
+}
+
+// Interval returns how old a property may get at time now before it is due.
+func (p Policy) Interval(watched bool, now time.Time) time.Duration {
+	interval := p.DefaultInterval
+	if p.InSeason(now) && p.SeasonInterval > 0 && p.SeasonInterval < interval {
+		interval = p.SeasonInterval
+	}
+	if watched && p.WatchedInterval < interval {
+		interval = p.WatchedInterval
+	}
+	return interval
+}
+
+type Planner struct {
+	pdb       *pgdb.Queries
+	policy    Policy
+	detailURL func(propertyID string) string
+}
+
+// NewPlanner returns a planner that queues stale properties into
+// pending_urls. detailURL builds the portal URL for a property, normally
+// (*scraper.Scraper).DetailURL.
+func NewPlanner(db *sql.DB, policy Policy, detailURL func(propertyID string) string) *Planner {
+	return &Planner{
+		pdb:       pgdb.NewFor(db),
+		policy:    policy,
+		detailURL: detailURL,
+	}
+}
+
+// Plan returns the properties due for a refresh at now, watched first, then
+// by priority and finally oldest scrape first. Properties that were never
+// scraped sort ahead of everything else in their group.
+func (p *Planner) Plan(ctx context.Context, now time.Time) ([]pgdb.GetRefreshCandidatesRow, error) {
+	now = now.UTC()
+	return p.pdb.GetRefreshCandidates(ctx, pgdb.GetRefreshCandidatesParams{
+		WatchedBefore: sql.NullTime{Time: now.Add(-p.policy.Interval(true, now)), Valid: true},
+		OthersBefore:  sql.NullTime{Time: now.Add(-p.policy.Interval(false, now)), Valid: true},
+		MaxResults:    int32(p.policy.BatchSize),
+	})
+}
+
+// Enqueue adds every property from Plan to pending_urls and returns how many
+// were queued. URLs already waiting in the queue are left alone.
+func (p *Planner) Enqueue(ctx context.Context, now time.Time) (int, error) {
+	due, err := p.Plan(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		if err := p.pdb.EnqueuePendingURL(ctx, p.detailURL(fmt.Sprint(d.ID))); err != nil {
+			return 0, err
+		}
+	}
+	if len(due) > 0 {
+		log.Printf("refresh: queued %d stale properties (season: %t)", len(due), p.policy.InSeason(now))
+	}
+	return len(due), nil
+}
+
+// Watch flags a property for daily refreshes. Higher priorities are queued
+// first when more properties are due than fit in a batch. It returns
+// storage.ErrNotFound for a property that hasn't been scraped.
+func (p *Planner) Watch(ctx context.Context, propertyID int32, priority int32) error {
+	return p.setWatched(ctx, pgdb.SetPropertyWatchedParams{
+		Watched:         true,
+		RefreshPriority: priority,
+		ID:              propertyID,
+	})
+}
+
+func (p *Planner) Unwatch(ctx context.Context, propertyID int32) error {
+	return p.setWatched(ctx, pgdb.SetPropertyWatchedParams{
+		Watched: false,
+		ID:      propertyID,
+	})
+}
+
+func (p *Planner) setWatched(ctx context.Context, arg pgdb.SetPropertyWatchedParams) error {
+	n, err := p.pdb.SetPropertyWatched(ctx, arg)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("property %d: %w", arg.ID, storage.ErrNotFound)
+	}
+	return nil
+}
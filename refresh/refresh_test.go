@@ -0,0 +1,119 @@
+package refresh
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+)
+
+func Test_PolicyInterval(t *testing.T) {
+	p := DefaultPolicy()
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		watched bool
+		now     time.Time
+		want    time.Duration
+	}{
+		{"winter", false, time.Date(2022, time.January, 10, 0, 0, 0, 0, time.UTC), 30 * day},
+		{"season start", false, time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC), 7 * day},
+		{"mid season", false, time.Date(2022, time.May, 20, 0, 0, 0, 0, time.UTC), 7 * day},
+		{"season end is exclusive", false, time.Date(2022, time.June, 15, 0, 0, 0, 0, time.UTC), 30 * day},
+		{"watched in winter", true, time.Date(2022, time.January, 10, 0, 0, 0, 0, time.UTC), day},
+		{"watched in season", true, time.Date(2022, time.May, 20, 0, 0, 0, 0, time.UTC), day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Interval(tt.watched, tt.now); got != tt.want {
+				t.Errorf("Interval() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_PlannerEnqueue(t *testing.T) {
+	db := migratetest.Open(t)
+
+	now := time.Date(2022, time.January, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	seed := []struct {
+		id       int
+		scraped  sql.NullTime
+		watched  bool
+		priority int
+	}{
+		{1, sql.NullTime{Time: now.Add(-2 * day), Valid: true}, false, 0},  // fresh
+		{2, sql.NullTime{Time: now.Add(-40 * day), Valid: true}, false, 0}, // stale
+		{3, sql.NullTime{}, false, 0},                                      // never scraped
+		{4, sql.NullTime{Time: now.Add(-2 * day), Valid: true}, true, 1},   // watched, due
+		{5, sql.NullTime{Time: now.Add(-2 * day), Valid: true}, true, 5},   // watched, due, higher priority
+		{6, sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, true, 9}, // watched, fresh
+	}
+	for _, s := range seed {
+		if _, err := db.Exec(`insert into properties(id, last_scraped_at, watched, refresh_priority) values($1, $2, $3, $4)`,
+			s.id, s.scraped, s.watched, s.priority); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewPlanner(db, DefaultPolicy(), func(id string) string { return "prop_id=" + id })
+	due, err := p.Plan(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int32
+	for _, d := range due {
+		got = append(got, d.ID)
+	}
+	if want := []int32{5, 4, 3, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Plan() = %v, want %v", got, want)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Enqueue(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var queued int
+	if err := db.QueryRow(`select count(*) from pending_urls`).Scan(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued != 4 {
+		t.Errorf("pending_urls rows = %d, want 4", queued)
+	}
+}
+
+func Test_PlannerWatch(t *testing.T) {
+	db := migratetest.Open(t)
+	if _, err := db.Exec(`insert into properties(id) values(1)`); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlanner(db, DefaultPolicy(), func(id string) string { return "prop_id=" + id })
+	ctx := context.Background()
+
+	if err := p.Watch(ctx, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	var watched bool
+	var priority int
+	if err := db.QueryRow(`select watched, refresh_priority from properties where id = 1`).Scan(&watched, &priority); err != nil {
+		t.Fatal(err)
+	}
+	if !watched || priority != 3 {
+		t.Errorf("watched, refresh_priority = %v, %d, want true, 3", watched, priority)
+	}
+
+	if err := p.Watch(ctx, 2, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Watch(unknown id) = %v, want ErrNotFound", err)
+	}
+	if err := p.Unwatch(ctx, 2); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Unwatch(unknown id) = %v, want ErrNotFound", err)
+	}
+}
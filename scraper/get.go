@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// DetailURLForYear is DetailURL for a past tax year. A year of zero means the
+// portal's current year.
+func (s *Scraper) DetailURLForYear(propertyID string, year int) string {
+	if year == 0 {
+		return s.DetailURL(propertyID)
+	}
+	return fmt.Sprintf("%s&year=%d", s.DetailURL(propertyID), year)
+}
+
+// Get fetches and parses a single property outside the worker pool, going
+// through the same warm-up, proxy and politeness handling as Scrape. It
+// returns the record along with the raw detail page. Nothing is written; pass
+// the record to the sink yourself to keep it.
+func (s *Scraper) Get(propertyID string, year int) (tax.PropertyRecord, []byte, error) {
+	j := Job{
+		URL:            s.DetailURLForYear(propertyID, year),
+		PropertyRecord: tax.PropertyRecord{PropertyID: propertyID},
+		Scraper:        s,
+		Force:          true,
+	}
+	if !j.fetch() {
+		return tax.PropertyRecord{}, nil, j.Error
+	}
+	if j.ResponseBodyBuffer == nil {
+		return tax.PropertyRecord{}, nil, errors.New("nil response body")
+	}
+	// parse drains the buffer, so keep a copy of the page first.
+	page := append([]byte(nil), j.ResponseBodyBuffer.Bytes()...)
+	if !j.parse() {
+		return tax.PropertyRecord{}, page, j.Error
+	}
+	return j.PropertyRecord, page, nil
+}
+
+// Save writes a record to the scraper's sink on its own.
+func (s *Scraper) Save(pr tax.PropertyRecord) error {
+	return s.writeRecords([]tax.PropertyRecord{pr})
+}
@@ -0,0 +1,199 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// Scrape runs urls through three bounded stages: s.workers fetchers download
+// detail pages, s.parsers parsers turn them into records and a single writer
+// commits records s.batchSize at a time. Each stage only blocks on the next
+// when its buffer is full, so a slow database no longer holds up the network
+// workers. Jobs that fail in any stage skip straight to the results.
+func (s *Scraper) Scrape(urls []string) {
+	var throttle <-chan time.Time
+	if s.jobsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / s.jobsPerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	jobs := make(chan Job)
+	fetched := make(chan Job, s.parsers)
+	parsed := make(chan Job, s.batchSize)
+	done := make(chan Job, s.workers)
+
+	go func() {
+		for i, u := range urls {
+
+			var propID = ""
+			parts := strings.Split(u, "=")
+			if len(parts) > 1 {
+				propID = parts[2]
+			}
+			if propID == "" {
+				continue
+			}
+			jobs <- Job{
+				JobID:          i,
+				URL:            u,
+				PropertyRecord: tax.PropertyRecord{PropertyID: propID},
+				Scraper:        s,
+			}
+		}
+		close(jobs)
+	}()
+
+	fetchers := &sync.WaitGroup{}
+	fetchers.Add(s.workers)
+	for i := 1; i <= s.workers; i++ {
+		go func(id int) {
+			defer fetchers.Done()
+			s.fetchStage(id, jobs, fetched, done, throttle)
+		}(i)
+	}
+	go func() {
+		fetchers.Wait()
+		close(fetched)
+	}()
+
+	parsers := &sync.WaitGroup{}
+	parsers.Add(s.parsers)
+	for i := 0; i < s.parsers; i++ {
+		go func() {
+			defer parsers.Done()
+			s.parseStage(fetched, parsed, done)
+		}()
+	}
+	go func() {
+		parsers.Wait()
+		close(parsed)
+	}()
+
+	// The writer is the last stage to finish, so once it returns nothing else
+	// can send on done.
+	go func() {
+		s.writeStage(parsed, done)
+		close(done)
+	}()
+
+	for r := range done {
+		metrics.ObserveJob(r.Started, r.Error)
+		if r.Error == nil {
+			r.Error = errors.New("No Error")
+		}
+		proxy := r.Proxy.IP
+		if proxy == "" {
+			proxy = noProxy
+		}
+		fmt.Fprintf(s.log, resultLine, r.ProcessorID, r.JobID, r.PropertyRecord.PropertyID, proxy, r.Error)
+	}
+}
+
+func (s *Scraper) fetchStage(id int, in <-chan Job, out, done chan<- Job, throttle <-chan time.Time) {
+	for j := range in {
+		if throttle != nil {
+			<-throttle
+		}
+		j.ProcessorID = id
+		j.Started = time.Now()
+		if j.fetch() {
+			out <- j
+		} else {
+			done <- j
+		}
+		time.Sleep(s.workerDelay)
+	}
+}
+
+func (s *Scraper) parseStage(in <-chan Job, out, done chan<- Job) {
+	for j := range in {
+		if j.parse() {
+			out <- j
+		} else {
+			done <- j
+		}
+	}
+}
+
+// writeStage collects parsed jobs into batches and writes a batch once it is
+// full or has waited s.flushInterval.
+func (s *Scraper) writeStage(in <-chan Job, done chan<- Job) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var batch []Job
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.writeBatch(batch)
+		for _, j := range batch {
+			done <- j
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case j, ok := <-in:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, j)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// writeBatch hands every record in batch to the sink in one call. If that
+// fails the records are retried one at a time, so a single bad record only
+// fails its own job.
+func (s *Scraper) writeBatch(batch []Job) {
+	records := make([]tax.PropertyRecord, len(batch))
+	urls := make([]string, len(batch))
+	for i, j := range batch {
+		records[i] = j.PropertyRecord
+		urls[i] = j.URL
+	}
+
+	if err := s.writeRecords(records); err != nil {
+		log.Printf("writer: batch of %d failed, retrying one at a time: %s", len(batch), err)
+		for i := range batch {
+			batch[i].persist()
+		}
+		return
+	}
+
+	if s.db == nil {
+		return
+	}
+	if err := s.pdb.RemovePendingURLs(context.Background(), urls); err != nil {
+		for i := range batch {
+			batch[i].Error = err
+			batch[i].ProcessError(false, "j.Scraper.pdb.RemovePendingURLs", err)
+		}
+		return
+	}
+	fmt.Fprintf(s.log, "writer: %d records committed\n", len(batch))
+}
+
+func (s *Scraper) writeRecords(records []tax.PropertyRecord) error {
+	if s.sink == nil {
+		return errors.New("no record sink set")
+	}
+	return s.sink.WriteRecords(context.Background(), records)
+}
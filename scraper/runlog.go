@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+)
+
+// resultLine is what Scrape prints for every finished job. ReadProxyStats
+// parses it back out of run logs, so the two must change together.
+const resultLine = "worker: %d   job: %d propertyID: %s  proxy: %s  final error: %s\n"
+
+// noProxy stands in for the proxy of a job that never leased one or went out
+// directly.
+const noProxy = "-"
+
+var (
+	resultLineRE = regexp.MustCompile(`job: \d+ propertyID: \S*\s+proxy: (\S+)\s+final error: (.*)$`)
+	// requestErrRE strips the method and URL net/http puts in front of
+	// transport errors, so the same failure on different properties groups.
+	requestErrRE = regexp.MustCompile(`^[A-Za-z]+ "[^"]*": `)
+)
+
+// ProxyRunStats is how the jobs sent through one proxy fared in run logs.
+type ProxyRunStats struct {
+	Proxy     string
+	Jobs      int
+	Succeeded int
+	// Errors counts failed jobs by error message.
+	Errors map[string]int
+}
+
+// ReadProxyStats tallies the job results in a scraper run log by proxy.
+// Jobs that didn't go through a proxy are left out.
+func ReadProxyStats(r io.Reader, stats map[string]*ProxyRunStats) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		m := resultLineRE.FindStringSubmatch(sc.Text())
+		if m == nil || m[1] == noProxy {
+			continue
+		}
+		st, ok := stats[m[1]]
+		if !ok {
+			st = &ProxyRunStats{Proxy: m[1], Errors: map[string]int{}}
+			stats[m[1]] = st
+		}
+		st.Jobs++
+		if m[2] == "No Error" {
+			st.Succeeded++
+			continue
+		}
+		st.Errors[requestErrRE.ReplaceAllString(m[2], "")]++
+	}
+	return sc.Err()
+}
@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_ReadProxyStats(t *testing.T) {
+	var log strings.Builder
+	for _, l := range []struct {
+		proxy, err string
+	}{
+		{"10.0.0.1:8080", "No Error"},
+		{"10.0.0.1:8080", "No Error"},
+		{"10.0.0.1:8080", `Get "https://example.com/clientdb/?cid=56": proxyconnect tcp: dial tcp 10.0.0.1:8080: connect: connection refused`},
+		{"10.0.0.1:8080", `Get "https://example.com/clientdb/Property.aspx?cid=56&prop_id=7": proxyconnect tcp: dial tcp 10.0.0.1:8080: connect: connection refused`},
+		{"10.0.0.2:8080", "403 Forbidden"},
+		{noProxy, "duplicate ID"},
+	} {
+		fmt.Fprintf(&log, "worker: 1   jobID: 3  parsing property details\n")
+		fmt.Fprintf(&log, resultLine, 1, 3, "2163", l.proxy, l.err)
+	}
+
+	stats := map[string]*ProxyRunStats{}
+	if err := ReadProxyStats(strings.NewReader(log.String()), stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got stats for %d proxies, want 2", len(stats))
+	}
+	st := stats["10.0.0.1:8080"]
+	if st.Jobs != 4 || st.Succeeded != 2 {
+		t.Errorf("10.0.0.1:8080 jobs = %d succeeded = %d, want 4 and 2", st.Jobs, st.Succeeded)
+	}
+	if n := st.Errors["proxyconnect tcp: dial tcp 10.0.0.1:8080: connect: connection refused"]; n != 2 {
+		t.Errorf("errors = %v, want the two refused connections grouped", st.Errors)
+	}
+	if st := stats["10.0.0.2:8080"]; st.Jobs != 1 || st.Errors["403 Forbidden"] != 1 {
+		t.Errorf("10.0.0.2:8080 = %+v", st)
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
-	"net/url"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
@@ -19,24 +19,60 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/jason-costello/taxcollector/metrics"
+	"github.com/jason-costello/taxcollector/politeness"
 	"github.com/jason-costello/taxcollector/proxies"
+	"github.com/jason-costello/taxcollector/sink"
+	"github.com/jason-costello/taxcollector/storage"
 	"github.com/jason-costello/taxcollector/storage/pgdb"
 	"github.com/jason-costello/taxcollector/tax"
 	"github.com/jason-costello/taxcollector/useragents"
 	"golang.org/x/net/publicsuffix"
 )
 
-// TODO:  Add flags to pull in db creds, worker count,
+const (
+	DefaultPortalURL = "https://propaccess.trueautomation.com"
+	DefaultClientID  = "56"
+)
+
+// proxyWait is how long a job waits for a proxy while all of them are in use.
+const proxyWait = time.Minute
+
+// A property scraped more recently than this is treated as a duplicate if its
+// URL turns up in pending_urls again; anything older is re-scraped in place.
+const minRescrapeAge = time.Hour
+
 type Scraper struct {
-	proxyClient     *proxies.ProxyClient
-	db              *sql.DB
-	pdb             *pgdb.Queries
-	userAgentClient *useragents.UserAgentClient
-	httpClient      *http.Client
-	currentProxy    proxies.Proxy
+	proxyProvider proxies.ProxyProvider
+	db            *sql.DB
+	pdb           *pgdb.Queries
+	store         storage.PropertyStore
+	userAgents    useragents.UserAgentProvider
+	httpClient    *http.Client
+	// transports caches one transport per proxy URL so connections through
+	// a proxy are reused across jobs.
+	transportsMu  sync.Mutex
+	transports    map[string]*http.Transport
+	portalURL     string
+	clientID      string
+	workers       int
+	jobsPerSecond float64
+	workerDelay   time.Duration
+	parsers       int
+	batchSize     int
+	flushInterval time.Duration
+	sink          sink.RecordSink
+	politeness    *politeness.Policy
+	// log takes the progress lines of every job, stdout by default.
+	log io.Writer
 }
 
-func NewScraper(proxyClient *proxies.ProxyClient, uac *useragents.UserAgentClient, db *sql.DB, httpClient *http.Client) *Scraper {
+// NewScraper returns a scraper that writes to db. db may be nil when a file
+// sink is set with SetSink, in which case pending_urls bookkeeping is
+// skipped, and so are duplicate checks unless SetStore supplies a store.
+// Each job leases its proxy from pp; a nil pp fetches without a proxy. A nil
+// uap rotates the built-in user agents.
+func NewScraper(pp proxies.ProxyProvider, uap useragents.UserAgentProvider, db *sql.DB, httpClient *http.Client) *Scraper {
 	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
 		log.Fatal(err)
@@ -47,80 +83,127 @@ func NewScraper(proxyClient *proxies.ProxyClient, uac *useragents.UserAgentClien
 
 	httpClient.Jar = jar
 
-	return &Scraper{
-		httpClient:      httpClient,
-		proxyClient:     proxyClient,
-		userAgentClient: uac,
-		db:              db,
-		pdb:             pgdb.New(db),
-	}
+	s := &Scraper{
+		httpClient:    httpClient,
+		userAgents:    uap,
+		db:            db,
+		pdb:           pgdb.NewFor(db),
+		portalURL:     DefaultPortalURL,
+		clientID:      DefaultClientID,
+		workers:       runtime.NumCPU(),
+		workerDelay:   time.Second,
+		parsers:       runtime.NumCPU(),
+		batchSize:     50,
+		flushInterval: 2 * time.Second,
+		transports:    map[string]*http.Transport{},
+		log:           os.Stdout,
+	}
+	if pp == nil {
+		pp = proxies.Direct{}
+	}
+	s.proxyProvider = pp
+	if uap == nil {
+		s.userAgents = useragents.Default()
+	}
+	if db != nil {
+		s.store = storage.NewSQL(db)
+		s.sink = sink.NewStore(s.store)
+	}
+	return s
 }
 
-func (s *Scraper) Scrape(urls []string) {
-	var workers = runtime.NumCPU()
+// SetStore replaces where records are written and duplicates looked up,
+// the database by default. A sink set later with SetSink still takes the
+// records.
+func (s *Scraper) SetStore(ps storage.PropertyStore) {
+	s.store = ps
+	s.sink = sink.NewStore(ps)
+}
 
-	jobChannel := make(chan Job)
+// SetSink replaces where parsed records are written, the database by default.
+func (s *Scraper) SetSink(rs sink.RecordSink) {
+	s.sink = rs
+}
 
-	go func() {
-		for i, u := range urls {
+// SetLog sends the scraper's progress lines to w instead of stdout.
+func (s *Scraper) SetLog(w io.Writer) {
+	s.log = w
+}
 
-			var propID = ""
-			parts := strings.Split(u, "=")
-			if len(parts) > 1 {
-				propID = parts[2]
-			}
-			if propID == "" {
-				continue
-			}
-			jobChannel <- Job{
-				ProcessorID:        0,
-				JobID:              i,
-				URL:                u,
-				Proxy:              proxies.Proxy{},
-				UserAgent:          "",
-				Request:            nil,
-				ResponseBodyBuffer: nil,
-				PropertyRecord:     tax.PropertyRecord{PropertyID: propID},
-				Duplicate:          false,
-				Error:              nil,
-				Scraper:            s,
-			}
-		}
-	}()
-	jobResultsChan := make(chan Job)
-	wg := &sync.WaitGroup{}
-	wg.Add(workers)
-	go func() {
-		wg.Wait()
-		close(jobResultsChan)
-	}()
-
-	for i := 1; i <= workers; i++ {
-		go func(id int) {
-			defer wg.Done()
-
-			for j := range jobChannel {
-				j.ProcessorID = id
-				j.Process()
-				jobResultsChan <- j
-				time.Sleep(time.Second)
+func (s *Scraper) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.workers = n
+}
 
-			}
-		}(i)
+// SetRateLimit caps how many jobs start per second across all workers (0
+// disables the cap) and how long each worker waits between its own jobs.
+func (s *Scraper) SetRateLimit(jobsPerSecond float64, workerDelay time.Duration) {
+	s.jobsPerSecond = jobsPerSecond
+	s.workerDelay = workerDelay
+}
+
+// SetPipeline sizes the stages after the fetchers: how many goroutines parse
+// pages, and how many records the writer commits per transaction. A partial
+// batch is written once it has waited flushInterval.
+func (s *Scraper) SetPipeline(parsers, batchSize int, flushInterval time.Duration) {
+	if parsers < 1 {
+		parsers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
 	}
+	s.parsers = parsers
+	s.batchSize = batchSize
+	s.flushInterval = flushInterval
+}
 
-	for r := range jobResultsChan {
-		if r.Error == nil {
-			r.Error = errors.New("No Error")
-		}
-		fmt.Printf("worker: %d   job: %d propertyID: %s  final error: %s\n", r.ProcessorID, r.JobID, r.PropertyRecord.PropertyID, r.Error)
+// SetPoliteness makes every portal request honour p: robots.txt, its
+// Crawl-delay and the daily budget. Requests then carry p's contact
+// User-Agent instead of a rotated browser one.
+func (s *Scraper) SetPoliteness(p *politeness.Policy) {
+	s.politeness = p
+}
+
+// polite blocks until the politeness policy, if one is set, allows req and
+// labels req with the contact User-Agent.
+func (s *Scraper) polite(req *http.Request) error {
+	if s.politeness == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.politeness.LoadRobots(ctx, s.httpClient, s.portalURL); err != nil {
+		return err
+	}
+	// Not req's context: with a long Crawl-delay and several workers the
+	// wait alone can outlast the request timeout.
+	if err := s.politeness.Wait(context.Background(), req.URL); err != nil {
+		return err
 	}
+	req.Header.Set("User-Agent", s.politeness.UserAgent())
+	return nil
+}
 
+// SetPortal points the scraper at a different PropAccess host and county
+// client id, e.g. a local fakeportal server.
+func (s *Scraper) SetPortal(portalURL, clientID string) {
+	s.portalURL = strings.TrimRight(portalURL, "/")
+	s.clientID = clientID
+}
+
+func (s *Scraper) warmupURL() string {
+	return fmt.Sprintf("%s/clientdb/?cid=%s", s.portalURL, s.clientID)
+}
+
+func (s *Scraper) DetailURL(propertyID string) string {
+	return fmt.Sprintf("%s/clientdb/Property.aspx?cid=%s&prop_id=%s", s.portalURL, s.clientID, propertyID)
 }
 
 func (s *Scraper) PropertyExists(url string) (bool, error) {
-	if s.db == nil {
-		return true, errors.New("db is nil")
+	if s.store == nil {
+		return true, errors.New("no property store")
 	}
 	urlParts := strings.Split(url, "prop_id=")
 	if len(urlParts) < 2 {
@@ -136,244 +219,75 @@ func (s *Scraper) PropertyExists(url string) (bool, error) {
 	if pid == 0 {
 		return true, errors.New("invalid property id: 0")
 	}
-	prop, err := s.pdb.GetPropertyByID(context.Background(), int32(pid))
+	prop, err := s.store.Property(context.Background(), int32(pid))
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
+		if errors.Is(err, storage.ErrNotFound) {
 			return false, nil
 		}
 		return false, err
 	}
-	if prop.Address.Valid && prop.Address.String != "" {
-		return true, nil
-	}
-	return false, nil
+	return prop.Address != "", nil
 }
 
-func (s *Scraper) changeUserAgent(req *http.Request) error {
-	ua, err := s.userAgentClient.GetRandomUserAgent()
-	if err != nil {
-		return err
-	}
-	req.Header.Set("User-Agent", ua)
-	return nil
+// clientFor returns a client that sends requests through pr and shares the
+// scraper's cookie jar. A job without a proxy uses the scraper's own client.
+func (s *Scraper) clientFor(pr proxies.Proxy) (*http.Client, error) {
+	if pr.IP == "" {
+		return s.httpClient, nil
+	}
+	var base *http.Transport
+	switch t := s.httpClient.Transport.(type) {
+	case nil:
+	case *http.Transport:
+		base = t
+	default:
+		return nil, fmt.Errorf("can't route %T through a proxy", t)
+	}
+
+	key := pr.ProxyURL().String()
+	s.transportsMu.Lock()
+	defer s.transportsMu.Unlock()
+	t, ok := s.transports[key]
+	if !ok {
+		var err error
+		if t, err = proxies.Transport(pr, base); err != nil {
+			return nil, err
+		}
+		s.transports[key] = t
+	}
+	return &http.Client{
+		Transport:     t,
+		Jar:           s.httpClient.Jar,
+		CheckRedirect: s.httpClient.CheckRedirect,
+		Timeout:       s.httpClient.Timeout,
+	}, nil
 }
 
-func (s *Scraper) addProxy() error {
-	p, err := s.proxyClient.GetNext()
-	if err != nil {
-		return err
-	}
-	proxyStr := fmt.Sprintf("http://%s", p.IP)
-
-	proxyURL, err := url.Parse(proxyStr)
-	if err != nil {
-		return err
-	}
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxyURL),
-	}
-	s.currentProxy = p
-	s.httpClient.Transport = transport
-	return nil
-}
+// errTruncated is returned for a detail page that stops before its end, as
+// when the portal drops the connection mid-response. Parsing what did arrive
+// would store a property missing whatever came after the cut.
+var errTruncated = errors.New("truncated response: no closing </html> tag")
 
 func parseDetails(b *bytes.Buffer) (tax.PropertyRecord, error) {
+	if !bytes.Contains(bytes.ToLower(b.Bytes()), []byte("</html>")) {
+		return tax.PropertyRecord{}, errTruncated
+	}
 
 	doc, err := goquery.NewDocumentFromReader(b)
 	if err != nil {
-		return tax.PropertyRecord{}, nil
+		return tax.PropertyRecord{}, err
 	}
 	propertyRecord, err := tax.GetPropertyRecord(doc)
-	return propertyRecord, nil
-}
-func stringToNullInt32(s string) sql.NullInt32 {
-	i, err := strconv.Atoi(s)
-	if err != nil {
-		i = 0
-	}
-	return sql.NullInt32{
-		Int32: int32(i),
-		Valid: true,
-	}
-}
-
-func stringToFloat64(s string) sql.NullFloat64 {
-	i, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		i = 0.0
-	}
-	return sql.NullFloat64{
-		Float64: i,
-		Valid:   true,
-	}
-}
-
-func stringToNullString(s string) sql.NullString {
-	return sql.NullString{
-		String: s,
-		Valid:  true,
-	}
-}
-func insertLand(pdb *pgdb.Queries, pr tax.PropertyRecord, tx *sql.Tx) error {
-
-	for _, i := range pr.Land {
-
-		landParams := pgdb.InsertLandParams{
-			Number:      stringToNullInt32(i.Number),
-			LandType:    stringToNullString(i.Type),
-			Description: stringToNullString(i.Description),
-			Acres:       stringToFloat64(i.Acres),
-			SquareFeet:  stringToFloat64(i.Sqft),
-			EffFront:    stringToFloat64(i.EffFront),
-			EffDepth:    stringToFloat64(i.EffDepth),
-			MarketValue: stringToNullInt32(i.MarketValue),
-			PropertyID:  stringToNullInt32(pr.PropertyID),
-		}
-		if err := pdb.WithTx(tx).InsertLand(context.Background(), landParams); err != nil {
-			tx.Rollback()
-			return err
-		}
-
-	}
-
-	return nil
-}
-
-func (s *Scraper) AddPropertyRecordToDB(workerID, jobID int, pUrl string, pr tax.PropertyRecord) error {
-
-	tx, err := s.db.Begin()
-	if err != nil {
-		return fmt.Errorf("worker: %d  job: %d propID: %s - error s.db.Begin() error: %w\n", workerID, jobID, pr.PropertyID, err)
-	}
-
-	err = insertPropertyRecord(s.pdb, pr, tx)
-	if err != nil {
-		return fmt.Errorf("worker: %d  job: %d propID: %s - insertPropertyRecord error: %w\n", workerID, jobID, pr.PropertyID, err)
-		tx.Rollback()
-
-		return err
-	}
-
-	err = insertRollValues(s.pdb, pr, tx)
-	if err != nil {
-		return fmt.Errorf("worker: %d  job: %d  propID: %s - Status: insertRollValues error: %w\n", workerID, jobID, pr.PropertyID, err)
-		tx.Rollback()
-		return err
-	}
-
-	err = insertJurisdictions(s.pdb, pr, tx)
-	if err != nil {
-		return fmt.Errorf("worker: %d  job: %d propID: %s - Status: insertJurisdictions error: %w\n", workerID, jobID, pr.PropertyID, err)
-		tx.Rollback()
-		return err
-	}
-
-	err = insertImprovements(s.pdb, pr, tx)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("worker: %d  job: %d  propID: %s - Status: insertImprovements error: %w\n", workerID, jobID, pr.PropertyID, err)
-	}
-
-	err = insertLand(s.pdb, pr, tx)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("worker: %d  job: %d propID: %s - Status: insertLand error: %w\n", workerID, jobID, pr.PropertyID, err)
+		return tax.PropertyRecord{}, err
 	}
-
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
-		return fmt.Errorf("worker: %d  job: %d  propID: %s - Error on tx.Commit: %w\n", workerID, jobID, pr.PropertyID, err)
+	if propertyRecord.PropertyID == "" {
+		return tax.PropertyRecord{}, errors.New("no property details found in response")
 	}
-	fmt.Printf("worker: %d  job: %d  propID: %s - All records committed\n", workerID, jobID, pr.PropertyID)
-
-	return nil
-}
-
-func insertImprovements(pdb *pgdb.Queries, pr tax.PropertyRecord, tx *sql.Tx) error {
-
-	for _, i := range pr.Improvements {
-		params := pgdb.InsertImprovementParams{
-			Name:        stringToNullString(i.Name),
-			Description: stringToNullString(i.Description),
-			StateCode:   stringToNullString(i.StateCode),
-			LivingArea:  stringToNullInt32(i.LivingArea),
-			Value:       stringToNullInt32(i.Value),
-			PropertyID:  stringToNullInt32(pr.PropertyID),
-		}
-
-		id, err := pdb.WithTx(tx).InsertImprovement(context.Background(), params)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-
-		for _, d := range i.Details {
-			paramDetails := pgdb.InsertImprovementDetailParams{
-				ImprovementID:   sql.NullInt32{Int32: id, Valid: true},
-				ImprovementType: stringToNullString(d.Type),
-				Description:     stringToNullString(d.Description),
-				Class:           stringToNullString(d.Class),
-				ExteriorWall:    stringToNullString(d.ExteriorWall),
-				YearBuilt:       stringToNullInt32(d.YearBuilt),
-				SquareFeet:      stringToNullInt32(d.SqFt),
-			}
-
-			if err := pdb.WithTx(tx).InsertImprovementDetail(context.Background(), paramDetails); err != nil {
-				tx.Rollback()
-				return err
-			}
-
-		}
-	}
-	return nil
-}
-
-func insertJurisdictions(pdb *pgdb.Queries, pr tax.PropertyRecord, tx *sql.Tx) error {
-	for _, j := range pr.Jurisdictions {
-
-		params := pgdb.InsertJurisdictionParams{
-			Entity:         sql.NullString{},
-			Description:    sql.NullString{},
-			TaxRate:        stringToNullInt32(j.TaxRate),
-			AppraisedValue: stringToNullInt32(j.AppraisedValue),
-			TaxableValue:   stringToNullInt32(j.TaxableValue),
-			EstimatedTax:   stringToNullInt32(j.EstimatedTax),
-			PropertyID:     stringToNullInt32(pr.PropertyID),
-		}
-
-		if err := pdb.WithTx(tx).InsertJurisdiction(context.Background(), params); err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
-	return nil
-
+	return propertyRecord, nil
 }
-func insertRollValues(pdb *pgdb.Queries, pr tax.PropertyRecord, tx *sql.Tx) error {
-
-	for _, r := range pr.RollValue {
-
-		rollParams := pgdb.InsertRollValueParams{
-			Year:         stringToNullInt32(r.Year),
-			Improvements: stringToNullInt32(r.Improvements),
-			LandMarket:   stringToNullInt32(r.LandMarket),
-			AgValuation:  stringToNullInt32(r.AgValuation),
-			Appraised:    stringToNullInt32(r.Appraised),
-			HomesteadCap: stringToNullInt32(r.HomesteadCap),
-			Assessed:     stringToNullInt32(r.Assessed),
-			PropertyID:   stringToNullInt32(pr.PropertyID),
-		}
 
-		if err := pdb.WithTx(tx).InsertRollValue(context.Background(), rollParams); err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
-	return nil
-}
 func getRandomTimeoutDuration(min, max int) time.Duration {
-	rand.Seed(time.Now().UnixNano())
-
 	i := rand.Intn(max-min) + min
 
 	d, e := time.ParseDuration(fmt.Sprintf("%dms", i))
@@ -383,43 +297,6 @@ func getRandomTimeoutDuration(min, max int) time.Duration {
 	return d
 }
 
-func stringToInt32(s string) int32 {
-
-	i, e := strconv.Atoi(s)
-	if e != nil {
-		return int32(0)
-	}
-	return int32(i)
-
-}
-func insertPropertyRecord(pdb *pgdb.Queries, pr tax.PropertyRecord, tx *sql.Tx) error {
-
-	propParams := pgdb.InsertPropertyRecordParams{
-		ID:                  stringToInt32(pr.PropertyID),
-		OwnerID:             stringToNullInt32(pr.OwnerID),
-		OwnerName:           stringToNullString(pr.OwnerName),
-		OwnerMailingAddress: stringToNullString(pr.OwnerMailingAddress),
-		Zoning:              stringToNullString(pr.Zoning),
-		NeighborhoodCd:      stringToNullString(pr.NeighborhoodCD),
-		Neighborhood:        stringToNullString(pr.Neighborhood),
-		Address:             stringToNullString(pr.Address),
-		LegalDescription:    stringToNullString(pr.LegalDescription),
-		GeographicID:        stringToNullString(pr.GeographicID),
-		Exemptions:          stringToNullString(pr.Exemptions),
-		OwnershipPercentage: stringToFloat64(pr.OwnershipPercentage),
-		MapscoMapID:         stringToNullString(pr.MapscoMapID),
-	}
-	if err := pdb.WithTx(tx).InsertPropertyRecord(context.Background(), propParams); err != nil {
-		fmt.Printf("propID: %s     Err property insert:  %s\n", pr.PropertyID, err)
-		fmt.Printf("%#+v\n", propParams)
-		tx.Rollback()
-		return err
-	}
-
-	return nil
-
-}
-
 type Job struct {
 	ProcessorID        int
 	JobID              int
@@ -432,85 +309,170 @@ type Job struct {
 	Duplicate          bool
 	Error              error
 	Scraper            *Scraper
+	// client sends the job's requests, through Proxy when one is set.
+	client *http.Client
+	// lease holds Proxy for the job; proxyFailed, proxyLatency and
+	// proxyRequests collect what to report when it is released.
+	lease         *proxies.Lease
+	proxyFailed   bool
+	proxyLatency  time.Duration
+	proxyRequests int
+	// Started is when the job left the queue, for the job duration metric.
+	Started time.Time
+	// Force fetches the property even if it was scraped moments ago.
+	Force bool
 }
 
 func (j *Job) ProcessError(removeURL bool, fun string, nerr error) error {
-	if removeURL {
+	if removeURL && j.Scraper.db != nil {
 		if err := j.Scraper.pdb.RemovePendingURL(context.Background(), j.URL); err != nil {
 			return err
 		}
 	}
-	fmt.Printf("worker: %d   job: %d   propertyID: %s  function: %s  error during processing: %s\n", j.ProcessorID, j.JobID, j.PropertyRecord.PropertyID, fun, nerr)
+	fmt.Fprintf(j.Scraper.log, "worker: %d   job: %d   propertyID: %s  function: %s  error during processing: %s\n", j.ProcessorID, j.JobID, j.PropertyRecord.PropertyID, fun, nerr)
 	return nil
 }
+
+// recordProxyHealth notes the outcome of a request through the job's proxy.
+// Transport errors and the statuses a blocked proxy gets count against it;
+// other failures are the portal's.
+func (j *Job) recordProxyHealth(resp *http.Response, err error, latency time.Duration) {
+	if j.Proxy.IP == "" {
+		return
+	}
+	blocked := err != nil
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusForbidden, http.StatusProxyAuthRequired, http.StatusTooManyRequests:
+			blocked = true
+		}
+	}
+	if blocked {
+		j.proxyFailed = true
+		return
+	}
+	j.proxyLatency += latency
+	j.proxyRequests++
+}
+
+// releaseProxy gives the job's proxy back along with how its requests went.
+func (j *Job) releaseProxy() {
+	if j.lease == nil {
+		return
+	}
+	outcome, latency := proxies.Unused, time.Duration(0)
+	switch {
+	case j.proxyFailed:
+		outcome = proxies.Failed
+		fmt.Fprintf(j.Scraper.log, "worker: %d   jobID: %d  proxy %s failed, cooling down\n", j.ProcessorID, j.JobID, j.Proxy.IP)
+		metrics.ProxyCooldowns.Inc()
+	case j.proxyRequests > 0:
+		outcome = proxies.Succeeded
+		latency = j.proxyLatency / time.Duration(j.proxyRequests)
+	}
+	if err := j.lease.Release(outcome, latency); err != nil {
+		j.ProcessError(false, "j.lease.Release", err)
+	}
+	j.lease = nil
+}
+
+// Process runs a job through every stage on the calling goroutine. Scrape
+// instead hands each stage to its own pool of goroutines.
 func (j *Job) Process() {
+	if j.fetch() && j.parse() {
+		j.persist()
+	}
+}
+
+// fetch checks the property isn't a fresh duplicate, then warms up a portal
+// session and downloads the detail page into ResponseBodyBuffer.
+func (j *Job) fetch() bool {
 
 	var propID int
-	var property pgdb.Property
+	var property storage.Property
 
 	if j.PropertyRecord.PropertyID == "" {
-		j.ProcessError(false, "strconv.Atoi(j.PropertyRecord.PropertyID)", errors.New("no property record id set"))
-		return
+		j.Error = errors.New("no property record id set")
+		j.ProcessError(false, "strconv.Atoi(j.PropertyRecord.PropertyID)", j.Error)
+		return false
 
 	}
 	propID, j.Error = strconv.Atoi(j.PropertyRecord.PropertyID)
 	if j.Error != nil {
 		j.ProcessError(false, "strconv.Atoi(j.PropertyRecord.PropertyID)", j.Error)
-		return
+		return false
 	}
 
-	property, j.Error = j.Scraper.pdb.GetPropertyByID(context.Background(), int32(propID))
-	if j.Error != nil {
-		if j.Error.Error() != "sql: no rows in result set" {
-			j.ProcessError(true, "GetPropertyByID", j.Error)
-			return
+	if j.Scraper.store != nil && !j.Force {
+		property, j.Error = j.Scraper.store.Property(context.Background(), int32(propID))
+		found := j.Error == nil
+		if j.Error != nil {
+			if !errors.Is(j.Error, storage.ErrNotFound) {
+				j.ProcessError(true, "Property", j.Error)
+				return false
+			}
+			j.Error = nil
 		}
-	}
 
-	if property.ID == int32(propID) {
-		j.Error = errors.New("duplicate ID")
-		j.ProcessError(true, fmt.Sprintf("propertyID: %d == propID: %d ", property.ID, propID), j.Error)
-		return
+		if found && !property.LastScrapedAt.IsZero() && time.Since(property.LastScrapedAt) < minRescrapeAge {
+			j.Error = errors.New("duplicate ID")
+			j.ProcessError(true, fmt.Sprintf("propertyID: %s == propID: %d ", property.PropertyID, propID), j.Error)
+			return false
+		}
 	}
 
-	j.Proxy, j.Error = j.Scraper.proxyClient.GetNext()
+	acquireCtx, cancelAcquire := context.WithTimeout(context.Background(), proxyWait)
+	j.lease, j.Error = j.Scraper.proxyProvider.Acquire(acquireCtx)
+	cancelAcquire()
 	if j.Error != nil {
-		j.ProcessError(false, "proxyClient.GetNext()", j.Error)
-		return
+		j.ProcessError(false, "proxyProvider.Acquire", j.Error)
+		return false
 	}
-
-	fmt.Printf("worker: %d   jobID: %d propID: %s   Getting user agent\n", j.ProcessorID, j.JobID, j.PropertyRecord.PropertyID)
-	j.UserAgent, j.Error = j.Scraper.userAgentClient.GetRandomUserAgent()
+	j.Proxy = j.lease.Proxy
+	defer j.releaseProxy()
+	j.client, j.Error = j.Scraper.clientFor(j.Proxy)
 	if j.Error != nil {
-		j.ProcessError(false, "userAgentClient.GetRandomUserAgent()", j.Error)
-		return
+		j.ProcessError(false, "j.Scraper.clientFor(j.Proxy)", j.Error)
+		return false
+	}
+
+	fmt.Fprintf(j.Scraper.log, "worker: %d   jobID: %d propID: %s   Getting user agent\n", j.ProcessorID, j.JobID, j.PropertyRecord.PropertyID)
+	if j.Scraper.politeness != nil {
+		j.UserAgent = j.Scraper.politeness.UserAgent()
+	} else {
+		j.UserAgent = j.Scraper.userAgents.UserAgent()
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	var firstReq *http.Request
-	firstReq, j.Error = http.NewRequestWithContext(ctx, "GET", "https://propaccess.trueautomation.com/clientdb/?cid=56", nil)
+	firstReq, j.Error = http.NewRequestWithContext(ctx, "GET", j.Scraper.warmupURL(), nil)
 	if j.Error != nil {
 		j.ProcessError(false, "http.NewRequestWithContext", j.Error)
-		return
+		return false
+	}
+	firstReq.Header.Set("User-Agent", j.UserAgent)
+	if j.Error = j.Scraper.polite(firstReq); j.Error != nil {
+		j.ProcessError(false, "j.Scraper.polite(firstReq)", j.Error)
+		return false
 	}
 	var resp *http.Response
-	resp, j.Error = j.Scraper.httpClient.Do(firstReq)
+	start := time.Now()
+	resp, j.Error = j.client.Do(firstReq)
+	metrics.ObserveRequest("warmup", resp)
+	j.recordProxyHealth(resp, j.Error, time.Since(start))
 	if j.Error != nil {
-		fmt.Printf("worker: %d   jobID: %d  Bad proxy\n", j.ProcessorID, j.JobID)
-		j.Error = j.Scraper.proxyClient.MarkProxyAsBad(j.Proxy.IP)
 		dur := getRandomTimeoutDuration(10, 100)
 		time.Sleep(dur)
+		j.ProcessError(false, "j.client.Do(firstReq)", j.Error)
+		return false
 	}
-	if j.Error != nil {
-		j.ProcessError(false, "http.proxyClient.MarkProxyAsBad", j.Error)
-		return
-	}
+	resp.Body.Close()
 	if resp.StatusCode > 399 || resp.StatusCode < 200 {
 		j.Error = errors.New(resp.Status)
-		j.ProcessError(false, "http.proxyClient.MarkProxyAsBad", j.Error)
-		return
+		j.ProcessError(false, "j.client.Do(firstReq)", j.Error)
+		return false
 
 	}
 
@@ -518,30 +480,42 @@ func (j *Job) Process() {
 	req, j.Error = http.NewRequest("GET", j.URL, nil)
 	if j.Error != nil {
 		j.ProcessError(false, "http.NewRequest", j.Error)
-		return
+		return false
 	}
 
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Host", "propaccesj.Scraper.trueautomation.com")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.1 Safari/605.1.15")
+	req.Header.Set("User-Agent", j.UserAgent)
 	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("Referer", "https://propaccesj.Scraper.trueautomation.com/clientdb/SearchResultj.Scraper.aspx?cid=56")
-	fmt.Printf("worker: %d   jobID: %d  Property Request\n", j.ProcessorID, j.JobID)
+	req.Header.Set("Referer", fmt.Sprintf("%s/clientdb/SearchResults.aspx?cid=%s", j.Scraper.portalURL, j.Scraper.clientID))
+	fmt.Fprintf(j.Scraper.log, "worker: %d   jobID: %d  Property Request\n", j.ProcessorID, j.JobID)
 
+	if j.Error = j.Scraper.polite(req); j.Error != nil {
+		j.ProcessError(false, "j.Scraper.polite(req)", j.Error)
+		return false
+	}
 	var detailResp *http.Response
-	detailResp, j.Error = j.Scraper.httpClient.Do(req)
+	start = time.Now()
+	detailResp, j.Error = j.client.Do(req)
+	metrics.ObserveRequest("detail", detailResp)
+	j.recordProxyHealth(detailResp, j.Error, time.Since(start))
 
 	if j.Error != nil {
-		j.ProcessError(false, "j.Scraper.httpClient.Do", j.Error)
-		return
+		j.ProcessError(false, "j.client.Do", j.Error)
+		return false
+	}
+	if detailResp.StatusCode > 399 || detailResp.StatusCode < 200 {
+		detailResp.Body.Close()
+		j.Error = errors.New(detailResp.Status)
+		j.ProcessError(false, "j.client.Do", j.Error)
+		return false
 	}
 
 	var b []byte
 	b, j.Error = io.ReadAll(detailResp.Body)
 	if j.Error != nil {
 		j.ProcessError(false, "io.ReadAll(detailResp.Body)", j.Error)
-		return
+		return false
 	}
 	j.ResponseBodyBuffer = bytes.NewBuffer(b)
 
@@ -550,20 +524,34 @@ func (j *Job) Process() {
 	if j.ResponseBodyBuffer == nil {
 		j.Error = errors.New("nil response body")
 		j.ProcessError(false, "j.ResponseBodyBuffer == nil", j.Error)
-		return
+		return false
 	}
 
-	fmt.Printf("worker: %d   jobID: %d  parsing property details\n", j.ProcessorID, j.JobID)
+	return true
+}
+
+func (j *Job) parse() bool {
+	fmt.Fprintf(j.Scraper.log, "worker: %d   jobID: %d  parsing property details\n", j.ProcessorID, j.JobID)
 	j.PropertyRecord, j.Error = parseDetails(j.ResponseBodyBuffer)
 	if j.Error != nil {
+		metrics.ParseFailures.Inc()
 		j.ProcessError(false, "parseDetails(j.ResponseBodyBuffer)", j.Error)
-		return
+		return false
 	}
+	return true
+}
 
-	fmt.Printf("worker: %d   jobID: %d  adding records to database\n", j.ProcessorID, j.JobID)
+// persist writes the job's record to the sink on its own and drops its URL
+// from pending_urls.
+func (j *Job) persist() {
+	fmt.Fprintf(j.Scraper.log, "worker: %d   jobID: %d  writing record to sink\n", j.ProcessorID, j.JobID)
 
-	if j.Error = j.Scraper.AddPropertyRecordToDB(j.ProcessorID, j.JobID, j.URL, j.PropertyRecord); j.Error != nil {
-		j.ProcessError(false, "j.Scraper.AddPropertyRecordToDB()", j.Error)
+	if j.Error = j.Scraper.writeRecords([]tax.PropertyRecord{j.PropertyRecord}); j.Error != nil {
+		metrics.DBInsertFailures.Inc()
+		j.ProcessError(false, "j.Scraper.writeRecords()", j.Error)
+		return
+	}
+	if j.Scraper.db == nil {
 		return
 	}
 
@@ -572,8 +560,5 @@ func (j *Job) Process() {
 		return
 	}
 
-	if j.Error == nil {
-		j.Error = errors.New("No Errors")
-	}
-	fmt.Printf("worker: %d  jobID: %d  procID:  %d   finalErrors: %s\n", j.ProcessorID, j.JobID, j.ProcessorID, j.Error)
+	fmt.Fprintf(j.Scraper.log, "worker: %d  jobID: %d  procID:  %d   completed\n", j.ProcessorID, j.JobID, j.ProcessorID)
 }
@@ -0,0 +1,535 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/cassette"
+	"github.com/jason-costello/taxcollector/fakeportal"
+	"github.com/jason-costello/taxcollector/politeness"
+	"github.com/jason-costello/taxcollector/proxies"
+	"github.com/jason-costello/taxcollector/sink"
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+	"github.com/jason-costello/taxcollector/tax"
+	"github.com/jason-costello/taxcollector/useragents"
+)
+
+const (
+	testWarmupURL = "https://propaccess.trueautomation.com/clientdb/?cid=56"
+	testDetailURL = "https://propaccess.trueautomation.com/clientdb/Property.aspx?cid=56&prop_id=2163"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := migratetest.Open(t)
+	if _, err := db.Exec(`insert into proxies(ip, uses, is_bad) values($1, 0, 0)`, startTestProxy(t).addr); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testProxy is a plain HTTP forward proxy that counts the requests it relays.
+type testProxy struct {
+	addr string
+	hits int32
+	// auth, when set, is the Proxy-Authorization header every request needs.
+	auth string
+}
+
+func startTestProxy(t *testing.T) *testProxy {
+	t.Helper()
+	tp := &testProxy{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tp.hits, 1)
+		if tp.auth != "" && r.Header.Get("Proxy-Authorization") != tp.auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		r.Header.Del("Proxy-Authorization")
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(srv.Close)
+	tp.addr = srv.Listener.Addr().String()
+	return tp
+}
+
+func Test_JobProcessReplay(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`insert into pending_urls(url) values($1)`, testDetailURL); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := os.ReadFile("../test_data/2163.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &cassette.Cassette{Interactions: []cassette.Interaction{
+		{
+			Request: cassette.Request{Method: http.MethodGet, URL: testWarmupURL},
+			Response: cassette.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Set-Cookie": {"ASP.NET_SessionId=replay; path=/"}},
+				Body:       []byte("<html></html>"),
+			},
+		},
+		{
+			Request: cassette.Request{Method: http.MethodGet, URL: testDetailURL},
+			Response: cassette.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+				Body:       page,
+			},
+		},
+	}}
+
+	// A replaying transport can't be proxied, so the job goes direct.
+	hc := &http.Client{Transport: cassette.FromCassette(c)}
+	s := NewScraper(nil, nil, db, hc)
+
+	j := Job{
+		JobID:          1,
+		URL:            testDetailURL,
+		PropertyRecord: tax.PropertyRecord{PropertyID: "2163"},
+		Scraper:        s,
+	}
+	j.Process()
+	if j.Error != nil {
+		t.Fatalf("Process() error = %v", j.Error)
+	}
+
+	var count int
+	if err := db.QueryRow(`select count(*) from properties where id = 2163`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("properties rows = %d, want 1", count)
+	}
+	if err := db.QueryRow(`select count(*) from roll_values where property_id = 2163`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count == 0 {
+		t.Error("no roll values inserted")
+	}
+	if err := db.QueryRow(`select count(*) from pending_urls`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("pending_urls rows = %d, want 0", count)
+	}
+}
+
+func Test_JobProcessFakePortal(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  []fakeportal.Fault
+		wantErr bool
+	}{
+		{name: "ok"},
+		{name: "forbidden", faults: []fakeportal.Fault{fakeportal.FaultForbidden}, wantErr: true},
+		{name: "rate limited", faults: []fakeportal.Fault{fakeportal.FaultTooManyRequests}, wantErr: true},
+		{name: "timeout", faults: []fakeportal.Fault{fakeportal.FaultTimeout}, wantErr: true},
+		{name: "truncated", faults: []fakeportal.Fault{fakeportal.FaultTruncated}, wantErr: true},
+		{name: "session expired", faults: []fakeportal.Fault{fakeportal.FaultSessionExpired}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portal, srv := fakeportal.NewServer(fakeportal.Options{
+				FixturesDir: "../test_data",
+				Delay:       2 * time.Second,
+			})
+			defer srv.Close()
+			portal.InjectFault("2163", tt.faults...)
+
+			db := openTestDB(t)
+			hc := &http.Client{Timeout: 500 * time.Millisecond}
+			s := NewScraper(proxies.NewProxyClient(db), nil, db, hc)
+			s.SetPortal(srv.URL, DefaultClientID)
+			var progress bytes.Buffer
+			s.SetLog(&progress)
+
+			j := Job{
+				JobID:          1,
+				URL:            s.DetailURL("2163"),
+				PropertyRecord: tax.PropertyRecord{PropertyID: "2163"},
+				Scraper:        s,
+			}
+			j.Process()
+			if (j.Error != nil) != tt.wantErr {
+				t.Fatalf("Process() error = %v, wantErr %v", j.Error, tt.wantErr)
+			}
+			if portal.Hits("/clientdb/") == 0 {
+				t.Error("scraper skipped the session warm-up")
+			}
+			if !strings.Contains(progress.String(), "jobID: 1") {
+				t.Errorf("progress went elsewhere than SetLog: %q", progress.String())
+			}
+
+			var count int
+			if err := db.QueryRow(`select count(*) from properties where id = 2163`).Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if want := map[bool]int{true: 0, false: 1}[tt.wantErr]; count != want {
+				t.Errorf("properties rows = %d, want %d", count, want)
+			}
+		})
+	}
+}
+
+func Test_JobProcessProxyAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "ok", password: "secret"},
+		{name: "wrong password", password: "guess", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+			defer srv.Close()
+
+			tp := startTestProxy(t)
+			tp.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte("scraper:secret"))
+
+			db := openTestDB(t)
+			if _, err := db.Exec(`delete from proxies`); err != nil {
+				t.Fatal(err)
+			}
+			pr, err := proxies.ParseProxy("scraper:" + tt.password + "@" + tp.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pc := proxies.NewProxyClient(db)
+			if err := pc.Import(context.Background(), []proxies.Proxy{pr}); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewScraper(pc, nil, db, &http.Client{})
+			s.SetPortal(srv.URL, DefaultClientID)
+			j := Job{URL: s.DetailURL("2163"), PropertyRecord: tax.PropertyRecord{PropertyID: "2163"}, Scraper: s}
+			j.Process()
+			if (j.Error != nil) != tt.wantErr {
+				t.Fatalf("Process() error = %v, wantErr %v", j.Error, tt.wantErr)
+			}
+			if atomic.LoadInt32(&tp.hits) == 0 {
+				t.Error("request did not go through the proxy")
+			}
+
+			pr, err = pc.Get(tp.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pr.LeasedUntil.IsZero() {
+				t.Error("proxy lease not released")
+			}
+			if got := map[bool]int{true: pr.Failures, false: pr.Successes}[tt.wantErr]; got != 1 {
+				t.Errorf("proxy successes/failures = %d/%d, want the job's outcome recorded once", pr.Successes, pr.Failures)
+			}
+		})
+	}
+}
+
+func Test_JobProcessRescrape(t *testing.T) {
+	portal, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	db := openTestDB(t)
+	s := NewScraper(proxies.NewProxyClient(db), nil, db, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+
+	rollValues := func() int {
+		var n int
+		if err := db.QueryRow(`select count(*) from roll_values where property_id = 2163`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	process := func() error {
+		j := Job{URL: s.DetailURL("2163"), PropertyRecord: tax.PropertyRecord{PropertyID: "2163"}, Scraper: s}
+		j.Process()
+		return j.Error
+	}
+
+	if err := process(); err != nil {
+		t.Fatal(err)
+	}
+	first := rollValues()
+
+	if err := process(); err == nil {
+		t.Fatal("freshly scraped property was not treated as a duplicate")
+	}
+
+	stale := sql.NullTime{Time: time.Now().Add(-48 * time.Hour).UTC(), Valid: true}
+	if _, err := db.Exec(`update properties set last_scraped_at = $1 where id = 2163`, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := process(); err != nil {
+		t.Fatal(err)
+	}
+	if got := rollValues(); got != first {
+		t.Errorf("roll values after re-scrape = %d, want %d", got, first)
+	}
+	if got := portal.Hits("/clientdb/Property.aspx"); got != 2 {
+		t.Errorf("detail requests = %d, want 2", got)
+	}
+}
+
+func Test_ScrapePipeline(t *testing.T) {
+	_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	db := openTestDB(t)
+	s := NewScraper(proxies.NewProxyClient(db), nil, db, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+	s.SetWorkers(2)
+	s.SetRateLimit(0, 0)
+	s.SetPipeline(2, 10, 50*time.Millisecond)
+
+	// 9999 has no fixture, so the portal answers 404 and the job fails in the
+	// fetch stage without holding up the batch.
+	urls := []string{s.DetailURL("2163"), s.DetailURL("114173"), s.DetailURL("9999")}
+	for _, u := range urls {
+		if _, err := db.Exec(`insert into pending_urls(url) values($1)`, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Scrape(urls)
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if got := count(`select count(*) from properties where id in (2163, 114173)`); got != 2 {
+		t.Errorf("properties rows = %d, want 2", got)
+	}
+	if got := count(`select count(*) from roll_values where property_id = 2163`); got == 0 {
+		t.Error("no roll values written for 2163")
+	}
+	if got := count(`select count(*) from improvement_detail`); got == 0 {
+		t.Error("no improvement details written")
+	}
+
+	var pending string
+	if err := db.QueryRow(`select url from pending_urls`).Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != urls[2] {
+		t.Errorf("pending url = %q, want only the failed %q", pending, urls[2])
+	}
+}
+
+func Test_ScrapeWithoutDB(t *testing.T) {
+	_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "props.ndjson")
+	rs, err := sink.NewNDJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScraper(nil, nil, nil, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+	s.SetRateLimit(0, 0)
+	s.SetSink(rs)
+	s.Scrape([]string{s.DetailURL("2163"), s.DetailURL("114173")})
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bytes.Count(b, []byte("\n")); got != 2 {
+		t.Errorf("ndjson lines = %d, want 2", got)
+	}
+}
+
+func Test_JobProcessMemoryStore(t *testing.T) {
+	_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	store := storage.NewMemory()
+	s := NewScraper(nil, nil, nil, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+	s.SetStore(store)
+
+	if ok, err := s.PropertyExists(s.DetailURL("2163")); err != nil || ok {
+		t.Fatalf("PropertyExists before scraping = %v, %v", ok, err)
+	}
+	process := func() error {
+		j := Job{URL: s.DetailURL("2163"), PropertyRecord: tax.PropertyRecord{PropertyID: "2163"}, Scraper: s}
+		j.Process()
+		return j.Error
+	}
+	if err := process(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := store.Property(context.Background(), 2163)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.RollValue) == 0 {
+		t.Error("stored property has no roll values")
+	}
+	if ok, err := s.PropertyExists(s.DetailURL("2163")); err != nil || !ok {
+		t.Errorf("PropertyExists after scraping = %v, %v", ok, err)
+	}
+	if err := process(); err == nil {
+		t.Error("freshly scraped property was not treated as a duplicate")
+	}
+}
+
+func Test_JobProcessPolite(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		budget  int
+		wantErr error
+		hits    int
+	}{
+		{name: "allowed", robots: "User-agent: *\nCrawl-delay: 0\n", hits: 1},
+		{name: "disallowed", robots: "User-agent: *\nDisallow: /clientdb/Property.aspx\n", wantErr: politeness.ErrDisallowed},
+		{name: "over budget", budget: 1, wantErr: politeness.ErrBudgetExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portal, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data", Robots: tt.robots})
+			defer srv.Close()
+
+			db := openTestDB(t)
+			s := NewScraper(proxies.NewProxyClient(db), nil, db, &http.Client{})
+			s.SetPortal(srv.URL, DefaultClientID)
+			s.SetPoliteness(politeness.New("taxcollector-test/1.0 (+mailto:ops@example.com)", tt.budget))
+
+			j := Job{URL: s.DetailURL("2163"), PropertyRecord: tax.PropertyRecord{PropertyID: "2163"}, Scraper: s}
+			j.Process()
+			if tt.wantErr == nil && j.Error != nil {
+				t.Fatal(j.Error)
+			}
+			if tt.wantErr != nil && !errors.Is(j.Error, tt.wantErr) {
+				t.Fatalf("Process() error = %v, want %v", j.Error, tt.wantErr)
+			}
+			if got := portal.Hits("/clientdb/Property.aspx"); got != tt.hits {
+				t.Errorf("detail requests = %d, want %d", got, tt.hits)
+			}
+			if j.UserAgent != "taxcollector-test/1.0 (+mailto:ops@example.com)" {
+				t.Errorf("UserAgent = %q, want the contact agent", j.UserAgent)
+			}
+		})
+	}
+}
+
+func Test_JobProcessUserAgent(t *testing.T) {
+	const ua = "taxcollector-test/2.0"
+	portal := fakeportal.New(fakeportal.Options{FixturesDir: "../test_data"})
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.URL.Path+" "+r.UserAgent())
+		mu.Unlock()
+		portal.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	db := openTestDB(t)
+	s := NewScraper(proxies.NewProxyClient(db), useragents.Fixed(ua), db, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+
+	j := Job{URL: s.DetailURL("2163"), PropertyRecord: tax.PropertyRecord{PropertyID: "2163"}, Scraper: s}
+	j.Process()
+	if j.Error != nil {
+		t.Fatal(j.Error)
+	}
+	if j.UserAgent != ua {
+		t.Errorf("UserAgent = %q, want %q", j.UserAgent, ua)
+	}
+	want := []string{"/clientdb/ " + ua, "/clientdb/Property.aspx " + ua}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func Test_Get(t *testing.T) {
+	_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	db := openTestDB(t)
+	s := NewScraper(proxies.NewProxyClient(db), nil, db, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+
+	for i := 0; i < 2; i++ {
+		pr, page, err := s.Get("2163", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pr.PropertyID != "2163" || len(page) == 0 {
+			t.Fatalf("Get() = %q with %d byte page", pr.PropertyID, len(page))
+		}
+		// A fresh copy in the database must not stop an explicit lookup.
+		if err := s.Save(pr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := s.Get("9999", 0); err == nil {
+		t.Error("Get() of a missing property returned no error")
+	}
+	if got, want := s.DetailURLForYear("2163", 2021), s.DetailURL("2163")+"&year=2021"; got != want {
+		t.Errorf("DetailURLForYear() = %q, want %q", got, want)
+	}
+}
+
+func Test_StaticProxiesWithoutDB(t *testing.T) {
+	_, srv := fakeportal.NewServer(fakeportal.Options{FixturesDir: "../test_data"})
+	defer srv.Close()
+
+	tp := startTestProxy(t)
+	pr, err := proxies.ParseProxy(tp.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewScraper(proxies.NewStatic([]proxies.Proxy{pr}), nil, nil, &http.Client{})
+	s.SetPortal(srv.URL, DefaultClientID)
+
+	got, _, err := s.Get("2163", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PropertyID != "2163" {
+		t.Errorf("Get() = %q, want 2163", got.PropertyID)
+	}
+	if atomic.LoadInt32(&tp.hits) == 0 {
+		t.Error("request did not go through the static proxy")
+	}
+}
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// CSV writes one file per table into a directory, e.g. properties.csv and
+// roll_values.csv, each with a header row.
+type CSV struct {
+	mu      sync.Mutex
+	files   []*os.File
+	writers []*csv.Writer
+}
+
+func NewCSV(dir string) (*CSV, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &CSV{}
+	for _, t := range tables {
+		f, err := os.Create(filepath.Join(dir, t.name+".csv"))
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		w := csv.NewWriter(f)
+		c.files = append(c.files, f)
+		c.writers = append(c.writers, w)
+		if err := w.Write(columns(t.proto)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *CSV) WriteRecords(ctx context.Context, records []tax.PropertyRecord) error {
+	return c.WriteProperties(ctx, fromRecords(records))
+}
+
+func (c *CSV) WriteProperties(ctx context.Context, props []storage.Property) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, rows := range flatten(props) {
+		w := c.writers[t]
+		for _, row := range rows {
+			if err := w.Write(values(row)); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CSV) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var firstErr error
+	for i, f := range c.files {
+		c.writers[i].Flush()
+		if err := c.writers[i].Error(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
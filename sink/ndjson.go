@@ -0,0 +1,111 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// NDJSON writes one JSON encoded tax.PropertyRecord per line, children
+// nested, in the same shape the API serves. Properties from the store carry
+// their stored fields as well.
+type NDJSON struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewNDJSON(path string) (*NDJSON, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &NDJSON{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (n *NDJSON) WriteRecords(ctx context.Context, records []tax.PropertyRecord) error {
+	return n.WriteProperties(ctx, fromRecords(records))
+}
+
+// storedRecord adds the fields the store keeps to a record's JSON, leaving
+// out the ones that are unset.
+type storedRecord struct {
+	tax.PropertyRecord
+	Street        string     `json:"street,omitempty"`
+	Latitude      *float64   `json:"latitude,omitempty"`
+	Longitude     *float64   `json:"longitude,omitempty"`
+	LastScrapedAt *time.Time `json:"lastScrapedAt,omitempty"`
+	Occupancy     string     `json:"occupancy,omitempty"`
+}
+
+func (n *NDJSON) WriteProperties(ctx context.Context, props []storage.Property) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, p := range props {
+		r := storedRecord{PropertyRecord: p.PropertyRecord, Street: p.Street, Occupancy: string(p.Occupancy)}
+		if p.Latitude.Valid && p.Longitude.Valid {
+			r.Latitude, r.Longitude = &p.Latitude.Float64, &p.Longitude.Float64
+		}
+		if !p.LastScrapedAt.IsZero() {
+			r.LastScrapedAt = &p.LastScrapedAt
+		}
+		if err := n.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return n.w.Flush()
+}
+
+func (n *NDJSON) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := n.w.Flush(); err != nil {
+		n.f.Close()
+		return err
+	}
+	return n.f.Close()
+}
+
+// FlatNDJSON writes one JSON object per row of the tables CSV writes, with
+// the same columns and a "table" field naming the table.
+type FlatNDJSON struct {
+	*NDJSON
+}
+
+func NewFlatNDJSON(path string) (*FlatNDJSON, error) {
+	n, err := NewNDJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FlatNDJSON{NDJSON: n}, nil
+}
+
+func (n *FlatNDJSON) WriteRecords(ctx context.Context, records []tax.PropertyRecord) error {
+	return n.WriteProperties(ctx, fromRecords(records))
+}
+
+func (n *FlatNDJSON) WriteProperties(ctx context.Context, props []storage.Property) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for t, rows := range flatten(props) {
+		cols := columns(tables[t].proto)
+		for _, row := range rows {
+			obj := map[string]string{"table": tables[t].name}
+			for i, v := range values(row) {
+				obj[cols[i]] = v
+			}
+			if err := n.enc.Encode(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return n.w.Flush()
+}
@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Parquet writes one snappy compressed file per table into a directory, with
+// the same tables and columns as CSV. The files are only valid once Close has
+// written their footers.
+type Parquet struct {
+	mu      sync.Mutex
+	files   []*os.File
+	writers []*writer.ParquetWriter
+}
+
+func NewParquet(dir string) (*Parquet, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	p := &Parquet{}
+	for _, t := range tables {
+		f, err := os.Create(filepath.Join(dir, t.name+".parquet"))
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.files = append(p.files, f)
+		w, err := writer.NewParquetWriterFromWriter(f, t.proto, 1)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		w.CompressionType = parquet.CompressionCodec_SNAPPY
+		p.writers = append(p.writers, w)
+	}
+	return p, nil
+}
+
+func (p *Parquet) WriteRecords(ctx context.Context, records []tax.PropertyRecord) error {
+	return p.WriteProperties(ctx, fromRecords(records))
+}
+
+func (p *Parquet) WriteProperties(ctx context.Context, props []storage.Property) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for t, rows := range flatten(props) {
+		for _, row := range rows {
+			if err := p.writers[t].Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Parquet) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for i, f := range p.files {
+		if i < len(p.writers) {
+			if err := p.writers[i].WriteStop(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
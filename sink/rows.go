@@ -0,0 +1,207 @@
+package sink
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// The file sinks flatten a record into one row per table, mirroring the
+// database schema. Child rows carry the property id; improvement details are
+// tied to their improvement by its position within the property, since there
+// is no database id to join on. The columns only the store knows, from
+// street on, are empty for records fresh from the scraper.
+
+type propertyRow struct {
+	PropertyID          string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OwnerID             string `parquet:"name=owner_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OwnerName           string `parquet:"name=owner_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OwnerMailingAddress string `parquet:"name=owner_mailing_address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Zoning              string `parquet:"name=zoning, type=BYTE_ARRAY, convertedtype=UTF8"`
+	NeighborhoodCD      string `parquet:"name=neighborhood_cd, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Neighborhood        string `parquet:"name=neighborhood, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Address             string `parquet:"name=address, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LegalDescription    string `parquet:"name=legal_description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GeographicID        string `parquet:"name=geographic_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Exemptions          string `parquet:"name=exemptions, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OwnershipPercentage string `parquet:"name=ownership_percentage, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MapscoMapID         string `parquet:"name=mapsco_map_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Street              string `parquet:"name=street, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Latitude            string `parquet:"name=latitude, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Longitude           string `parquet:"name=longitude, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LastScrapedAt       string `parquet:"name=last_scraped_at, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Occupancy           string `parquet:"name=occupancy, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type rollValueRow struct {
+	PropertyID   string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Year         string `parquet:"name=year, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Improvements string `parquet:"name=improvements, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LandMarket   string `parquet:"name=land_market, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AgValuation  string `parquet:"name=ag_valuation, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Appraised    string `parquet:"name=appraised, type=BYTE_ARRAY, convertedtype=UTF8"`
+	HomesteadCap string `parquet:"name=homestead_cap, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Assessed     string `parquet:"name=assessed, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type jurisdictionRow struct {
+	PropertyID     string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Entity         string `parquet:"name=entity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description    string `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TaxRate        string `parquet:"name=tax_rate, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AppraisedValue string `parquet:"name=appraised_value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TaxableValue   string `parquet:"name=taxable_value, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EstimatedTax   string `parquet:"name=estimated_tax, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type landRow struct {
+	PropertyID  string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Number      string `parquet:"name=number, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LandType    string `parquet:"name=land_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description string `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Acres       string `parquet:"name=acres, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SquareFeet  string `parquet:"name=square_feet, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EffFront    string `parquet:"name=eff_front, type=BYTE_ARRAY, convertedtype=UTF8"`
+	EffDepth    string `parquet:"name=eff_depth, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MarketValue string `parquet:"name=market_value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type improvementRow struct {
+	PropertyID     string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ImprovementSeq int32  `parquet:"name=improvement_seq, type=INT32"`
+	Name           string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description    string `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StateCode      string `parquet:"name=state_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	LivingArea     string `parquet:"name=living_area, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Value          string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type improvementDetailRow struct {
+	PropertyID      string `parquet:"name=property_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ImprovementSeq  int32  `parquet:"name=improvement_seq, type=INT32"`
+	ImprovementType string `parquet:"name=improvement_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description     string `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Class           string `parquet:"name=class, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ExteriorWall    string `parquet:"name=exterior_wall, type=BYTE_ARRAY, convertedtype=UTF8"`
+	YearBuilt       string `parquet:"name=year_built, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SquareFeet      string `parquet:"name=square_feet, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+const (
+	tableProperties = iota
+	tableRollValues
+	tableJurisdictions
+	tableLand
+	tableImprovements
+	tableImprovementDetails
+)
+
+// tables are written in this order, one file each, named after the table.
+var tables = []struct {
+	name  string
+	proto interface{}
+}{
+	tableProperties:         {"properties", new(propertyRow)},
+	tableRollValues:         {"roll_values", new(rollValueRow)},
+	tableJurisdictions:      {"jurisdictions", new(jurisdictionRow)},
+	tableLand:               {"land", new(landRow)},
+	tableImprovements:       {"improvements", new(improvementRow)},
+	tableImprovementDetails: {"improvement_details", new(improvementDetailRow)},
+}
+
+// fromRecords wraps records the scraper parsed, which the store hasn't
+// added anything to yet.
+func fromRecords(records []tax.PropertyRecord) []storage.Property {
+	props := make([]storage.Property, len(records))
+	for i, pr := range records {
+		props[i].PropertyRecord = pr
+	}
+	return props
+}
+
+// flatten splits properties into rows, indexed like tables.
+func flatten(props []storage.Property) [][]interface{} {
+	rows := make([][]interface{}, len(tables))
+	add := func(table int, row interface{}) {
+		rows[table] = append(rows[table], row)
+	}
+
+	for _, p := range props {
+		pr := p.PropertyRecord
+		id := pr.PropertyID
+		var lastScraped string
+		if !p.LastScrapedAt.IsZero() {
+			lastScraped = p.LastScrapedAt.UTC().Format(time.RFC3339)
+		}
+		add(tableProperties, propertyRow{
+			PropertyID:          id,
+			OwnerID:             pr.OwnerID,
+			OwnerName:           pr.OwnerName,
+			OwnerMailingAddress: pr.OwnerMailingAddress,
+			Zoning:              pr.Zoning,
+			NeighborhoodCD:      pr.NeighborhoodCD,
+			Neighborhood:        pr.Neighborhood,
+			Address:             pr.Address,
+			LegalDescription:    pr.LegalDescription,
+			GeographicID:        pr.GeographicID,
+			Exemptions:          pr.Exemptions,
+			OwnershipPercentage: pr.OwnershipPercentage,
+			MapscoMapID:         pr.MapscoMapID,
+			Street:              p.Street,
+			Latitude:            formatNullFloat(p.Latitude.Float64, p.Latitude.Valid),
+			Longitude:           formatNullFloat(p.Longitude.Float64, p.Longitude.Valid),
+			LastScrapedAt:       lastScraped,
+			Occupancy:           string(p.Occupancy),
+		})
+		for _, r := range pr.RollValue {
+			add(tableRollValues, rollValueRow{id, r.Year, r.Improvements, r.LandMarket, r.AgValuation, r.Appraised, r.HomesteadCap, r.Assessed})
+		}
+		for _, j := range pr.Jurisdictions {
+			add(tableJurisdictions, jurisdictionRow{id, j.Entity, j.Description, j.TaxRate, j.AppraisedValue, j.TaxableValue, j.EstimatedTax})
+		}
+		for _, l := range pr.Land {
+			add(tableLand, landRow{id, l.Number, l.Type, l.Description, l.Acres, l.Sqft, l.EffFront, l.EffDepth, l.MarketValue})
+		}
+		for n, i := range pr.Improvements {
+			seq := int32(n + 1)
+			add(tableImprovements, improvementRow{id, seq, i.Name, i.Description, i.StateCode, i.LivingArea, i.Value})
+			for _, d := range i.Details {
+				add(tableImprovementDetails, improvementDetailRow{id, seq, d.Type, d.Description, d.Class, d.ExteriorWall, d.YearBuilt, d.SqFt})
+			}
+		}
+	}
+	return rows
+}
+
+func formatNullFloat(f float64, valid bool) string {
+	if !valid {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// columns returns the column names of a row type from its parquet tags.
+func columns(proto interface{}) []string {
+	t := reflect.TypeOf(proto).Elem()
+	cols := make([]string, t.NumField())
+	for i := range cols {
+		tag := t.Field(i).Tag.Get("parquet")
+		cols[i] = strings.TrimPrefix(strings.SplitN(tag, ",", 2)[0], "name=")
+	}
+	return cols
+}
+
+// values returns a row's fields as strings, in column order.
+func values(row interface{}) []string {
+	v := reflect.ValueOf(row)
+	vals := make([]string, v.NumField())
+	for i := range vals {
+		vals[i] = fmt.Sprint(v.Field(i).Interface())
+	}
+	return vals
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// RecordSink receives the property records the scraper parses. WriteRecords
+// may be handed the same property more than once across calls; each call
+// should leave the sink holding the latest copy it was given.
+type RecordSink interface {
+	WriteRecords(ctx context.Context, records []tax.PropertyRecord) error
+	Close() error
+}
+
+// PropertySink is a RecordSink that can also write what the store adds to a
+// record: its coordinates, street, scrape time and occupancy. The file sinks
+// are all PropertySinks, which is how exports carry those fields.
+type PropertySink interface {
+	RecordSink
+	WriteProperties(ctx context.Context, props []storage.Property) error
+}
+
+// Kinds lists the sinks Open knows about.
+var Kinds = []string{"sql", "ndjson", "ndjson-flat", "csv", "parquet"}
+
+// Open returns the sink named by kind. path is the output file for the
+// ndjson sinks and the output directory for csv and parquet; sql writes to db instead.
+func Open(kind, path string, db *sql.DB) (RecordSink, error) {
+	if kind != "sql" && path == "" {
+		return nil, fmt.Errorf("%s sink needs an output path", kind)
+	}
+	switch kind {
+	case "sql":
+		if db == nil {
+			return nil, errors.New("sql sink needs a database")
+		}
+		return NewSQL(db), nil
+	case "ndjson":
+		return NewNDJSON(path)
+	case "ndjson-flat":
+		return NewFlatNDJSON(path)
+	case "csv":
+		return NewCSV(path)
+	case "parquet":
+		return NewParquet(path)
+	}
+	return nil, fmt.Errorf("unknown sink %q, want one of %v", kind, Kinds)
+}
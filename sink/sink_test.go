@@ -0,0 +1,236 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+func testRecords() []tax.PropertyRecord {
+	return []tax.PropertyRecord{
+		{
+			PropertyID:   "2163",
+			OwnerName:    "SMITH, JOHN",
+			Address:      "101 MAIN ST, TEMPLE, TX 76501",
+			Neighborhood: "DOWNTOWN",
+			RollValue: []tax.RollValue{
+				{Year: "2022", Appraised: "150000"},
+				{Year: "2021", Appraised: "140000"},
+			},
+			Jurisdictions: []tax.TaxingJurisdiction{{Entity: "CAD", TaxRate: "1"}},
+			Land:          []tax.Land{{Number: "1", Type: "A1", Acres: "0.25"}},
+			Improvements: []tax.Improvement{
+				{Name: "Residential", Value: "120000", Details: []tax.ImprovDetail{
+					{Type: "MA", SqFt: "1500"},
+					{Type: "GAR", SqFt: "400"},
+				}},
+			},
+		},
+		{PropertyID: "114173", OwnerName: "DOE, JANE", Land: []tax.Land{{Number: "1"}}},
+	}
+}
+
+func Test_NDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "props.ndjson")
+	rs, err := Open("ndjson", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.WriteRecords(context.Background(), testRecords()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []tax.PropertyRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var pr tax.PropertyRecord
+		if err := json.Unmarshal(sc.Bytes(), &pr); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, pr)
+	}
+	if len(got) != 2 || got[0].PropertyID != "2163" || len(got[0].Improvements[0].Details) != 2 {
+		t.Fatalf("read back %+v", got)
+	}
+}
+
+func Test_FlatNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.ndjson")
+	rs, err := Open("ndjson-flat", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.WriteRecords(context.Background(), testRecords()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	perTable := map[string]int{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var row map[string]string
+		if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+			t.Fatal(err)
+		}
+		if row["property_id"] == "" {
+			t.Errorf("row without property_id: %v", row)
+		}
+		perTable[row["table"]]++
+	}
+	want := map[string]int{"properties": 2, "roll_values": 2, "land": 2, "improvements": 1, "improvement_details": 2}
+	for table, n := range want {
+		if perTable[table] != n {
+			t.Errorf("%s rows = %d, want %d", table, perTable[table], n)
+		}
+	}
+}
+
+func Test_CSV(t *testing.T) {
+	dir := t.TempDir()
+	rs, err := Open("csv", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.WriteRecords(context.Background(), testRecords()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file   string
+		header string
+		rows   int
+	}{
+		{"properties.csv", "property_id", 2},
+		{"roll_values.csv", "property_id", 2},
+		{"jurisdictions.csv", "property_id", 1},
+		{"land.csv", "property_id", 2},
+		{"improvements.csv", "property_id", 1},
+		{"improvement_details.csv", "property_id", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join(dir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			recs, err := csv.NewReader(f).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recs[0][0] != tt.header {
+				t.Errorf("header = %v", recs[0])
+			}
+			if got := len(recs) - 1; got != tt.rows {
+				t.Errorf("rows = %d, want %d", got, tt.rows)
+			}
+		})
+	}
+}
+
+func Test_Parquet(t *testing.T) {
+	dir := t.TempDir()
+	rs, err := Open("parquet", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.WriteRecords(context.Background(), testRecords()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range tables {
+		b, err := os.ReadFile(filepath.Join(dir, tbl.name+".parquet"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) < 8 || string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+			t.Errorf("%s is not a parquet file", tbl.name)
+		}
+	}
+}
+
+func Test_SQL(t *testing.T) {
+	db := migratetest.Open(t)
+
+	rs, err := Open("sql", "", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := testRecords()
+	// A repeated land number keeps the last segment rather than breaking the
+	// property's natural key.
+	records[1].Land = append(records[1].Land, tax.Land{Number: "1", Acres: "2"})
+	// Segments and roll values without a readable number or year are all
+	// kept rather than collapsing into one.
+	records[1].Land = append(records[1].Land, tax.Land{Acres: "3"}, tax.Land{Number: "n/a", Acres: "4"})
+	records[1].RollValue = append(records[1].RollValue, tax.RollValue{Appraised: "1"}, tax.RollValue{Appraised: "2"})
+	// Writing the same property twice, within and across calls, must leave a
+	// single copy of its children.
+	for i := 0; i < 2; i++ {
+		if err := rs.WriteRecords(context.Background(), append(records, records[0])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(query string) int {
+		var n int
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"select count(*) from properties", 2},
+		{"select count(*) from roll_values where property_id = 2163", 2},
+		{"select count(*) from land", 4},
+		{"select count(*) from land where number is null", 2},
+		{"select count(*) from roll_values where property_id = 114173 and year is null", 2},
+		{"select count(*) from improvements", 1},
+		{"select count(*) from improvement_detail", 2},
+		{"select count(*) from land where property_id = 114173 and acres = 2", 1},
+	}
+	for _, tt := range tests {
+		if got := count(tt.query); got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func Test_OpenUnknown(t *testing.T) {
+	if _, err := Open("xml", "out.xml", nil); err == nil {
+		t.Fatal("expected an error for an unknown sink")
+	}
+	if _, err := Open("sql", "", nil); err == nil {
+		t.Fatal("expected an error for the sql sink without a database")
+	}
+}
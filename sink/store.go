@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jason-costello/taxcollector/storage"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// Store writes records to a PropertyStore. It doesn't own the store; Close
+// leaves it open.
+type Store struct {
+	store storage.PropertyStore
+}
+
+func NewStore(ps storage.PropertyStore) *Store {
+	return &Store{store: ps}
+}
+
+// NewSQL writes to the properties table and its child tables in db.
+func NewSQL(db *sql.DB) *Store {
+	return NewStore(storage.NewSQL(db))
+}
+
+func (s *Store) WriteRecords(ctx context.Context, records []tax.PropertyRecord) error {
+	return s.store.Save(ctx, records)
+}
+
+func (s *Store) Close() error {
+	return nil
+}
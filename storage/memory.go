@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// Memory is a PropertyStore and OwnerStore in maps, for tests and
+// short-lived runs. It
+// keeps records as given rather than normalising them the way SQL does,
+// and hands out copies so callers can't change what it holds.
+type Memory struct {
+	mu     sync.Mutex
+	props  map[int32]Property
+	owners map[int32]Owner
+	now    func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{props: map[int32]Property{}, owners: map[int32]Owner{}, now: time.Now}
+}
+
+func (m *Memory) Save(ctx context.Context, records []tax.PropertyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, pr := range records {
+		id := stringToInt32(pr.PropertyID)
+		// Like the SQL upsert, saving keeps what geocoding filled in.
+		p := m.props[id]
+		p.PropertyRecord = copyRecord(pr)
+		p.LastScrapedAt = now
+		m.props[id] = p
+		if o, ok := ownerOf(pr); ok {
+			m.owners[o.ID] = o
+		}
+	}
+	countyZIPs := m.countyZIPs()
+	for _, pr := range records {
+		id := stringToInt32(pr.PropertyID)
+		p := m.props[id]
+		p.Occupancy = classify(pr, countyZIPs)
+		m.props[id] = p
+	}
+	return nil
+}
+
+func (m *Memory) Property(ctx context.Context, id int32) (Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.props[id]
+	if !ok {
+		return Property{}, ErrNotFound
+	}
+	p.PropertyRecord = copyRecord(p.PropertyRecord)
+	return p, nil
+}
+
+func (m *Memory) Properties(ctx context.Context, ids []int32) ([]Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var props []Property
+	for _, id := range sortedIDs(ids) {
+		if p, ok := m.props[id]; ok {
+			p.PropertyRecord = copyRecord(p.PropertyRecord)
+			props = append(props, p)
+		}
+	}
+	return props, nil
+}
+
+func (m *Memory) List(ctx context.Context, f PropertyFilter) ([]Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var props []Property
+	skip := f.Offset
+	for _, id := range m.sortedPropertyIDs() {
+		p := m.props[id]
+		if f.Neighborhood != "" && !strings.EqualFold(p.Neighborhood, f.Neighborhood) {
+			continue
+		}
+		if f.Address != "" && !strings.Contains(strings.ToUpper(p.Address), strings.ToUpper(f.Address)) {
+			continue
+		}
+		if f.Street != "" && !strings.EqualFold(p.Street, f.Street) {
+			continue
+		}
+		if b := f.Within; b != nil && !(p.Latitude.Valid && p.Longitude.Valid &&
+			p.Latitude.Float64 >= b.MinLatitude && p.Latitude.Float64 <= b.MaxLatitude &&
+			p.Longitude.Float64 >= b.MinLongitude && p.Longitude.Float64 <= b.MaxLongitude) {
+			continue
+		}
+		if f.Occupancy != "" && p.Occupancy != f.Occupancy {
+			continue
+		}
+		if p.LastScrapedAt.Before(f.ScrapedSince) || id <= f.AfterID {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if len(props) == f.limit() {
+			break
+		}
+		if f.Children {
+			p.PropertyRecord = copyRecord(p.PropertyRecord)
+		} else {
+			p.RollValue, p.Land, p.Improvements, p.Jurisdictions = nil, nil, nil, nil
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, id int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.props[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.props, id)
+	return nil
+}
+
+func (m *Memory) History(ctx context.Context, id int32) ([]tax.RollValue, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.props[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	history := append([]tax.RollValue(nil), p.RollValue...)
+	sort.SliceStable(history, func(i, j int) bool {
+		yi, _ := strconv.Atoi(history[i].Year)
+		yj, _ := strconv.Atoi(history[j].Year)
+		return yi < yj
+	})
+	return history, nil
+}
+
+func copyRecord(pr tax.PropertyRecord) tax.PropertyRecord {
+	pr.RollValue = append([]tax.RollValue(nil), pr.RollValue...)
+	pr.Land = append([]tax.Land(nil), pr.Land...)
+	pr.Jurisdictions = append([]tax.TaxingJurisdiction(nil), pr.Jurisdictions...)
+	var improvements []tax.Improvement
+	for _, i := range pr.Improvements {
+		i.Details = append([]tax.ImprovDetail(nil), i.Details...)
+		improvements = append(improvements, i)
+	}
+	pr.Improvements = improvements
+	return pr
+}
+
+// sortedPropertyIDs returns the ids of every property held, in order. The
+// caller holds m.mu.
+func (m *Memory) sortedPropertyIDs() []int32 {
+	ids := make([]int32, 0, len(m.props))
+	for id := range m.props {
+		ids = append(ids, id)
+	}
+	return sortedIDs(ids)
+}
+
+// sortedIDs returns ids in order without repeats.
+func sortedIDs(ids []int32) []int32 {
+	seen := make(map[int32]bool, len(ids))
+	sorted := make([]int32, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			sorted = append(sorted, id)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
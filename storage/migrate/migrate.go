@@ -0,0 +1,338 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrations holds migrations/<driver>/NNNN_name.up.sql and
+// NNNN_name.down.sql. The versions applied to a database are recorded in its
+// schema_migrations table.
+//
+//go:embed migrations
+var migrations embed.FS
+
+var (
+	// ErrOutdated means the database is missing migrations this build has.
+	ErrOutdated = errors.New("database schema is out of date")
+	// ErrUnknownVersion means the database has migrations this build
+	// doesn't know about, i.e. it was migrated by a newer build.
+	ErrUnknownVersion = errors.New("database schema is newer than this build")
+	// ErrUnmanaged means the database has tables but no record of
+	// migrations, and not every table of the old schema.sql, so it can't be
+	// taken to be at version 1 either.
+	ErrUnmanaged = errors.New("database has tables not created by migrations")
+)
+
+var fileRE = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// tableExists asks each driver whether the table named $1 is there.
+var tableExists = map[string]string{
+	"postgres": `select count(*) from information_schema.tables where table_schema = current_schema() and table_name = $1`,
+	"sqlite3":  `select count(*) from sqlite_master where type = 'table' and name = $1`,
+}
+
+// oldSchemaTables are the tables of the old schema.sql, which migration 1
+// creates.
+var oldSchemaTables = []string{
+	"properties", "roll_values", "jurisdictions", "improvements",
+	"improvement_detail", "land", "pending_urls", "proxies",
+}
+
+const createTable = `create table if not exists schema_migrations
+(
+    version    integer not null primary key,
+    name       text    not null,
+    applied_at timestamp not null
+)`
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load returns the migrations for driver, oldest first.
+func Load(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileRE.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("%s: not a migration file name", path.Join(dir, e.Name()))
+		}
+		version, _ := strconv.Atoi(m[1])
+		b, err := migrations.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("%s: version %d is also %s", path.Join(dir, e.Name()), version, mig.Name)
+		}
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrator applies and rolls back migrations on one database.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+	now        func() time.Time
+}
+
+// New returns a Migrator for db, opened with driver.
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	list, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: list, now: time.Now}, nil
+}
+
+// Latest is the version the newest migration brings a database to.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status is a migration and whether the database has it.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type applied struct {
+	name string
+	at   time.Time
+}
+
+func (m *Migrator) hasTable(ctx context.Context, name string) (bool, error) {
+	var n int
+	err := m.db.QueryRowContext(ctx, tableExists[m.driver], name).Scan(&n)
+	return n > 0, err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]applied, error) {
+	done := map[int]applied{}
+	if ok, err := m.hasTable(ctx, "schema_migrations"); err != nil || !ok {
+		return done, err
+	}
+	rows, err := m.db.QueryContext(ctx, `select version, name, applied_at from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		var a applied
+		if err := rows.Scan(&version, &a.name, &a.at); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+	return done, rows.Err()
+}
+
+// Status lists every migration this build knows, plus any the database has
+// that it doesn't, oldest first.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []Status
+	for _, mig := range m.migrations {
+		a, ok := done[mig.Version]
+		list = append(list, Status{Migration: mig, Applied: ok, AppliedAt: a.at})
+		delete(done, mig.Version)
+	}
+	for version, a := range done {
+		list = append(list, Status{Migration: Migration{Version: version, Name: a.name}, Applied: true, AppliedAt: a.at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Up applies every pending migration, each in its own transaction, and
+// returns the ones it applied. A database set up from the old schema.sql,
+// without schema_migrations, is recorded as being at version 1 first; any
+// other database without it has to be empty, or Up returns ErrUnmanaged.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	managed, err := m.hasTable(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !managed {
+		if err := m.baseline(ctx); err != nil {
+			return nil, err
+		}
+	}
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ran []Migration
+	for _, mig := range m.migrations {
+		if _, ok := done[mig.Version]; ok {
+			continue
+		}
+		err := m.inTx(ctx, mig.Up, `insert into schema_migrations(version, name, applied_at) values($1, $2, $3)`,
+			mig.Version, mig.Name, m.now().UTC())
+		if err != nil {
+			return ran, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		ran = append(ran, mig)
+	}
+	return ran, nil
+}
+
+// baseline creates schema_migrations, and records version 1 without running
+// it if the database already has the tables of the old schema.sql.
+func (m *Migrator) baseline(ctx context.Context) error {
+	var missing []string
+	for _, name := range oldSchemaTables {
+		ok, err := m.hasTable(ctx, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+	switch {
+	case len(missing) == len(oldSchemaTables):
+		_, err := m.db.ExecContext(ctx, createTable)
+		return err
+	case len(missing) > 0:
+		return fmt.Errorf("%w: missing %s", ErrUnmanaged, strings.Join(missing, ", "))
+	}
+	first := m.migrations[0]
+	return m.inTx(ctx, createTable, `insert into schema_migrations(version, name, applied_at) values($1, $2, $3)`,
+		first.Version, first.Name, m.now().UTC())
+}
+
+// Down rolls back the newest steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := map[int]Migration{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+	var versions []int
+	for version := range done {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	var ran []Migration
+	for _, version := range versions {
+		if len(ran) == steps {
+			break
+		}
+		mig, ok := known[version]
+		if !ok {
+			return ran, fmt.Errorf("%w: can't roll back version %d", ErrUnknownVersion, version)
+		}
+		if mig.Down == "" {
+			return ran, fmt.Errorf("migration %d_%s can't be rolled back", mig.Version, mig.Name)
+		}
+		if err := m.inTx(ctx, mig.Down, `delete from schema_migrations where version = $1`, mig.Version); err != nil {
+			return ran, fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		ran = append(ran, mig)
+	}
+	return ran, nil
+}
+
+// inTx runs a migration script and the schema_migrations bookkeeping for it
+// atomically.
+func (m *Migrator) inTx(ctx context.Context, script, record string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Check returns ErrOutdated or ErrUnknownVersion unless the database has
+// exactly the migrations this build knows.
+func (m *Migrator) Check(ctx context.Context) error {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	current, pending := 0, 0
+	for _, st := range list {
+		switch {
+		case st.Applied && st.Up == "":
+			return fmt.Errorf("%w: database has version %d, this build knows up to %d", ErrUnknownVersion, st.Version, m.Latest())
+		case st.Applied:
+			current = st.Version
+		default:
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d migrations pending (database at version %d, want %d), run migrate up",
+			ErrOutdated, pending, current, m.Latest())
+	}
+	return nil
+}
+
+// Check opens a Migrator for db and checks its schema, for commands that
+// need an up-to-date database to start.
+func Check(ctx context.Context, db *sql.DB, driver string) error {
+	m, err := New(db, driver)
+	if err != nil {
+		return err
+	}
+	return m.Check(ctx)
+}
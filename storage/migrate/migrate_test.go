@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tax.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func Test_Load(t *testing.T) {
+	for _, driver := range []string{"postgres", "sqlite3"} {
+		list, err := Load(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		if len(list) == 0 || list[0].Version != 1 {
+			t.Fatalf("%s migrations = %v, want to start at version 1", driver, list)
+		}
+		for i, mig := range list {
+			if mig.Down == "" {
+				t.Errorf("%s migration %d_%s has no down script", driver, mig.Version, mig.Name)
+			}
+			if i > 0 && mig.Version != list[i-1].Version+1 {
+				t.Errorf("%s migration %d follows %d", driver, mig.Version, list[i-1].Version)
+			}
+		}
+	}
+	pg, _ := Load("postgres")
+	lite, _ := Load("sqlite3")
+	if len(pg) != len(lite) {
+		t.Errorf("postgres has %d migrations, sqlite3 %d", len(pg), len(lite))
+	}
+	if _, err := Load("mysql"); err == nil {
+		t.Error("Load(mysql) succeeded")
+	}
+}
+
+func Test_UpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Check(ctx); !errors.Is(err, ErrOutdated) {
+		t.Fatalf("Check() on an empty database = %v, want ErrOutdated", err)
+	}
+	ran, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(m.migrations) {
+		t.Fatalf("Up() ran %d migrations, want %d", len(ran), len(m.migrations))
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() after Up() = %v", err)
+	}
+	if ran, err := m.Up(ctx); err != nil || len(ran) != 0 {
+		t.Fatalf("second Up() ran %d migrations, err %v", len(ran), err)
+	}
+	if _, err := db.Exec(`insert into properties(id) values(1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range list {
+		if !st.Applied || st.AppliedAt.IsZero() {
+			t.Errorf("migration %d status = %+v, want applied", st.Version, st)
+		}
+	}
+
+	ran, err = m.Down(ctx, len(m.migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(m.migrations) || ran[0].Version != m.Latest() {
+		t.Fatalf("Down() rolled back %v, want everything newest first", ran)
+	}
+	var n int
+	if err := db.QueryRow(`select count(*) from sqlite_master where type = 'table' and name = 'properties'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("properties table survived rolling everything back")
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrOutdated) {
+		t.Errorf("Check() after Down() = %v, want ErrOutdated", err)
+	}
+}
+
+func Test_CheckUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into schema_migrations(version, name, applied_at) values($1, 'from_the_future', $2)`,
+		m.Latest()+1, m.now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Check() = %v, want ErrUnknownVersion", err)
+	}
+	if _, err := m.Down(ctx, 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Down() = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func Test_UpOldSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// What the old schema.sql left behind: its tables, without
+	// schema_migrations.
+	for _, stmt := range []string{
+		m.migrations[0].Up,
+		`insert into properties(id, owner_name) values(1, 'SMITH JOHN')`,
+		`insert into proxies(ip, lastused, uses) values('10.0.0.1:8080', '2022-03-04 05:06:07.891 -0600 CST m=+1.5', 3)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ran, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != len(m.migrations)-1 || ran[0].Version != 2 {
+		t.Fatalf("Up() ran %v, want everything after version 1", ran)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("Check() after Up() = %v", err)
+	}
+	var name string
+	if err := db.QueryRow(`select owner_name from properties where id = 1`).Scan(&name); err != nil || name != "SMITH JOHN" {
+		t.Errorf("property after Up() = %q, %v", name, err)
+	}
+	var lastUsed sql.NullTime
+	if err := db.QueryRow(`select last_used_at from proxies`).Scan(&lastUsed); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC); !lastUsed.Time.Equal(want) {
+		t.Errorf("last_used_at = %v, want %v", lastUsed, want)
+	}
+}
+
+func Test_UpUnmanaged(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	// Some of the old tables, but not all of them.
+	if _, err := db.Exec(`create table properties (id integer not null primary key)`); err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, ErrUnmanaged) {
+		t.Fatalf("Up() = %v, want ErrUnmanaged", err)
+	}
+	if ok, err := m.hasTable(ctx, "schema_migrations"); err != nil || ok {
+		t.Errorf("refused Up() left schema_migrations = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,31 @@
+package migratetest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open returns a SQLite database in a temporary directory with every
+// migration applied and foreign keys enforced, as config opens them. It is
+// closed when the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "tax.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := migrate.New(db, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
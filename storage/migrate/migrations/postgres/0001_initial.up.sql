@@ -0,0 +1,123 @@
+-- The tables of the old schema.sql, minus its role and sequence names, and
+-- with the year_built index it meant to create. migrate up records a
+-- database set up from schema.sql as being at this version instead of
+-- running it, and later migrations bring it up to date from there.
+
+create table properties
+(
+    id                    integer not null
+        constraint properties_pk
+            primary key,
+    owner_id              integer,
+    owner_name            varchar(255),
+    owner_mailing_address varchar(255),
+    zoning                varchar(255),
+    neighborhood_cd       varchar(255),
+    neighborhood          varchar(500),
+    address               varchar(500),
+    legal_description     varchar(500),
+    geographic_id         varchar(255),
+    exemptions            varchar(255),
+    ownership_percentage  double precision,
+    mapsco_map_id         varchar(255),
+    longitude             double precision,
+    latitude              double precision,
+    address_number        varchar(255) default 0 not null,
+    address_line_two      varchar(255),
+    city                  varchar(255),
+    street                varchar(255),
+    county                varchar(255),
+    state                 varchar(2)
+);
+
+create table roll_values
+(
+    id            serial
+        constraint main_rollvalues_pk
+            primary key,
+    year          integer,
+    improvements  integer,
+    land_market   integer,
+    ag_valuation  integer,
+    appraised     integer,
+    homestead_cap integer,
+    assessed      integer,
+    property_id   integer
+);
+
+create table jurisdictions
+(
+    id              serial
+        constraint jurisdictions_pk
+            primary key,
+    entity          varchar(255),
+    description     text,
+    tax_rate        integer,
+    appraised_value integer,
+    taxable_value   integer,
+    estimated_tax   integer,
+    property_id     integer
+);
+
+create table improvements
+(
+    id          serial
+        constraint improvements_pk
+            primary key,
+    name        text,
+    description text,
+    state_code  varchar(255),
+    living_area integer,
+    value       integer,
+    property_id integer
+);
+
+create table improvement_detail
+(
+    id               serial
+        constraint improvementdetail_pk
+            primary key,
+    improvement_id   integer,
+    improvement_type varchar(255),
+    description      text,
+    class            varchar(255),
+    exterior_wall    varchar(255),
+    year_built       integer,
+    square_feet      integer
+);
+
+create index improvement_detail_year_built_index
+    on improvement_detail (year_built);
+
+create table land
+(
+    id           serial
+        constraint land_pk
+            primary key,
+    number       integer,
+    land_type    varchar(255),
+    description  text,
+    acres        double precision,
+    square_feet  double precision,
+    eff_front    double precision,
+    eff_depth    double precision,
+    market_value integer,
+    property_id  integer
+);
+
+create table pending_urls
+(
+    url text not null
+        constraint pending_urls_pk
+            primary key
+);
+
+create table proxies
+(
+    ip       text not null
+        constraint proxies_pk
+            primary key,
+    lastused text,
+    uses     integer,
+    is_bad   integer
+);
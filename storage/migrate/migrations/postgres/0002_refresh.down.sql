@@ -0,0 +1,4 @@
+drop index properties_last_scraped_at_index;
+alter table properties drop column refresh_priority;
+alter table properties drop column watched;
+alter table properties drop column last_scraped_at;
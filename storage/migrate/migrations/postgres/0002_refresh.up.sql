@@ -0,0 +1,8 @@
+-- When each property was last scraped, and how eagerly to scrape it again.
+
+alter table properties add column last_scraped_at timestamp;
+alter table properties add column watched boolean default false not null;
+alter table properties add column refresh_priority integer default 0 not null;
+
+create index properties_last_scraped_at_index
+    on properties (last_scraped_at);
@@ -0,0 +1 @@
+alter table proxies drop column url;
@@ -0,0 +1,4 @@
+-- The full proxy URL, with scheme and credentials. Rows without one are
+-- plain http proxies at ip.
+
+alter table proxies add column url text;
@@ -0,0 +1,6 @@
+alter table proxies add column lastused text;
+update proxies set lastused = cast(last_used_at as text) where last_used_at is not null;
+
+alter table proxies drop column lease_token;
+alter table proxies drop column leased_until;
+alter table proxies drop column last_used_at;
@@ -0,0 +1,13 @@
+-- Proxies are leased to one scraper at a time. last_used_at replaces
+-- lastused, which held time.Time.String() text; its local wall-clock part
+-- carries over.
+
+alter table proxies add column last_used_at timestamp;
+alter table proxies add column leased_until timestamp;
+alter table proxies add column lease_token text;
+
+update proxies
+set last_used_at = cast(left(lastused, 19) as timestamp)
+where lastused ~ '^\d{4}-\d\d-\d\d \d\d:\d\d:\d\d';
+
+alter table proxies drop column lastused;
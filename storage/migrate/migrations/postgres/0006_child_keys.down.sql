@@ -0,0 +1,14 @@
+drop index improvement_detail_improvement_id_index;
+alter table improvement_detail drop constraint improvement_detail_improvement_id_fk;
+
+drop index improvements_property_id_index;
+alter table improvements drop constraint improvements_property_id_fk;
+
+drop index jurisdictions_property_id_index;
+alter table jurisdictions drop constraint jurisdictions_property_id_fk;
+
+alter table land drop constraint land_property_id_number_key;
+alter table land drop constraint land_property_id_fk;
+
+alter table roll_values drop constraint roll_values_property_id_year_key;
+alter table roll_values drop constraint roll_values_property_id_fk;
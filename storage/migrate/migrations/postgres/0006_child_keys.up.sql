@@ -0,0 +1,33 @@
+-- Child rows now belong to their property, or improvement, and go with it.
+-- Databases with orphans or duplicate roll values or land from older runs
+-- fail here; run migrate cleanup first.
+
+alter table roll_values
+    add constraint roll_values_property_id_fk
+        foreign key (property_id) references properties (id) on delete cascade;
+alter table roll_values
+    add constraint roll_values_property_id_year_key unique (property_id, year);
+
+alter table land
+    add constraint land_property_id_fk
+        foreign key (property_id) references properties (id) on delete cascade;
+alter table land
+    add constraint land_property_id_number_key unique (property_id, number);
+
+alter table jurisdictions
+    add constraint jurisdictions_property_id_fk
+        foreign key (property_id) references properties (id) on delete cascade;
+create index jurisdictions_property_id_index
+    on jurisdictions (property_id);
+
+alter table improvements
+    add constraint improvements_property_id_fk
+        foreign key (property_id) references properties (id) on delete cascade;
+create index improvements_property_id_index
+    on improvements (property_id);
+
+alter table improvement_detail
+    add constraint improvement_detail_improvement_id_fk
+        foreign key (improvement_id) references improvements (id) on delete cascade;
+create index improvement_detail_improvement_id_index
+    on improvement_detail (improvement_id);
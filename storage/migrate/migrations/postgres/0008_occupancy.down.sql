@@ -0,0 +1,4 @@
+drop index properties_situs_zip_index;
+drop index properties_occupancy_index;
+alter table properties drop column situs_zip;
+alter table properties drop column occupancy;
@@ -0,0 +1 @@
+drop table request_budget;
@@ -0,0 +1,8 @@
+-- How many portal requests politeness mode has made each day, so every
+-- process crawling against this database draws on the one daily budget.
+
+create table request_budget
+(
+    day  text primary key,
+    used integer not null default 0
+);
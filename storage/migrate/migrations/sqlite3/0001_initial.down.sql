@@ -0,0 +1,8 @@
+drop table if exists proxies;
+drop table if exists pending_urls;
+drop table if exists land;
+drop table if exists improvement_detail;
+drop table if exists improvements;
+drop table if exists jurisdictions;
+drop table if exists roll_values;
+drop table if exists properties;
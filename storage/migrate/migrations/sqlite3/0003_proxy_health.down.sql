@@ -0,0 +1,5 @@
+alter table proxies drop column cooldown_until;
+alter table proxies drop column latency_ms;
+alter table proxies drop column consecutive_failures;
+alter table proxies drop column failures;
+alter table proxies drop column successes;
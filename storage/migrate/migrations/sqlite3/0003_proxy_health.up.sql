@@ -0,0 +1,7 @@
+-- How well each proxy has done, and when a failing one may be tried again.
+
+alter table proxies add column successes integer default 0 not null;
+alter table proxies add column failures integer default 0 not null;
+alter table proxies add column consecutive_failures integer default 0 not null;
+alter table proxies add column latency_ms double precision default 0 not null;
+alter table proxies add column cooldown_until timestamp;
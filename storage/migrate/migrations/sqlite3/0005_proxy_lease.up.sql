@@ -0,0 +1,11 @@
+-- Proxies are leased to one scraper at a time, see the postgres flavour.
+
+alter table proxies add column last_used_at timestamp;
+alter table proxies add column leased_until timestamp;
+alter table proxies add column lease_token text;
+
+update proxies
+set last_used_at = substr(lastused, 1, 19)
+where lastused glob '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]*';
+
+alter table proxies drop column lastused;
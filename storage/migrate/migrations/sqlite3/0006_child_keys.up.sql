@@ -0,0 +1,97 @@
+-- Child rows now belong to their property, or improvement, and go with it.
+-- SQLite can only add foreign keys by rebuilding a table. Parents go first
+-- so dropping one never cascades into a rebuilt child. Databases with orphans
+-- or duplicate roll values or land from older runs fail here; run
+-- migrate cleanup first.
+
+create table improvements_new
+(
+    id          integer primary key autoincrement,
+    name        text,
+    description text,
+    state_code  varchar(255),
+    living_area integer,
+    value       integer,
+    property_id integer,
+    foreign key (property_id) references properties (id) on delete cascade
+);
+insert into improvements_new select * from improvements;
+drop table improvements;
+alter table improvements_new rename to improvements;
+create index improvements_property_id_index
+    on improvements (property_id);
+
+create table improvement_detail_new
+(
+    id               integer primary key autoincrement,
+    improvement_id   integer,
+    improvement_type varchar(255),
+    description      text,
+    class            varchar(255),
+    exterior_wall    varchar(255),
+    year_built       integer,
+    square_feet      integer,
+    foreign key (improvement_id) references improvements (id) on delete cascade
+);
+insert into improvement_detail_new select * from improvement_detail;
+drop table improvement_detail;
+alter table improvement_detail_new rename to improvement_detail;
+create index improvement_detail_improvement_id_index
+    on improvement_detail (improvement_id);
+create index improvement_detail_year_built_index
+    on improvement_detail (year_built);
+
+create table roll_values_new
+(
+    id            integer primary key autoincrement,
+    year          integer,
+    improvements  integer,
+    land_market   integer,
+    ag_valuation  integer,
+    appraised     integer,
+    homestead_cap integer,
+    assessed      integer,
+    property_id   integer,
+    foreign key (property_id) references properties (id) on delete cascade,
+    unique (property_id, year)
+);
+insert into roll_values_new select * from roll_values;
+drop table roll_values;
+alter table roll_values_new rename to roll_values;
+
+create table land_new
+(
+    id           integer primary key autoincrement,
+    number       integer,
+    land_type    varchar(255),
+    description  text,
+    acres        double precision,
+    square_feet  double precision,
+    eff_front    double precision,
+    eff_depth    double precision,
+    market_value integer,
+    property_id  integer,
+    foreign key (property_id) references properties (id) on delete cascade,
+    unique (property_id, number)
+);
+insert into land_new select * from land;
+drop table land;
+alter table land_new rename to land;
+
+create table jurisdictions_new
+(
+    id              integer primary key autoincrement,
+    entity          varchar(255),
+    description     text,
+    tax_rate        integer,
+    appraised_value integer,
+    taxable_value   integer,
+    estimated_tax   integer,
+    property_id     integer,
+    foreign key (property_id) references properties (id) on delete cascade
+);
+insert into jurisdictions_new select * from jurisdictions;
+drop table jurisdictions;
+alter table jurisdictions_new rename to jurisdictions;
+create index jurisdictions_property_id_index
+    on jurisdictions (property_id);
@@ -0,0 +1,3 @@
+drop index properties_neighborhood_index;
+drop index properties_owner_id_index;
+drop table owners;
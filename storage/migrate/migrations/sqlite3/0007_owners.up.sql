@@ -0,0 +1,27 @@
+-- Owners get a row of their own, keyed by the county's owner id, so their
+-- holdings can be looked up. Properties keep owner_id without a foreign key:
+-- the scraper stores 0 for an owner id it can't read. Existing owners take
+-- their name and address from their most recently scraped property.
+
+create table owners
+(
+    id              integer primary key,
+    name            text,
+    mailing_address text
+);
+
+insert into owners (id, name, mailing_address)
+select p.owner_id, p.owner_name, p.owner_mailing_address
+from properties p
+where p.owner_id is not null
+  and p.owner_id <> 0
+  and p.id = (select q.id
+              from properties q
+              where q.owner_id = p.owner_id
+              order by q.last_scraped_at desc, q.id desc
+              limit 1);
+
+create index properties_owner_id_index
+    on properties (owner_id);
+create index properties_neighborhood_index
+    on properties (neighborhood);
@@ -0,0 +1,11 @@
+-- Where the owner of a property is mailed relative to the property, and the
+-- property's own ZIP code, which is how the county's ZIP codes are known.
+-- Existing properties stay unclassified until the occupancy command runs.
+
+alter table properties add column occupancy text;
+alter table properties add column situs_zip text;
+
+create index properties_occupancy_index
+    on properties (occupancy);
+create index properties_situs_zip_index
+    on properties (situs_zip);
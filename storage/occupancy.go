@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// The county's ZIP codes are taken to be those of the properties in it, so
+// saving classifies against the properties saved so far: until a scrape has
+// seen the whole county, an owner mailed at a ZIP code it hasn't reached yet
+// is counted out of the county. Reclassify goes over them all again, and
+// has to run after every scrape; the scrape command does so when it writes
+// to the database.
+
+// reclassifyPage is how many properties Reclassify reads per query.
+const reclassifyPage = 1000
+
+func classify(pr tax.PropertyRecord, countyZIPs map[string]bool) occupancy.Class {
+	return occupancy.Classify(pr.Address, pr.OwnerMailingAddress, countyZIPs)
+}
+
+// addZIPs adds the situs ZIP code of each record to countyZIPs.
+func addZIPs(countyZIPs map[string]bool, records []tax.PropertyRecord) {
+	for _, pr := range records {
+		if zip := occupancy.Parse(pr.Address).ZIP; zip != "" {
+			countyZIPs[zip] = true
+		}
+	}
+}
+
+// Reclassify works out the occupancy of every property again and returns
+// how many fall in each class.
+func (s *SQL) Reclassify(ctx context.Context) (map[occupancy.Class]int, error) {
+	var records []tax.PropertyRecord
+	for after := int32(math.MinInt32); ; {
+		rows, err := s.pdb.ListPropertyAddresses(ctx, pgdb.ListPropertyAddressesParams{ID: after, Limit: reclassifyPage})
+		if err != nil {
+			return nil, fmt.Errorf("ListPropertyAddresses: %w", err)
+		}
+		for _, r := range rows {
+			records = append(records, tax.PropertyRecord{
+				PropertyID:          fmt.Sprint(r.ID),
+				Address:             r.Address.String,
+				OwnerMailingAddress: r.OwnerMailingAddress.String,
+			})
+		}
+		if len(rows) < reclassifyPage {
+			break
+		}
+		after = rows[len(rows)-1].ID
+	}
+	countyZIPs := map[string]bool{}
+	addZIPs(countyZIPs, records)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := s.pdb.InTx(tx)
+	counts := map[occupancy.Class]int{}
+	for _, pr := range records {
+		c := classify(pr, countyZIPs)
+		if err := q.UpdatePropertyOccupancy(ctx, pgdb.UpdatePropertyOccupancyParams{
+			Occupancy: stringToNullString(string(c)),
+			SitusZip:  stringToNullString(occupancy.Parse(pr.Address).ZIP),
+			ID:        stringToInt32(pr.PropertyID),
+		}); err != nil {
+			return nil, fmt.Errorf("UpdatePropertyOccupancy: %w", err)
+		}
+		counts[c]++
+	}
+	return counts, tx.Commit()
+}
+
+func (m *Memory) Reclassify(ctx context.Context) (map[occupancy.Class]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	countyZIPs := m.countyZIPs()
+	counts := map[occupancy.Class]int{}
+	for id, p := range m.props {
+		p.Occupancy = classify(p.PropertyRecord, countyZIPs)
+		m.props[id] = p
+		counts[p.Occupancy]++
+	}
+	return counts, nil
+}
+
+// countyZIPs returns the situs ZIP codes of every property held. The
+// caller holds m.mu.
+func (m *Memory) countyZIPs() map[string]bool {
+	records := make([]tax.PropertyRecord, 0, len(m.props))
+	for _, p := range m.props {
+		records = append(records, p.PropertyRecord)
+	}
+	countyZIPs := map[string]bool{}
+	addZIPs(countyZIPs, records)
+	return countyZIPs
+}
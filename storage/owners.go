@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// Owner is a property owner as the county identifies it. Saving a property
+// updates its owner's name and mailing address, so they are the ones last
+// scraped.
+type Owner struct {
+	ID             int32  `json:"id"`
+	Name           string `json:"name"`
+	MailingAddress string `json:"mailingAddress"`
+}
+
+// Holding is one property in an owner's portfolio.
+type Holding struct {
+	PropertyID   int32  `json:"propertyID"`
+	Address      string `json:"address"`
+	Neighborhood string `json:"neighborhood"`
+	// Latitude and Longitude are nil until the address has been geocoded.
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Appraised is the value of the latest roll year, or 0 without one.
+	Appraised int64 `json:"appraised"`
+}
+
+// Portfolio is an owner with everything it holds.
+type Portfolio struct {
+	Owner
+	Properties     int       `json:"properties"`
+	TotalAppraised int64     `json:"totalAppraised"`
+	Holdings       []Holding `json:"holdings"`
+}
+
+// OwnerHoldings sums up what an owner holds in one neighborhood.
+type OwnerHoldings struct {
+	Owner
+	Properties     int   `json:"properties"`
+	TotalAppraised int64 `json:"totalAppraised"`
+}
+
+// OwnerStore answers questions about who owns what.
+type OwnerStore interface {
+	// Portfolio returns an owner with its properties in id order, or
+	// ErrNotFound.
+	Portfolio(ctx context.Context, id int32) (Portfolio, error)
+	// TopOwners returns the owners holding the most properties in a
+	// neighborhood, matched ignoring case, with ties going to the greater
+	// total appraised value. limit caps the results; 0 means DefaultLimit.
+	TopOwners(ctx context.Context, neighborhood string, limit int) ([]OwnerHoldings, error)
+}
+
+// ownerOf returns the owner a record names, or false when the scraper
+// couldn't read an owner id. Names and addresses have their runs of
+// whitespace collapsed, since the portal wraps them differently from page
+// to page.
+func ownerOf(pr tax.PropertyRecord) (Owner, bool) {
+	id := stringToInt32(pr.OwnerID)
+	if id == 0 {
+		return Owner{}, false
+	}
+	return Owner{
+		ID:             id,
+		Name:           strings.Join(strings.Fields(pr.OwnerName), " "),
+		MailingAddress: strings.Join(strings.Fields(pr.OwnerMailingAddress), " "),
+	}, true
+}
+
+// ownerParams returns an upsert per owner in records, the last record
+// naming an owner winning.
+func ownerParams(records []tax.PropertyRecord) []pgdb.UpsertOwnerParams {
+	var params []pgdb.UpsertOwnerParams
+	seen := map[int32]int{}
+	for _, pr := range records {
+		o, ok := ownerOf(pr)
+		if !ok {
+			continue
+		}
+		p := pgdb.UpsertOwnerParams{
+			ID:             o.ID,
+			Name:           stringToNullString(o.Name),
+			MailingAddress: stringToNullString(o.MailingAddress),
+		}
+		if i, ok := seen[o.ID]; ok {
+			params[i] = p
+			continue
+		}
+		seen[o.ID] = len(params)
+		params = append(params, p)
+	}
+	return params
+}
+
+func (s *SQL) Portfolio(ctx context.Context, id int32) (Portfolio, error) {
+	o, err := s.pdb.GetOwner(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Portfolio{}, ErrNotFound
+	}
+	if err != nil {
+		return Portfolio{}, err
+	}
+	rows, err := s.pdb.GetOwnerHoldings(ctx, sql.NullInt32{Int32: id, Valid: true})
+	if err != nil {
+		return Portfolio{}, err
+	}
+	p := Portfolio{Owner: Owner{ID: o.ID, Name: o.Name.String, MailingAddress: o.MailingAddress.String}}
+	for _, r := range rows {
+		h := Holding{
+			PropertyID:   r.ID,
+			Address:      r.Address.String,
+			Neighborhood: r.Neighborhood.String,
+			Appraised:    int64(r.Appraised),
+		}
+		if r.Latitude.Valid && r.Longitude.Valid {
+			h.Latitude, h.Longitude = &r.Latitude.Float64, &r.Longitude.Float64
+		}
+		p.add(h)
+	}
+	return p, nil
+}
+
+func (s *SQL) TopOwners(ctx context.Context, neighborhood string, limit int) ([]OwnerHoldings, error) {
+	rows, err := s.pdb.GetTopOwnersByNeighborhood(ctx, pgdb.GetTopOwnersByNeighborhoodParams{
+		Neighborhood: neighborhood,
+		MaxResults:   int32(PropertyFilter{Limit: limit}.limit()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]OwnerHoldings, 0, len(rows))
+	for _, r := range rows {
+		owners = append(owners, OwnerHoldings{
+			Owner:          Owner{ID: r.ID, Name: r.Name.String, MailingAddress: r.MailingAddress.String},
+			Properties:     int(r.Properties),
+			TotalAppraised: r.TotalAppraised,
+		})
+	}
+	return owners, nil
+}
+
+func (m *Memory) Portfolio(ctx context.Context, id int32) (Portfolio, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.owners[id]
+	if !ok {
+		return Portfolio{}, ErrNotFound
+	}
+	p := Portfolio{Owner: o}
+	for _, pid := range m.sortedPropertyIDs() {
+		pr := m.props[pid]
+		if stringToInt32(pr.OwnerID) != id {
+			continue
+		}
+		h := Holding{
+			PropertyID:   pid,
+			Address:      pr.Address,
+			Neighborhood: pr.Neighborhood,
+			Appraised:    latestAppraised(pr.PropertyRecord),
+		}
+		if pr.Latitude.Valid && pr.Longitude.Valid {
+			lat, lon := pr.Latitude.Float64, pr.Longitude.Float64
+			h.Latitude, h.Longitude = &lat, &lon
+		}
+		p.add(h)
+	}
+	return p, nil
+}
+
+func (m *Memory) TopOwners(ctx context.Context, neighborhood string, limit int) ([]OwnerHoldings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byOwner := map[int32]*OwnerHoldings{}
+	for _, p := range m.props {
+		if !strings.EqualFold(p.Neighborhood, neighborhood) {
+			continue
+		}
+		o, ok := m.owners[stringToInt32(p.OwnerID)]
+		if !ok {
+			continue
+		}
+		oh := byOwner[o.ID]
+		if oh == nil {
+			oh = &OwnerHoldings{Owner: o}
+			byOwner[o.ID] = oh
+		}
+		oh.Properties++
+		oh.TotalAppraised += latestAppraised(p.PropertyRecord)
+	}
+	owners := make([]OwnerHoldings, 0, len(byOwner))
+	for _, oh := range byOwner {
+		owners = append(owners, *oh)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		a, b := owners[i], owners[j]
+		if a.Properties != b.Properties {
+			return a.Properties > b.Properties
+		}
+		if a.TotalAppraised != b.TotalAppraised {
+			return a.TotalAppraised > b.TotalAppraised
+		}
+		return a.ID < b.ID
+	})
+	if n := (PropertyFilter{Limit: limit}).limit(); len(owners) > n {
+		owners = owners[:n]
+	}
+	return owners, nil
+}
+
+func (p *Portfolio) add(h Holding) {
+	p.Holdings = append(p.Holdings, h)
+	p.Properties++
+	p.TotalAppraised += h.Appraised
+}
+
+// latestAppraised is the appraised value of a record's latest roll year,
+// read the way the SQL store would have stored it.
+func latestAppraised(pr tax.PropertyRecord) int64 {
+	var latest tax.RollValue
+	year := -1
+	for _, r := range pr.RollValue {
+		y, _ := strconv.Atoi(r.Year)
+		if y > year {
+			latest, year = r, y
+		}
+	}
+	return int64(stringToInt32(latest.Appraised))
+}
@@ -0,0 +1,129 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Multi-property variants of the generated Get*ByPropertyID queries, so a
+// set of properties loads with one query per table instead of one per
+// property and improvement. Maintained by hand like batch.go, with their
+// columns from columns.go.
+
+// queryByIDs runs query with the placeholder list for ids in place of %s,
+// in chunks that stay under maxBatchParams, calling scan for every row.
+func (q *Queries) queryByIDs(ctx context.Context, query string, ids []int32, scan func(*sql.Rows) error) error {
+	for start := 0; start < len(ids); start += maxBatchParams {
+		end := start + maxBatchParams
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := make([]interface{}, 0, end-start)
+		for _, id := range ids[start:end] {
+			chunk = append(chunk, id)
+		}
+		if err := q.scanAll(ctx, strings.Replace(query, "%s", inList(len(chunk)), 1), chunk, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *Queries) scanAll(ctx context.Context, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+func (q *Queries) GetPropertiesByIDs(ctx context.Context, ids []int32) ([]Property, error) {
+	var items []Property
+	err := q.queryByIDs(ctx, "select "+columnNames(propertyColumns(&Property{}))+" from properties\nwhere id in %s\norder by id", ids, func(rows *sql.Rows) error {
+		var i Property
+		if err := rows.Scan(fields(propertyColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
+
+func (q *Queries) GetRollValuesByPropertyIDs(ctx context.Context, ids []int32) ([]RollValue, error) {
+	var items []RollValue
+	err := q.queryByIDs(ctx, "select "+columnNames(rollValueColumns(&RollValue{}))+" from roll_values\nwhere property_id in %s\norder by property_id, id", ids, func(rows *sql.Rows) error {
+		var i RollValue
+		if err := rows.Scan(fields(rollValueColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
+
+func (q *Queries) GetLandByPropertyIDs(ctx context.Context, ids []int32) ([]Land, error) {
+	var items []Land
+	err := q.queryByIDs(ctx, "select "+columnNames(landColumns(&Land{}))+" from land\nwhere property_id in %s\norder by property_id, id", ids, func(rows *sql.Rows) error {
+		var i Land
+		if err := rows.Scan(fields(landColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
+
+func (q *Queries) GetJurisdictionsByPropertyIDs(ctx context.Context, ids []int32) ([]Jurisdiction, error) {
+	var items []Jurisdiction
+	err := q.queryByIDs(ctx, "select "+columnNames(jurisdictionColumns(&Jurisdiction{}))+" from jurisdictions\nwhere property_id in %s\norder by property_id, id", ids, func(rows *sql.Rows) error {
+		var i Jurisdiction
+		if err := rows.Scan(fields(jurisdictionColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
+
+func (q *Queries) GetImprovementsByPropertyIDs(ctx context.Context, ids []int32) ([]Improvement, error) {
+	var items []Improvement
+	err := q.queryByIDs(ctx, "select "+columnNames(improvementColumns(&Improvement{}))+" from improvements\nwhere property_id in %s\norder by property_id, id", ids, func(rows *sql.Rows) error {
+		var i Improvement
+		if err := rows.Scan(fields(improvementColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
+
+// GetImprovementDetailsByPropertyIDs returns the details of every
+// improvement of the properties in ids.
+func (q *Queries) GetImprovementDetailsByPropertyIDs(ctx context.Context, ids []int32) ([]ImprovementDetail, error) {
+	var items []ImprovementDetail
+	err := q.queryByIDs(ctx, "select "+columnNames(improvementDetailColumns(&ImprovementDetail{}))+" from improvement_detail\n"+
+		"where improvement_id in (select id from improvements where property_id in %s)\norder by improvement_id, id", ids, func(rows *sql.Rows) error {
+		var i ImprovementDetail
+		if err := rows.Scan(fields(improvementDetailColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
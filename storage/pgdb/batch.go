@@ -0,0 +1,169 @@
+package pgdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Multi-row variants of the generated inserts, used by the scraper's batch
+// writer. sqlc can't generate a variable number of VALUES tuples, so these are
+// maintained by hand, with their columns from columns.go.
+
+// maxBatchParams keeps every statement below SQLite's default host parameter
+// limit; Postgres allows far more.
+const maxBatchParams = 900
+
+// valuesList returns "($1,$2),($3,$4)" style placeholders for rows tuples of
+// cols parameters each.
+func valuesList(rows, cols int) string {
+	var sb strings.Builder
+	n := 1
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteByte('(')
+		for c := 0; c < cols; c++ {
+			if c > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "$%d", n)
+			n++
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// execBatched runs prefix + VALUES + suffix over args in chunks that stay
+// under maxBatchParams. args holds cols values per row.
+func (q *Queries) execBatched(ctx context.Context, prefix, suffix string, cols int, args []interface{}) error {
+	perStmt := maxBatchParams / cols
+	for start := 0; start < len(args); start += perStmt * cols {
+		end := start + perStmt*cols
+		if end > len(args) {
+			end = len(args)
+		}
+		chunk := args[start:end]
+		query := prefix + " values " + valuesList(len(chunk)/cols, cols) + suffix
+		if _, err := q.db.ExecContext(ctx, query, chunk...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func inList(n int) string {
+	ph := make([]string, n)
+	for i := range ph {
+		ph[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return "(" + strings.Join(ph, ",") + ")"
+}
+
+// insertRows inserts rows, each given as its columns, into table with suffix
+// after the VALUES list.
+func (q *Queries) insertRows(ctx context.Context, table string, rows [][]column, suffix string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	var vals []interface{}
+	for _, r := range rows {
+		vals = append(vals, fields(r)...)
+	}
+	return q.execBatched(ctx, "insert into "+table+"("+columnNames(rows[0])+")", suffix, len(rows[0]), vals)
+}
+
+func (q *Queries) InsertPropertyRecords(ctx context.Context, args []InsertPropertyRecordParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = insertPropertyColumns(a)
+	}
+	return q.insertRows(ctx, "properties", rows, excludedSet(insertPropertyColumns(InsertPropertyRecordParams{}), "id"))
+}
+
+func (q *Queries) InsertRollValues(ctx context.Context, args []InsertRollValueParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = insertRollValueColumns(a)
+	}
+	return q.insertRows(ctx, "roll_values", rows, "")
+}
+
+func (q *Queries) InsertJurisdictions(ctx context.Context, args []InsertJurisdictionParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = insertJurisdictionColumns(a)
+	}
+	return q.insertRows(ctx, "jurisdictions", rows, "")
+}
+
+func (q *Queries) InsertLands(ctx context.Context, args []InsertLandParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = insertLandColumns(a)
+	}
+	return q.insertRows(ctx, "land", rows, "")
+}
+
+func (q *Queries) InsertImprovementDetails(ctx context.Context, args []InsertImprovementDetailParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = insertImprovementDetailColumns(a)
+	}
+	return q.insertRows(ctx, "improvement_detail", rows, "")
+}
+
+func (q *Queries) UpsertOwners(ctx context.Context, args []UpsertOwnerParams) error {
+	rows := make([][]column, len(args))
+	for i, a := range args {
+		rows[i] = upsertOwnerColumns(a)
+	}
+	return q.insertRows(ctx, "owners", rows, excludedSet(upsertOwnerColumns(UpsertOwnerParams{}), "id"))
+}
+
+// DeletePropertyChildren removes the roll values, jurisdictions, land,
+// improvements and improvement details of every property in ids.
+func (q *Queries) DeletePropertyChildren(ctx context.Context, ids []int32) error {
+	for start := 0; start < len(ids); start += maxBatchParams {
+		end := start + maxBatchParams
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := make([]interface{}, 0, end-start)
+		for _, id := range ids[start:end] {
+			chunk = append(chunk, id)
+		}
+		in := inList(len(chunk))
+		for _, query := range []string{
+			"delete from improvement_detail where improvement_id in (select id from improvements where property_id in " + in + ")",
+			"delete from improvements where property_id in " + in,
+			"delete from jurisdictions where property_id in " + in,
+			"delete from land where property_id in " + in,
+			"delete from roll_values where property_id in " + in,
+		} {
+			if _, err := q.db.ExecContext(ctx, query, chunk...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *Queries) RemovePendingURLs(ctx context.Context, urls []string) error {
+	for start := 0; start < len(urls); start += maxBatchParams {
+		end := start + maxBatchParams
+		if end > len(urls) {
+			end = len(urls)
+		}
+		chunk := make([]interface{}, 0, end-start)
+		for _, u := range urls[start:end] {
+			chunk = append(chunk, u)
+		}
+		if _, err := q.db.ExecContext(ctx, "delete from pending_urls where url in "+inList(len(chunk)), chunk...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
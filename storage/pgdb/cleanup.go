@@ -0,0 +1,65 @@
+package pgdb
+
+import (
+	"context"
+)
+
+// Orphans and duplicates in the child tables, left by runs from before they
+// had foreign keys and natural keys. Maintained by hand like batch.go.
+
+// CleanupCount is how many rows of a table had one kind of problem.
+type CleanupCount struct {
+	Table   string
+	Problem string
+	Rows    int64
+}
+
+// cleanupChecks are in the order Cleanup removes them. A detail is orphaned
+// along with its improvement, and duplicates only count among rows that
+// still have a property, so counting without removing gives the same totals.
+// orphaned matches child rows whose property is gone.
+const orphaned = "property_id is null or property_id not in (select id from properties)"
+
+var cleanupChecks = []struct {
+	table   string
+	problem string
+	where   string
+}{
+	{"improvement_detail", "orphaned", "improvement_id is null or improvement_id not in " +
+		"(select id from improvements where property_id in (select id from properties))"},
+	{"improvements", "orphaned", orphaned},
+	{"jurisdictions", "orphaned", orphaned},
+	{"land", "orphaned", orphaned},
+	{"roll_values", "orphaned", orphaned},
+	{"land", "duplicate", "property_id in (select id from properties) and number is not null and id not in " +
+		"(select max(id) from land group by property_id, number)"},
+	{"roll_values", "duplicate", "property_id in (select id from properties) and year is not null and id not in " +
+		"(select max(id) from roll_values group by property_id, year)"},
+}
+
+// Cleanup counts child rows whose property or improvement is gone, and roll
+// values and land that repeat a year or number of their property, and
+// removes them unless dryRun is set. Of duplicates the newest row is kept.
+// Run it in a transaction to get a consistent report.
+func (q *Queries) Cleanup(ctx context.Context, dryRun bool) ([]CleanupCount, error) {
+	var counts []CleanupCount
+	for _, c := range cleanupChecks {
+		var n int64
+		if dryRun {
+			err := q.db.QueryRowContext(ctx, "select count(*) from "+c.table+" where "+c.where).Scan(&n)
+			if err != nil {
+				return counts, err
+			}
+		} else {
+			res, err := q.db.ExecContext(ctx, "delete from "+c.table+" where "+c.where)
+			if err != nil {
+				return counts, err
+			}
+			if n, err = res.RowsAffected(); err != nil {
+				return counts, err
+			}
+		}
+		counts = append(counts, CleanupCount{Table: c.table, Problem: c.problem, Rows: n})
+	}
+	return counts, nil
+}
@@ -0,0 +1,119 @@
+package pgdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+)
+
+// childKeysVersion is the migration that adds the keys Cleanup makes room for.
+const childKeysVersion = 6
+
+func Test_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	for d, db := range testDatabases(t) {
+		// Go back to the schema without keys, which let the mess in.
+		m, err := migrate.New(db, string(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Down(ctx, m.Latest()-childKeysVersion+1); err != nil {
+			t.Fatalf("%s: %v", d, err)
+		}
+		for _, stmt := range []string{
+			"insert into properties (id) values (1)",
+			"insert into roll_values (year, property_id) values (2020, 1), (2020, 1), (2021, 1), (2020, 2)",
+			"insert into land (number, property_id) values (1, 1), (1, 1), (1, 1), (2, 1)",
+			"insert into jurisdictions (property_id) values (1), (2), (null)",
+			"insert into improvements (id, property_id) values (10, 1), (11, 2)",
+			"insert into improvement_detail (improvement_id) values (10), (11), (12)",
+		} {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				t.Fatalf("%s: %s: %v", d, stmt, err)
+			}
+		}
+
+		want := map[string]int64{
+			"improvement_detail orphaned": 2,
+			"improvements orphaned":       1,
+			"jurisdictions orphaned":      2,
+			"land orphaned":               0,
+			"roll_values orphaned":        1,
+			"land duplicate":              2,
+			"roll_values duplicate":       1,
+		}
+		check := func(dryRun bool) {
+			counts, err := NewDialect(db, d).Cleanup(ctx, dryRun)
+			if err != nil {
+				t.Fatalf("%s Cleanup(%v): %v", d, dryRun, err)
+			}
+			if len(counts) != len(want) {
+				t.Fatalf("%s Cleanup(%v) = %v", d, dryRun, counts)
+			}
+			for _, c := range counts {
+				if w := want[c.Table+" "+c.Problem]; c.Rows != w {
+					t.Errorf("%s Cleanup(%v) %s %s = %d, want %d", d, dryRun, c.Table, c.Problem, c.Rows, w)
+				}
+			}
+		}
+		check(true)
+		check(false)
+		for k := range want {
+			want[k] = 0
+		}
+		check(true)
+
+		var rolls int
+		if err := db.QueryRowContext(ctx, "select count(*) from roll_values").Scan(&rolls); err != nil {
+			t.Fatal(err)
+		}
+		if rolls != 2 {
+			t.Errorf("%s: %d roll values left, want 2", d, rolls)
+		}
+		if _, err := m.Up(ctx); err != nil {
+			t.Errorf("%s: migrating a cleaned up database: %v", d, err)
+		}
+	}
+}
+
+func Test_ChildKeys(t *testing.T) {
+	ctx := context.Background()
+	db := migratetest.Open(t)
+
+	for _, stmt := range []string{
+		"insert into properties (id) values (1)",
+		"insert into roll_values (year, property_id) values (2020, 1)",
+		"insert into land (number, property_id) values (1, 1)",
+		"insert into jurisdictions (property_id) values (1)",
+		"insert into improvements (id, property_id) values (10, 1)",
+		"insert into improvement_detail (improvement_id) values (10)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	for _, stmt := range []string{
+		"insert into roll_values (year, property_id) values (2020, 1)",
+		"insert into land (number, property_id) values (1, 1)",
+		"insert into jurisdictions (property_id) values (2)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err == nil {
+			t.Errorf("%s succeeded", stmt)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, "delete from properties where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"roll_values", "land", "jurisdictions", "improvements", "improvement_detail"} {
+		var n int
+		if err := db.QueryRowContext(ctx, "select count(*) from "+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%d rows left in %s after deleting their property", n, table)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package pgdb
+
+import (
+	"strings"
+)
+
+// Column lists for the hand-written queries in aggregate.go, search.go and
+// batch.go. Each function below pairs the columns of a table with the
+// fields of a model or params struct, so a statement's column list and the
+// values it scans or sends come from one place. A column added in a
+// migration is added to its function once, in models.go order for models.
+
+// column pairs a column with a pointer to the field it scans into, or with
+// the value it inserts.
+type column struct {
+	name  string
+	field interface{}
+}
+
+func columnNames(cols []column) string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+func fields(cols []column) []interface{} {
+	fs := make([]interface{}, len(cols))
+	for i, c := range cols {
+		fs[i] = c.field
+	}
+	return fs
+}
+
+// excludedSet returns an on conflict update setting every column but key
+// to the row that was refused.
+func excludedSet(cols []column, key string) string {
+	var set []string
+	for _, c := range cols {
+		if c.name != key {
+			set = append(set, c.name+" = excluded."+c.name)
+		}
+	}
+	return "\non conflict (" + key + ") do update\n    set " + strings.Join(set, ",\n        ")
+}
+
+func propertyColumns(i *Property) []column {
+	return []column{
+		{"id", &i.ID},
+		{"owner_id", &i.OwnerID},
+		{"owner_name", &i.OwnerName},
+		{"owner_mailing_address", &i.OwnerMailingAddress},
+		{"zoning", &i.Zoning},
+		{"neighborhood_cd", &i.NeighborhoodCd},
+		{"neighborhood", &i.Neighborhood},
+		{"address", &i.Address},
+		{"legal_description", &i.LegalDescription},
+		{"geographic_id", &i.GeographicID},
+		{"exemptions", &i.Exemptions},
+		{"ownership_percentage", &i.OwnershipPercentage},
+		{"mapsco_map_id", &i.MapscoMapID},
+		{"longitude", &i.Longitude},
+		{"latitude", &i.Latitude},
+		{"address_number", &i.AddressNumber},
+		{"address_line_two", &i.AddressLineTwo},
+		{"city", &i.City},
+		{"street", &i.Street},
+		{"county", &i.County},
+		{"state", &i.State},
+		{"last_scraped_at", &i.LastScrapedAt},
+		{"watched", &i.Watched},
+		{"refresh_priority", &i.RefreshPriority},
+		{"occupancy", &i.Occupancy},
+		{"situs_zip", &i.SitusZip},
+	}
+}
+
+func rollValueColumns(i *RollValue) []column {
+	return []column{
+		{"id", &i.ID},
+		{"year", &i.Year},
+		{"improvements", &i.Improvements},
+		{"land_market", &i.LandMarket},
+		{"ag_valuation", &i.AgValuation},
+		{"appraised", &i.Appraised},
+		{"homestead_cap", &i.HomesteadCap},
+		{"assessed", &i.Assessed},
+		{"property_id", &i.PropertyID},
+	}
+}
+
+func landColumns(i *Land) []column {
+	return []column{
+		{"id", &i.ID},
+		{"number", &i.Number},
+		{"land_type", &i.LandType},
+		{"description", &i.Description},
+		{"acres", &i.Acres},
+		{"square_feet", &i.SquareFeet},
+		{"eff_front", &i.EffFront},
+		{"eff_depth", &i.EffDepth},
+		{"market_value", &i.MarketValue},
+		{"property_id", &i.PropertyID},
+	}
+}
+
+func jurisdictionColumns(i *Jurisdiction) []column {
+	return []column{
+		{"id", &i.ID},
+		{"entity", &i.Entity},
+		{"description", &i.Description},
+		{"tax_rate", &i.TaxRate},
+		{"appraised_value", &i.AppraisedValue},
+		{"taxable_value", &i.TaxableValue},
+		{"estimated_tax", &i.EstimatedTax},
+		{"property_id", &i.PropertyID},
+	}
+}
+
+func improvementColumns(i *Improvement) []column {
+	return []column{
+		{"id", &i.ID},
+		{"name", &i.Name},
+		{"description", &i.Description},
+		{"state_code", &i.StateCode},
+		{"living_area", &i.LivingArea},
+		{"value", &i.Value},
+		{"property_id", &i.PropertyID},
+	}
+}
+
+func improvementDetailColumns(i *ImprovementDetail) []column {
+	return []column{
+		{"id", &i.ID},
+		{"improvement_id", &i.ImprovementID},
+		{"improvement_type", &i.ImprovementType},
+		{"description", &i.Description},
+		{"class", &i.Class},
+		{"exterior_wall", &i.ExteriorWall},
+		{"year_built", &i.YearBuilt},
+		{"square_feet", &i.SquareFeet},
+	}
+}
+
+// insertPropertyColumns leaves out the columns scraping doesn't fill in, so
+// that rescraping a property keeps its coordinates, address parts and
+// refresh settings.
+func insertPropertyColumns(a InsertPropertyRecordParams) []column {
+	return []column{
+		{"id", a.ID},
+		{"owner_id", a.OwnerID},
+		{"owner_name", a.OwnerName},
+		{"owner_mailing_address", a.OwnerMailingAddress},
+		{"zoning", a.Zoning},
+		{"neighborhood_cd", a.NeighborhoodCd},
+		{"neighborhood", a.Neighborhood},
+		{"address", a.Address},
+		{"legal_description", a.LegalDescription},
+		{"geographic_id", a.GeographicID},
+		{"exemptions", a.Exemptions},
+		{"ownership_percentage", a.OwnershipPercentage},
+		{"mapsco_map_id", a.MapscoMapID},
+		{"last_scraped_at", a.LastScrapedAt},
+		{"occupancy", a.Occupancy},
+		{"situs_zip", a.SitusZip},
+	}
+}
+
+func insertRollValueColumns(a InsertRollValueParams) []column {
+	return []column{
+		{"year", a.Year},
+		{"improvements", a.Improvements},
+		{"land_market", a.LandMarket},
+		{"ag_valuation", a.AgValuation},
+		{"appraised", a.Appraised},
+		{"homestead_cap", a.HomesteadCap},
+		{"assessed", a.Assessed},
+		{"property_id", a.PropertyID},
+	}
+}
+
+func insertJurisdictionColumns(a InsertJurisdictionParams) []column {
+	return []column{
+		{"entity", a.Entity},
+		{"description", a.Description},
+		{"tax_rate", a.TaxRate},
+		{"appraised_value", a.AppraisedValue},
+		{"taxable_value", a.TaxableValue},
+		{"estimated_tax", a.EstimatedTax},
+		{"property_id", a.PropertyID},
+	}
+}
+
+func insertLandColumns(a InsertLandParams) []column {
+	return []column{
+		{"number", a.Number},
+		{"land_type", a.LandType},
+		{"description", a.Description},
+		{"acres", a.Acres},
+		{"square_feet", a.SquareFeet},
+		{"eff_front", a.EffFront},
+		{"eff_depth", a.EffDepth},
+		{"market_value", a.MarketValue},
+		{"property_id", a.PropertyID},
+	}
+}
+
+func insertImprovementDetailColumns(a InsertImprovementDetailParams) []column {
+	return []column{
+		{"improvement_id", a.ImprovementID},
+		{"improvement_type", a.ImprovementType},
+		{"description", a.Description},
+		{"class", a.Class},
+		{"exterior_wall", a.ExteriorWall},
+		{"year_built", a.YearBuilt},
+		{"square_feet", a.SquareFeet},
+	}
+}
+
+func upsertOwnerColumns(a UpsertOwnerParams) []column {
+	return []column{
+		{"id", a.ID},
+		{"name", a.Name},
+		{"mailing_address", a.MailingAddress},
+	}
+}
@@ -0,0 +1,104 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect is the SQL flavour of the database behind a Queries, named after
+// its database/sql driver. The queries in query.sql are written for Postgres
+// and most run unchanged on SQLite, which accepts $N placeholders too; the
+// rest have a SQLite spelling in sqliteQueries.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite3"
+)
+
+// DialectFor returns the dialect of a database/sql driver name.
+func DialectFor(driver string) (Dialect, error) {
+	switch d := Dialect(driver); d {
+	case Postgres, SQLite:
+		return d, nil
+	}
+	return "", fmt.Errorf("unsupported database driver %q", driver)
+}
+
+// DialectOf tells which dialect db speaks from its driver.
+func DialectOf(db *sql.DB) Dialect {
+	if strings.Contains(fmt.Sprintf("%T", db.Driver()), "sqlite") {
+		return SQLite
+	}
+	return Postgres
+}
+
+// sqliteQueries maps the text of Postgres-only queries to what SQLite runs
+// instead. An entry has to change whenever its query in query.sql does.
+var sqliteQueries = map[string]string{
+	// SQLite has neither concat nor :: casts, and its like ignores case, so
+	// the case-sensitive street prefix match compares the prefix instead.
+	getStreetsLike: `-- name: GetStreetsLike :many
+Select  distinct street from properties where substr(street, 1, length($1)) = $1 order by street asc
+`,
+	getNeighborhoodsLike: `-- name: GetNeighborhoodsLike :many
+Select  distinct neighborhood from properties where Upper(neighborhood) like Upper($1) || '%' order by neighborhood asc
+`,
+}
+
+// NewDialect is New for a database of dialect d.
+func NewDialect(db DBTX, d Dialect) *Queries {
+	if d == SQLite {
+		return New(&rewriter{DBTX: db, queries: sqliteQueries})
+	}
+	return New(db)
+}
+
+// NewFor is New in the dialect of db's driver. db may be nil.
+func NewFor(db *sql.DB) *Queries {
+	if db == nil {
+		return New(nil)
+	}
+	return NewDialect(db, DialectOf(db))
+}
+
+// rewriter swaps query text on its way to the database.
+type rewriter struct {
+	DBTX
+	queries map[string]string
+}
+
+func (r *rewriter) query(q string) string {
+	if alt, ok := r.queries[q]; ok {
+		return alt
+	}
+	return q
+}
+
+func (r *rewriter) ExecContext(ctx context.Context, q string, args ...interface{}) (sql.Result, error) {
+	return r.DBTX.ExecContext(ctx, r.query(q), args...)
+}
+
+func (r *rewriter) PrepareContext(ctx context.Context, q string) (*sql.Stmt, error) {
+	return r.DBTX.PrepareContext(ctx, r.query(q))
+}
+
+func (r *rewriter) QueryContext(ctx context.Context, q string, args ...interface{}) (*sql.Rows, error) {
+	return r.DBTX.QueryContext(ctx, r.query(q), args...)
+}
+
+func (r *rewriter) QueryRowContext(ctx context.Context, q string, args ...interface{}) *sql.Row {
+	return r.DBTX.QueryRowContext(ctx, r.query(q), args...)
+}
+
+// InTx is WithTx keeping q's dialect: the generated WithTx runs every query
+// through tx as written, so on SQLite the Postgres-only ones would fail. Use
+// InTx rather than WithTx outside this package.
+func (q *Queries) InTx(tx *sql.Tx) *Queries {
+	if r, ok := q.db.(*rewriter); ok {
+		return New(&rewriter{DBTX: tx, queries: r.queries})
+	}
+	return New(tx)
+}
@@ -51,6 +51,12 @@ type Land struct {
 	PropertyID  sql.NullInt32
 }
 
+type Owner struct {
+	ID             int32
+	Name           sql.NullString
+	MailingAddress sql.NullString
+}
+
 type PendingUrl struct {
 	Url string
 }
@@ -77,13 +83,31 @@ type Property struct {
 	Street              sql.NullString
 	County              sql.NullString
 	State               sql.NullString
+	LastScrapedAt       sql.NullTime
+	Watched             bool
+	RefreshPriority     int32
+	Occupancy           sql.NullString
+	SitusZip            sql.NullString
 }
 
 type Proxy struct {
-	Ip       string
-	Lastused sql.NullString
-	Uses     sql.NullInt32
-	IsBad    sql.NullInt32
+	Ip                  string
+	Uses                sql.NullInt32
+	IsBad               sql.NullInt32
+	Successes           int32
+	Failures            int32
+	ConsecutiveFailures int32
+	LatencyMs           float64
+	CooldownUntil       sql.NullTime
+	Url                 sql.NullString
+	LastUsedAt          sql.NullTime
+	LeasedUntil         sql.NullTime
+	LeaseToken          sql.NullString
+}
+
+type RequestBudget struct {
+	Day  string
+	Used int32
 }
 
 type RollValue struct {
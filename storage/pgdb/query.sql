@@ -37,15 +37,69 @@ WHERE property_id = $1;
 SELECT * FROM jurisdictions
 WHERE property_id = $1;
 
--- name: GetValidProxy :one
-select ip, lastused, uses
-from proxies
-where is_bad = 0
-order by lastused asc, uses asc
-limit 1;
-
--- name: UpdateProxyLastUsedTime :exec
-update proxies set lastused = $1, uses = $2 where ip = $3;
+-- name: GetProxyByIP :one
+SELECT * FROM proxies
+WHERE ip = $1 limit 1;
+
+-- name: ListAvailableProxies :many
+SELECT * FROM proxies
+WHERE is_bad = 0 and (cooldown_until is null or cooldown_until <= $1);
+
+-- name: ListProxies :many
+SELECT * FROM proxies
+ORDER BY ip;
+
+-- name: RecordProxySuccess :execrows
+update proxies
+set successes = successes + 1, consecutive_failures = 0, cooldown_until = null,
+    latency_ms = case when latency_ms = 0 then sqlc.arg(latency_ms)
+                      else latency_ms * sqlc.arg(decay) + sqlc.arg(sample) end
+where ip = sqlc.arg(ip);
+
+-- name: RecordProxyFailure :execrows
+update proxies
+set failures = failures + 1, consecutive_failures = consecutive_failures + 1, cooldown_until = sqlc.arg(cooldown_until)
+where ip = sqlc.arg(ip) and consecutive_failures = sqlc.arg(consecutive_failures);
+
+-- name: AddProxyHealth :exec
+update proxies
+set successes = successes + sqlc.arg(successes), failures = failures + sqlc.arg(failures),
+    consecutive_failures = sqlc.arg(consecutive_failures), latency_ms = sqlc.arg(latency_ms),
+    cooldown_until = sqlc.arg(cooldown_until)
+where ip = sqlc.arg(ip);
+
+-- name: ClaimProxy :execrows
+update proxies
+set lease_token = $1, leased_until = $2, last_used_at = $3, uses = coalesce(uses, 0) + 1
+where ip = $4 and is_bad = 0
+  and (cooldown_until is null or cooldown_until <= $3)
+  and (leased_until is null or leased_until <= $3);
+
+-- name: ReleaseProxy :execrows
+update proxies set lease_token = null, leased_until = null
+where ip = $1 and lease_token = $2;
+
+-- name: UpdateProxyUsage :exec
+update proxies set uses = coalesce(uses, 0) + $1, last_used_at = $2
+where ip = $3;
+
+-- name: ResetProxy :execrows
+update proxies set is_bad = 0, consecutive_failures = 0, cooldown_until = null
+where ip = $1;
+
+-- name: ResetAllProxies :execrows
+update proxies set is_bad = 0, consecutive_failures = 0, cooldown_until = null
+where is_bad <> 0 or consecutive_failures > 0 or cooldown_until is not null;
+
+-- name: MarkProxyBad :exec
+update proxies set is_bad = 1 where ip = $1;
+
+-- name: DeleteProxy :execrows
+delete from proxies where ip = $1;
+
+-- name: InsertProxy :exec
+insert into proxies(ip, url, uses, is_bad) values ($1, $2, 0, 0)
+on conflict (ip) do update set url = excluded.url;
 
 -- name: InsertLand :exec
 insert into land(number, land_type, description, acres, square_feet, eff_front, eff_depth, market_value, property_id) values($1,$2,$3,$4,$5,$6,$7,$8,$9);
@@ -54,8 +108,25 @@ insert into land(number, land_type, description, acres, square_feet, eff_front,
 insert into properties(id,owner_id,owner_name,owner_mailing_address,
                        zoning,neighborhood_cd,neighborhood,
                        address, legal_description, geographic_id, exemptions,
-                       ownership_percentage, mapsco_map_id)
-values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13);
+                       ownership_percentage, mapsco_map_id, last_scraped_at,
+                       occupancy, situs_zip)
+values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+on conflict (id) do update
+    set owner_id = excluded.owner_id,
+        owner_name = excluded.owner_name,
+        owner_mailing_address = excluded.owner_mailing_address,
+        zoning = excluded.zoning,
+        neighborhood_cd = excluded.neighborhood_cd,
+        neighborhood = excluded.neighborhood,
+        address = excluded.address,
+        legal_description = excluded.legal_description,
+        geographic_id = excluded.geographic_id,
+        exemptions = excluded.exemptions,
+        ownership_percentage = excluded.ownership_percentage,
+        mapsco_map_id = excluded.mapsco_map_id,
+        last_scraped_at = excluded.last_scraped_at,
+        occupancy = excluded.occupancy,
+        situs_zip = excluded.situs_zip;
 
 -- name: InsertRollValue :exec
 insert into roll_values( year, improvements, land_market, ag_valuation, appraised, homestead_cap, assessed, property_id) values($1,$2,$3,$4,$5,$6,$7,$8);
@@ -102,6 +173,12 @@ Select * from properties limit $1 offset $2;
 Update properties set address_number = $1, address_line_two = $2, street = $3, city = $4, county = $5, state = $6
 where id = $7;
 
+-- name: ListUngeocodedProperties :many
+select id, address from properties where (latitude is null or longitude is null) and address != '';
+
+-- name: UpdatePropertyCoordinates :exec
+update properties set latitude = $1, longitude = $2 where id = $3;
+
 -- name: GetStreetsLike :many
 Select  distinct street from properties where street like concat($1::text,'%') order by street asc;
 
@@ -116,3 +193,96 @@ Select Distinct street from properties order by street asc;
 
 -- name: GetDistinctNeighborhoods :many
 Select Distinct neighborhood from properties order by neighborhood asc;
+
+
+-- name: GetRefreshCandidates :many
+select id, last_scraped_at, watched, refresh_priority
+from properties
+where (watched and (last_scraped_at is null or last_scraped_at < sqlc.arg(watched_before)))
+   or (not watched and (last_scraped_at is null or last_scraped_at < sqlc.arg(others_before)))
+order by watched desc, refresh_priority desc, last_scraped_at asc nulls first
+limit sqlc.arg(max_results);
+
+-- name: EnqueuePendingURL :exec
+insert into pending_urls(url) values ($1) on conflict do nothing;
+
+-- name: SetPropertyWatched :execrows
+update properties set watched = $1, refresh_priority = $2 where id = $3;
+
+-- name: DeleteImprovementDetailsByPropertyID :exec
+delete from improvement_detail
+where improvement_id in (select id from improvements where property_id = $1);
+
+-- name: DeleteImprovementsByPropertyID :exec
+delete from improvements where property_id = $1;
+
+-- name: DeleteJurisdictionsByPropertyID :exec
+delete from jurisdictions where property_id = $1;
+
+-- name: DeleteLandByPropertyID :exec
+delete from land where property_id = $1;
+
+-- name: DeleteRollValuesByPropertyID :exec
+delete from roll_values where property_id = $1;
+
+-- name: DeleteProperty :execrows
+delete from properties where id = $1;
+
+-- name: UpsertOwner :exec
+insert into owners(id, name, mailing_address)
+values ($1, $2, $3)
+on conflict (id) do update
+    set name = excluded.name,
+        mailing_address = excluded.mailing_address;
+
+-- name: GetOwner :one
+select id, name, mailing_address from owners
+where id = $1;
+
+-- name: GetOwnerHoldings :many
+select p.id, p.address, p.neighborhood, p.latitude, p.longitude,
+       coalesce((select r.appraised from roll_values r
+                 where r.property_id = p.id
+                 order by r.year desc nulls last
+                 limit 1), 0) as appraised
+from properties p
+where p.owner_id = $1
+order by p.id;
+
+-- name: GetTopOwnersByNeighborhood :many
+select o.id, o.name, o.mailing_address, count(*) as properties, coalesce(sum(h.appraised), 0) as total_appraised
+from (select p.owner_id,
+             coalesce((select r.appraised from roll_values r
+                       where r.property_id = p.id
+                       order by r.year desc nulls last
+                       limit 1), 0) as appraised
+      from properties p
+      where lower(p.neighborhood) = lower(sqlc.arg(neighborhood))) h
+         join owners o on o.id = h.owner_id
+group by o.id, o.name, o.mailing_address
+order by properties desc, total_appraised desc, o.id
+limit sqlc.arg(max_results);
+
+-- name: GetSitusZips :many
+select distinct situs_zip from properties
+where situs_zip is not null and situs_zip <> '';
+
+-- name: ListPropertyAddresses :many
+select id, address, owner_mailing_address from properties
+where id > $1
+order by id
+limit $2;
+
+-- name: UpdatePropertyOccupancy :exec
+update properties set occupancy = $1, situs_zip = $2 where id = $3;
+
+-- name: AddRequestBudgetDay :exec
+insert into request_budget(day) values ($1)
+on conflict (day) do nothing;
+
+-- name: SpendRequestBudget :execrows
+update request_budget set used = used + 1
+where day = sqlc.arg(day) and used < sqlc.arg(budget);
+
+-- name: GetRequestBudgetUsed :one
+select used from request_budget where day = $1;
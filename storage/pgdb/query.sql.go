@@ -8,6 +8,152 @@ import (
 	"database/sql"
 )
 
+const addProxyHealth = `-- name: AddProxyHealth :exec
+update proxies
+set successes = successes + $1, failures = failures + $2,
+    consecutive_failures = $3, latency_ms = $4,
+    cooldown_until = $5
+where ip = $6
+`
+
+type AddProxyHealthParams struct {
+	Successes           int32
+	Failures            int32
+	ConsecutiveFailures int32
+	LatencyMs           float64
+	CooldownUntil       sql.NullTime
+	Ip                  string
+}
+
+func (q *Queries) AddProxyHealth(ctx context.Context, arg AddProxyHealthParams) error {
+	_, err := q.db.ExecContext(ctx, addProxyHealth,
+		arg.Successes,
+		arg.Failures,
+		arg.ConsecutiveFailures,
+		arg.LatencyMs,
+		arg.CooldownUntil,
+		arg.Ip,
+	)
+	return err
+}
+
+const addRequestBudgetDay = `-- name: AddRequestBudgetDay :exec
+insert into request_budget(day) values ($1)
+on conflict (day) do nothing
+`
+
+func (q *Queries) AddRequestBudgetDay(ctx context.Context, day string) error {
+	_, err := q.db.ExecContext(ctx, addRequestBudgetDay, day)
+	return err
+}
+
+const claimProxy = `-- name: ClaimProxy :execrows
+update proxies
+set lease_token = $1, leased_until = $2, last_used_at = $3, uses = coalesce(uses, 0) + 1
+where ip = $4 and is_bad = 0
+  and (cooldown_until is null or cooldown_until <= $3)
+  and (leased_until is null or leased_until <= $3)
+`
+
+type ClaimProxyParams struct {
+	LeaseToken  sql.NullString
+	LeasedUntil sql.NullTime
+	LastUsedAt  sql.NullTime
+	Ip          string
+}
+
+func (q *Queries) ClaimProxy(ctx context.Context, arg ClaimProxyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimProxy,
+		arg.LeaseToken,
+		arg.LeasedUntil,
+		arg.LastUsedAt,
+		arg.Ip,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteImprovementDetailsByPropertyID = `-- name: DeleteImprovementDetailsByPropertyID :exec
+delete from improvement_detail
+where improvement_id in (select id from improvements where property_id = $1)
+`
+
+func (q *Queries) DeleteImprovementDetailsByPropertyID(ctx context.Context, propertyID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, deleteImprovementDetailsByPropertyID, propertyID)
+	return err
+}
+
+const deleteImprovementsByPropertyID = `-- name: DeleteImprovementsByPropertyID :exec
+delete from improvements where property_id = $1
+`
+
+func (q *Queries) DeleteImprovementsByPropertyID(ctx context.Context, propertyID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, deleteImprovementsByPropertyID, propertyID)
+	return err
+}
+
+const deleteJurisdictionsByPropertyID = `-- name: DeleteJurisdictionsByPropertyID :exec
+delete from jurisdictions where property_id = $1
+`
+
+func (q *Queries) DeleteJurisdictionsByPropertyID(ctx context.Context, propertyID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, deleteJurisdictionsByPropertyID, propertyID)
+	return err
+}
+
+const deleteLandByPropertyID = `-- name: DeleteLandByPropertyID :exec
+delete from land where property_id = $1
+`
+
+func (q *Queries) DeleteLandByPropertyID(ctx context.Context, propertyID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, deleteLandByPropertyID, propertyID)
+	return err
+}
+
+const deleteProperty = `-- name: DeleteProperty :execrows
+delete from properties where id = $1
+`
+
+func (q *Queries) DeleteProperty(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteProperty, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteProxy = `-- name: DeleteProxy :execrows
+delete from proxies where ip = $1
+`
+
+func (q *Queries) DeleteProxy(ctx context.Context, ip string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteProxy, ip)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRollValuesByPropertyID = `-- name: DeleteRollValuesByPropertyID :exec
+delete from roll_values where property_id = $1
+`
+
+func (q *Queries) DeleteRollValuesByPropertyID(ctx context.Context, propertyID sql.NullInt32) error {
+	_, err := q.db.ExecContext(ctx, deleteRollValuesByPropertyID, propertyID)
+	return err
+}
+
+const enqueuePendingURL = `-- name: EnqueuePendingURL :exec
+insert into pending_urls(url) values ($1) on conflict do nothing
+`
+
+func (q *Queries) EnqueuePendingURL(ctx context.Context, url string) error {
+	_, err := q.db.ExecContext(ctx, enqueuePendingURL, url)
+	return err
+}
+
 const getDistinctNeighborhoods = `-- name: GetDistinctNeighborhoods :many
 Select Distinct neighborhood from properties order by neighborhood asc
 `
@@ -365,8 +511,70 @@ func (q *Queries) GetNeighborhoodsLike(ctx context.Context, upper string) ([]sql
 	return items, nil
 }
 
+const getOwner = `-- name: GetOwner :one
+select id, name, mailing_address from owners
+where id = $1
+`
+
+func (q *Queries) GetOwner(ctx context.Context, id int32) (Owner, error) {
+	row := q.db.QueryRowContext(ctx, getOwner, id)
+	var i Owner
+	err := row.Scan(&i.ID, &i.Name, &i.MailingAddress)
+	return i, err
+}
+
+const getOwnerHoldings = `-- name: GetOwnerHoldings :many
+select p.id, p.address, p.neighborhood, p.latitude, p.longitude,
+       coalesce((select r.appraised from roll_values r
+                 where r.property_id = p.id
+                 order by r.year desc nulls last
+                 limit 1), 0) as appraised
+from properties p
+where p.owner_id = $1
+order by p.id
+`
+
+type GetOwnerHoldingsRow struct {
+	ID           int32
+	Address      sql.NullString
+	Neighborhood sql.NullString
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	Appraised    int32
+}
+
+func (q *Queries) GetOwnerHoldings(ctx context.Context, ownerID sql.NullInt32) ([]GetOwnerHoldingsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOwnerHoldings, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOwnerHoldingsRow
+	for rows.Next() {
+		var i GetOwnerHoldingsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Address,
+			&i.Neighborhood,
+			&i.Latitude,
+			&i.Longitude,
+			&i.Appraised,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPropertyByID = `-- name: GetPropertyByID :one
-SELECT id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state FROM properties
+SELECT id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state, last_scraped_at, watched, refresh_priority, occupancy, situs_zip FROM properties
 WHERE id = $1 limit 1
 `
 
@@ -395,12 +603,17 @@ func (q *Queries) GetPropertyByID(ctx context.Context, id int32) (Property, erro
 		&i.Street,
 		&i.County,
 		&i.State,
+		&i.LastScrapedAt,
+		&i.Watched,
+		&i.RefreshPriority,
+		&i.Occupancy,
+		&i.SitusZip,
 	)
 	return i, err
 }
 
 const getPropertyByNeighborhood = `-- name: GetPropertyByNeighborhood :many
-SELECT id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state FROM properties
+SELECT id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state, last_scraped_at, watched, refresh_priority, occupancy, situs_zip FROM properties
 WHERE neighborhood = $1
 `
 
@@ -435,6 +648,11 @@ func (q *Queries) GetPropertyByNeighborhood(ctx context.Context, neighborhood sq
 			&i.Street,
 			&i.County,
 			&i.State,
+			&i.LastScrapedAt,
+			&i.Watched,
+			&i.RefreshPriority,
+			&i.Occupancy,
+			&i.SitusZip,
 		); err != nil {
 			return nil, err
 		}
@@ -450,7 +668,7 @@ func (q *Queries) GetPropertyByNeighborhood(ctx context.Context, neighborhood sq
 }
 
 const getPropertyByStreet = `-- name: GetPropertyByStreet :many
-Select id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state from properties where UPPER(street) = UPPER($1) order by address_number,street,city asc
+Select id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state, last_scraped_at, watched, refresh_priority, occupancy, situs_zip from properties where UPPER(street) = UPPER($1) order by address_number,street,city asc
 `
 
 func (q *Queries) GetPropertyByStreet(ctx context.Context, upper string) ([]Property, error) {
@@ -484,6 +702,11 @@ func (q *Queries) GetPropertyByStreet(ctx context.Context, upper string) ([]Prop
 			&i.Street,
 			&i.County,
 			&i.State,
+			&i.LastScrapedAt,
+			&i.Watched,
+			&i.RefreshPriority,
+			&i.Occupancy,
+			&i.SitusZip,
 		); err != nil {
 			return nil, err
 		}
@@ -498,6 +721,31 @@ func (q *Queries) GetPropertyByStreet(ctx context.Context, upper string) ([]Prop
 	return items, nil
 }
 
+const getProxyByIP = `-- name: GetProxyByIP :one
+SELECT ip, uses, is_bad, successes, failures, consecutive_failures, latency_ms, cooldown_until, url, last_used_at, leased_until, lease_token FROM proxies
+WHERE ip = $1 limit 1
+`
+
+func (q *Queries) GetProxyByIP(ctx context.Context, ip string) (Proxy, error) {
+	row := q.db.QueryRowContext(ctx, getProxyByIP, ip)
+	var i Proxy
+	err := row.Scan(
+		&i.Ip,
+		&i.Uses,
+		&i.IsBad,
+		&i.Successes,
+		&i.Failures,
+		&i.ConsecutiveFailures,
+		&i.LatencyMs,
+		&i.CooldownUntil,
+		&i.Url,
+		&i.LastUsedAt,
+		&i.LeasedUntil,
+		&i.LeaseToken,
+	)
+	return i, err
+}
+
 const getRandomURLs = `-- name: GetRandomURLs :many
 SELECT url  FROM pending_urls
 ORDER BY RANDOM()
@@ -527,6 +775,56 @@ func (q *Queries) GetRandomURLs(ctx context.Context, limit int32) ([]string, err
 	return items, nil
 }
 
+const getRefreshCandidates = `-- name: GetRefreshCandidates :many
+select id, last_scraped_at, watched, refresh_priority
+from properties
+where (watched and (last_scraped_at is null or last_scraped_at < $1))
+   or (not watched and (last_scraped_at is null or last_scraped_at < $2))
+order by watched desc, refresh_priority desc, last_scraped_at asc nulls first
+limit $3
+`
+
+type GetRefreshCandidatesParams struct {
+	WatchedBefore sql.NullTime
+	OthersBefore  sql.NullTime
+	MaxResults    int32
+}
+
+type GetRefreshCandidatesRow struct {
+	ID              int32
+	LastScrapedAt   sql.NullTime
+	Watched         bool
+	RefreshPriority int32
+}
+
+func (q *Queries) GetRefreshCandidates(ctx context.Context, arg GetRefreshCandidatesParams) ([]GetRefreshCandidatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRefreshCandidates, arg.WatchedBefore, arg.OthersBefore, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRefreshCandidatesRow
+	for rows.Next() {
+		var i GetRefreshCandidatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LastScrapedAt,
+			&i.Watched,
+			&i.RefreshPriority,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getRemainingURLCount = `-- name: GetRemainingURLCount :one
 Select count(url) from pending_urls
 `
@@ -538,6 +836,17 @@ func (q *Queries) GetRemainingURLCount(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const getRequestBudgetUsed = `-- name: GetRequestBudgetUsed :one
+select used from request_budget where day = $1
+`
+
+func (q *Queries) GetRequestBudgetUsed(ctx context.Context, day string) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getRequestBudgetUsed, day)
+	var used int32
+	err := row.Scan(&used)
+	return used, err
+}
+
 const getRollValuesByPropertyID = `-- name: GetRollValuesByPropertyID :many
 Select id, year, improvements, land_market, ag_valuation, appraised, homestead_cap, assessed, property_id from roll_values
 where property_id = $1
@@ -576,6 +885,34 @@ func (q *Queries) GetRollValuesByPropertyID(ctx context.Context, propertyID sql.
 	return items, nil
 }
 
+const getSitusZips = `-- name: GetSitusZips :many
+select distinct situs_zip from properties
+where situs_zip is not null and situs_zip <> ''
+`
+
+func (q *Queries) GetSitusZips(ctx context.Context) ([]sql.NullString, error) {
+	rows, err := q.db.QueryContext(ctx, getSitusZips)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []sql.NullString
+	for rows.Next() {
+		var situs_zip sql.NullString
+		if err := rows.Scan(&situs_zip); err != nil {
+			return nil, err
+		}
+		items = append(items, situs_zip)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getStreetsLike = `-- name: GetStreetsLike :many
 Select  distinct street from properties where street like concat($1::text,'%') order by street asc
 `
@@ -603,25 +940,61 @@ func (q *Queries) GetStreetsLike(ctx context.Context, dollar_1 string) ([]sql.Nu
 	return items, nil
 }
 
-const getValidProxy = `-- name: GetValidProxy :one
-select ip, lastused, uses
-from proxies
-where is_bad = 0
-order by lastused asc, uses asc
-limit 1
+const getTopOwnersByNeighborhood = `-- name: GetTopOwnersByNeighborhood :many
+select o.id, o.name, o.mailing_address, count(*) as properties, coalesce(sum(h.appraised), 0) as total_appraised
+from (select p.owner_id,
+             coalesce((select r.appraised from roll_values r
+                       where r.property_id = p.id
+                       order by r.year desc nulls last
+                       limit 1), 0) as appraised
+      from properties p
+      where lower(p.neighborhood) = lower($1)) h
+         join owners o on o.id = h.owner_id
+group by o.id, o.name, o.mailing_address
+order by properties desc, total_appraised desc, o.id
+limit $2
 `
 
-type GetValidProxyRow struct {
-	Ip       string
-	Lastused sql.NullString
-	Uses     sql.NullInt32
+type GetTopOwnersByNeighborhoodParams struct {
+	Neighborhood string
+	MaxResults   int32
 }
 
-func (q *Queries) GetValidProxy(ctx context.Context) (GetValidProxyRow, error) {
-	row := q.db.QueryRowContext(ctx, getValidProxy)
-	var i GetValidProxyRow
-	err := row.Scan(&i.Ip, &i.Lastused, &i.Uses)
-	return i, err
+type GetTopOwnersByNeighborhoodRow struct {
+	ID             int32
+	Name           sql.NullString
+	MailingAddress sql.NullString
+	Properties     int64
+	TotalAppraised int64
+}
+
+func (q *Queries) GetTopOwnersByNeighborhood(ctx context.Context, arg GetTopOwnersByNeighborhoodParams) ([]GetTopOwnersByNeighborhoodRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTopOwnersByNeighborhood, arg.Neighborhood, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTopOwnersByNeighborhoodRow
+	for rows.Next() {
+		var i GetTopOwnersByNeighborhoodRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.MailingAddress,
+			&i.Properties,
+			&i.TotalAppraised,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const insertImprovement = `-- name: InsertImprovement :one
@@ -740,8 +1113,25 @@ const insertPropertyRecord = `-- name: InsertPropertyRecord :exec
 insert into properties(id,owner_id,owner_name,owner_mailing_address,
                        zoning,neighborhood_cd,neighborhood,
                        address, legal_description, geographic_id, exemptions,
-                       ownership_percentage, mapsco_map_id)
-values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+                       ownership_percentage, mapsco_map_id, last_scraped_at,
+                       occupancy, situs_zip)
+values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+on conflict (id) do update
+    set owner_id = excluded.owner_id,
+        owner_name = excluded.owner_name,
+        owner_mailing_address = excluded.owner_mailing_address,
+        zoning = excluded.zoning,
+        neighborhood_cd = excluded.neighborhood_cd,
+        neighborhood = excluded.neighborhood,
+        address = excluded.address,
+        legal_description = excluded.legal_description,
+        geographic_id = excluded.geographic_id,
+        exemptions = excluded.exemptions,
+        ownership_percentage = excluded.ownership_percentage,
+        mapsco_map_id = excluded.mapsco_map_id,
+        last_scraped_at = excluded.last_scraped_at,
+        occupancy = excluded.occupancy,
+        situs_zip = excluded.situs_zip
 `
 
 type InsertPropertyRecordParams struct {
@@ -758,6 +1148,9 @@ type InsertPropertyRecordParams struct {
 	Exemptions          sql.NullString
 	OwnershipPercentage sql.NullFloat64
 	MapscoMapID         sql.NullString
+	LastScrapedAt       sql.NullTime
+	Occupancy           sql.NullString
+	SitusZip            sql.NullString
 }
 
 func (q *Queries) InsertPropertyRecord(ctx context.Context, arg InsertPropertyRecordParams) error {
@@ -775,10 +1168,28 @@ func (q *Queries) InsertPropertyRecord(ctx context.Context, arg InsertPropertyRe
 		arg.Exemptions,
 		arg.OwnershipPercentage,
 		arg.MapscoMapID,
+		arg.LastScrapedAt,
+		arg.Occupancy,
+		arg.SitusZip,
 	)
 	return err
 }
 
+const insertProxy = `-- name: InsertProxy :exec
+insert into proxies(ip, url, uses, is_bad) values ($1, $2, 0, 0)
+on conflict (ip) do update set url = excluded.url
+`
+
+type InsertProxyParams struct {
+	Ip  string
+	Url sql.NullString
+}
+
+func (q *Queries) InsertProxy(ctx context.Context, arg InsertProxyParams) error {
+	_, err := q.db.ExecContext(ctx, insertProxy, arg.Ip, arg.Url)
+	return err
+}
+
 const insertRollValue = `-- name: InsertRollValue :exec
 insert into roll_values( year, improvements, land_market, ag_valuation, appraised, homestead_cap, assessed, property_id) values($1,$2,$3,$4,$5,$6,$7,$8)
 `
@@ -819,8 +1230,49 @@ func (q *Queries) IsExistingProperty(ctx context.Context, id int32) (bool, error
 	return exists, err
 }
 
+const listAvailableProxies = `-- name: ListAvailableProxies :many
+SELECT ip, uses, is_bad, successes, failures, consecutive_failures, latency_ms, cooldown_until, url, last_used_at, leased_until, lease_token FROM proxies
+WHERE is_bad = 0 and (cooldown_until is null or cooldown_until <= $1)
+`
+
+func (q *Queries) ListAvailableProxies(ctx context.Context, cooldownUntil sql.NullTime) ([]Proxy, error) {
+	rows, err := q.db.QueryContext(ctx, listAvailableProxies, cooldownUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Proxy
+	for rows.Next() {
+		var i Proxy
+		if err := rows.Scan(
+			&i.Ip,
+			&i.Uses,
+			&i.IsBad,
+			&i.Successes,
+			&i.Failures,
+			&i.ConsecutiveFailures,
+			&i.LatencyMs,
+			&i.CooldownUntil,
+			&i.Url,
+			&i.LastUsedAt,
+			&i.LeasedUntil,
+			&i.LeaseToken,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listProperties = `-- name: ListProperties :many
-Select id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state from properties limit $1 offset $2
+Select id, owner_id, owner_name, owner_mailing_address, zoning, neighborhood_cd, neighborhood, address, legal_description, geographic_id, exemptions, ownership_percentage, mapsco_map_id, longitude, latitude, address_number, address_line_two, city, street, county, state, last_scraped_at, watched, refresh_priority, occupancy, situs_zip from properties limit $1 offset $2
 `
 
 type ListPropertiesParams struct {
@@ -859,6 +1311,93 @@ func (q *Queries) ListProperties(ctx context.Context, arg ListPropertiesParams)
 			&i.Street,
 			&i.County,
 			&i.State,
+			&i.LastScrapedAt,
+			&i.Watched,
+			&i.RefreshPriority,
+			&i.Occupancy,
+			&i.SitusZip,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPropertyAddresses = `-- name: ListPropertyAddresses :many
+select id, address, owner_mailing_address from properties
+where id > $1
+order by id
+limit $2
+`
+
+type ListPropertyAddressesParams struct {
+	ID    int32
+	Limit int32
+}
+
+type ListPropertyAddressesRow struct {
+	ID                  int32
+	Address             sql.NullString
+	OwnerMailingAddress sql.NullString
+}
+
+func (q *Queries) ListPropertyAddresses(ctx context.Context, arg ListPropertyAddressesParams) ([]ListPropertyAddressesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPropertyAddresses, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPropertyAddressesRow
+	for rows.Next() {
+		var i ListPropertyAddressesRow
+		if err := rows.Scan(&i.ID, &i.Address, &i.OwnerMailingAddress); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProxies = `-- name: ListProxies :many
+SELECT ip, uses, is_bad, successes, failures, consecutive_failures, latency_ms, cooldown_until, url, last_used_at, leased_until, lease_token FROM proxies
+ORDER BY ip
+`
+
+func (q *Queries) ListProxies(ctx context.Context) ([]Proxy, error) {
+	rows, err := q.db.QueryContext(ctx, listProxies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Proxy
+	for rows.Next() {
+		var i Proxy
+		if err := rows.Scan(
+			&i.Ip,
+			&i.Uses,
+			&i.IsBad,
+			&i.Successes,
+			&i.Failures,
+			&i.ConsecutiveFailures,
+			&i.LatencyMs,
+			&i.CooldownUntil,
+			&i.Url,
+			&i.LastUsedAt,
+			&i.LeasedUntil,
+			&i.LeaseToken,
 		); err != nil {
 			return nil, err
 		}
@@ -873,6 +1412,113 @@ func (q *Queries) ListProperties(ctx context.Context, arg ListPropertiesParams)
 	return items, nil
 }
 
+const listUngeocodedProperties = `-- name: ListUngeocodedProperties :many
+select id, address from properties where (latitude is null or longitude is null) and address != ''
+`
+
+type ListUngeocodedPropertiesRow struct {
+	ID      int32
+	Address sql.NullString
+}
+
+func (q *Queries) ListUngeocodedProperties(ctx context.Context) ([]ListUngeocodedPropertiesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUngeocodedProperties)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUngeocodedPropertiesRow
+	for rows.Next() {
+		var i ListUngeocodedPropertiesRow
+		if err := rows.Scan(&i.ID, &i.Address); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markProxyBad = `-- name: MarkProxyBad :exec
+update proxies set is_bad = 1 where ip = $1
+`
+
+func (q *Queries) MarkProxyBad(ctx context.Context, ip string) error {
+	_, err := q.db.ExecContext(ctx, markProxyBad, ip)
+	return err
+}
+
+const recordProxyFailure = `-- name: RecordProxyFailure :execrows
+update proxies
+set failures = failures + 1, consecutive_failures = consecutive_failures + 1, cooldown_until = $1
+where ip = $2 and consecutive_failures = $3
+`
+
+type RecordProxyFailureParams struct {
+	CooldownUntil       sql.NullTime
+	Ip                  string
+	ConsecutiveFailures int32
+}
+
+func (q *Queries) RecordProxyFailure(ctx context.Context, arg RecordProxyFailureParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordProxyFailure, arg.CooldownUntil, arg.Ip, arg.ConsecutiveFailures)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordProxySuccess = `-- name: RecordProxySuccess :execrows
+update proxies
+set successes = successes + 1, consecutive_failures = 0, cooldown_until = null,
+    latency_ms = case when latency_ms = 0 then $1
+                      else latency_ms * $2 + $3 end
+where ip = $4
+`
+
+type RecordProxySuccessParams struct {
+	LatencyMs float64
+	Decay     float64
+	Sample    float64
+	Ip        string
+}
+
+func (q *Queries) RecordProxySuccess(ctx context.Context, arg RecordProxySuccessParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordProxySuccess,
+		arg.LatencyMs,
+		arg.Decay,
+		arg.Sample,
+		arg.Ip,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const releaseProxy = `-- name: ReleaseProxy :execrows
+update proxies set lease_token = null, leased_until = null
+where ip = $1 and lease_token = $2
+`
+
+type ReleaseProxyParams struct {
+	Ip         string
+	LeaseToken sql.NullString
+}
+
+func (q *Queries) ReleaseProxy(ctx context.Context, arg ReleaseProxyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, releaseProxy, arg.Ip, arg.LeaseToken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const removePendingURL = `-- name: RemovePendingURL :exec
 Delete from pending_urls where url = $1
 `
@@ -882,6 +1528,98 @@ func (q *Queries) RemovePendingURL(ctx context.Context, url string) error {
 	return err
 }
 
+const resetAllProxies = `-- name: ResetAllProxies :execrows
+update proxies set is_bad = 0, consecutive_failures = 0, cooldown_until = null
+where is_bad <> 0 or consecutive_failures > 0 or cooldown_until is not null
+`
+
+func (q *Queries) ResetAllProxies(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resetAllProxies)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resetProxy = `-- name: ResetProxy :execrows
+update proxies set is_bad = 0, consecutive_failures = 0, cooldown_until = null
+where ip = $1
+`
+
+func (q *Queries) ResetProxy(ctx context.Context, ip string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, resetProxy, ip)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setPropertyWatched = `-- name: SetPropertyWatched :execrows
+update properties set watched = $1, refresh_priority = $2 where id = $3
+`
+
+type SetPropertyWatchedParams struct {
+	Watched         bool
+	RefreshPriority int32
+	ID              int32
+}
+
+func (q *Queries) SetPropertyWatched(ctx context.Context, arg SetPropertyWatchedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setPropertyWatched, arg.Watched, arg.RefreshPriority, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const spendRequestBudget = `-- name: SpendRequestBudget :execrows
+update request_budget set used = used + 1
+where day = $1 and used < $2
+`
+
+type SpendRequestBudgetParams struct {
+	Day    string
+	Budget int32
+}
+
+func (q *Queries) SpendRequestBudget(ctx context.Context, arg SpendRequestBudgetParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, spendRequestBudget, arg.Day, arg.Budget)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updatePropertyCoordinates = `-- name: UpdatePropertyCoordinates :exec
+update properties set latitude = $1, longitude = $2 where id = $3
+`
+
+type UpdatePropertyCoordinatesParams struct {
+	Latitude  sql.NullFloat64
+	Longitude sql.NullFloat64
+	ID        int32
+}
+
+func (q *Queries) UpdatePropertyCoordinates(ctx context.Context, arg UpdatePropertyCoordinatesParams) error {
+	_, err := q.db.ExecContext(ctx, updatePropertyCoordinates, arg.Latitude, arg.Longitude, arg.ID)
+	return err
+}
+
+const updatePropertyOccupancy = `-- name: UpdatePropertyOccupancy :exec
+update properties set occupancy = $1, situs_zip = $2 where id = $3
+`
+
+type UpdatePropertyOccupancyParams struct {
+	Occupancy sql.NullString
+	SitusZip  sql.NullString
+	ID        int32
+}
+
+func (q *Queries) UpdatePropertyOccupancy(ctx context.Context, arg UpdatePropertyOccupancyParams) error {
+	_, err := q.db.ExecContext(ctx, updatePropertyOccupancy, arg.Occupancy, arg.SitusZip, arg.ID)
+	return err
+}
+
 const updatePropertySetAddressParts = `-- name: UpdatePropertySetAddressParts :exec
 Update properties set address_number = $1, address_line_two = $2, street = $3, city = $4, county = $5, state = $6
 where id = $7
@@ -910,17 +1648,37 @@ func (q *Queries) UpdatePropertySetAddressParts(ctx context.Context, arg UpdateP
 	return err
 }
 
-const updateProxyLastUsedTime = `-- name: UpdateProxyLastUsedTime :exec
-update proxies set lastused = $1, uses = $2 where ip = $3
+const updateProxyUsage = `-- name: UpdateProxyUsage :exec
+update proxies set uses = coalesce(uses, 0) + $1, last_used_at = $2
+where ip = $3
 `
 
-type UpdateProxyLastUsedTimeParams struct {
-	Lastused sql.NullString
-	Uses     sql.NullInt32
-	Ip       string
+type UpdateProxyUsageParams struct {
+	Uses       int32
+	LastUsedAt sql.NullTime
+	Ip         string
+}
+
+func (q *Queries) UpdateProxyUsage(ctx context.Context, arg UpdateProxyUsageParams) error {
+	_, err := q.db.ExecContext(ctx, updateProxyUsage, arg.Uses, arg.LastUsedAt, arg.Ip)
+	return err
+}
+
+const upsertOwner = `-- name: UpsertOwner :exec
+insert into owners(id, name, mailing_address)
+values ($1, $2, $3)
+on conflict (id) do update
+    set name = excluded.name,
+        mailing_address = excluded.mailing_address
+`
+
+type UpsertOwnerParams struct {
+	ID             int32
+	Name           sql.NullString
+	MailingAddress sql.NullString
 }
 
-func (q *Queries) UpdateProxyLastUsedTime(ctx context.Context, arg UpdateProxyLastUsedTimeParams) error {
-	_, err := q.db.ExecContext(ctx, updateProxyLastUsedTime, arg.Lastused, arg.Uses, arg.Ip)
+func (q *Queries) UpsertOwner(ctx context.Context, arg UpsertOwnerParams) error {
+	_, err := q.db.ExecContext(ctx, upsertOwner, arg.ID, arg.Name, arg.MailingAddress)
 	return err
 }
@@ -0,0 +1,235 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/storage/migrate"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testDatabases opens a migrated, empty database per dialect: an in-memory
+// SQLite one always, and a Postgres one in a throwaway schema when
+// TAX_TEST_POSTGRES_DSN is set.
+func testDatabases(t *testing.T) map[Dialect]*sql.DB {
+	t.Helper()
+	dbs := map[Dialect]*sql.DB{}
+
+	lite, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection gets its own memory database, so keep to one.
+	lite.SetMaxOpenConns(1)
+	lite.SetConnMaxLifetime(0)
+	t.Cleanup(func() { lite.Close() })
+	dbs[SQLite] = lite
+
+	if dsn := os.Getenv("TAX_TEST_POSTGRES_DSN"); dsn != "" {
+		dbs[Postgres] = openTestPostgres(t, dsn)
+	} else {
+		t.Log("TAX_TEST_POSTGRES_DSN not set, skipping postgres")
+	}
+
+	for d, db := range dbs {
+		m, err := migrate.New(db, string(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			t.Fatalf("%s: %v", d, err)
+		}
+	}
+	return dbs
+}
+
+func openTestPostgres(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("pgdb_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("create schema " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("drop schema " + schema + " cascade") })
+
+	sep := " "
+	if strings.Contains(dsn, "://") {
+		sep = "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+	}
+	db, err := sql.Open("postgres", dsn+sep+"search_path="+schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Test_EveryQuery calls every Queries method with zero values, so a query
+// one of the dialects can't run fails here even if nothing else uses it.
+func Test_EveryQuery(t *testing.T) {
+	for d, db := range testDatabases(t) {
+		q := NewDialect(db, d)
+		v := reflect.ValueOf(q)
+		for i := 0; i < v.NumMethod(); i++ {
+			name := v.Type().Method(i).Name
+			m := v.Method(i)
+			mt := m.Type()
+			if mt.NumIn() == 0 || mt.In(0) != reflect.TypeOf((*context.Context)(nil)).Elem() {
+				continue
+			}
+			args := []reflect.Value{reflect.ValueOf(context.Background())}
+			for j := 1; j < mt.NumIn(); j++ {
+				arg := reflect.New(mt.In(j)).Elem()
+				if arg.Kind() == reflect.Slice {
+					arg = reflect.MakeSlice(mt.In(j), 1, 1)
+				}
+				args = append(args, arg)
+			}
+			out := m.Call(args)
+			if err, _ := out[len(out)-1].Interface().(error); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("%s %s: %v", d, name, err)
+			}
+		}
+	}
+}
+
+func Test_PrefixSearch(t *testing.T) {
+	ctx := context.Background()
+	for d, db := range testDatabases(t) {
+		q := NewDialect(db, d)
+		for i, p := range []struct{ street, neighborhood string }{
+			{"MAIN ST", "Downtown"},
+			{"MAPLE AVE", "downtown east"},
+			{"OAK DR", "Westfield"},
+		} {
+			err := q.InsertPropertyRecord(ctx, InsertPropertyRecordParams{
+				ID:           int32(i + 1),
+				Neighborhood: sql.NullString{String: p.neighborhood, Valid: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = q.UpdatePropertySetAddressParts(ctx, UpdatePropertySetAddressPartsParams{
+				ID:     int32(i + 1),
+				Street: sql.NullString{String: p.street, Valid: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		streets, err := q.GetStreetsLike(ctx, "MA")
+		if err != nil {
+			t.Fatalf("%s: %v", d, err)
+		}
+		if len(streets) != 2 || streets[0].String != "MAIN ST" || streets[1].String != "MAPLE AVE" {
+			t.Errorf("%s GetStreetsLike(MA) = %v", d, streets)
+		}
+		if streets, err := q.GetStreetsLike(ctx, "ma"); err != nil || len(streets) != 0 {
+			t.Errorf("%s GetStreetsLike(ma) = %v, %v, want case-sensitive matching", d, streets, err)
+		}
+		hoods, err := q.GetNeighborhoodsLike(ctx, "down")
+		if err != nil {
+			t.Fatalf("%s: %v", d, err)
+		}
+		if len(hoods) != 2 {
+			t.Errorf("%s GetNeighborhoodsLike(down) = %v", d, hoods)
+		}
+
+		if DialectOf(db) != d {
+			t.Errorf("DialectOf(%s database) = %s", d, DialectOf(db))
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.InTx(tx).GetStreetsLike(ctx, "MA"); err != nil {
+			t.Errorf("%s GetStreetsLike in InTx: %v", d, err)
+		}
+		tx.Rollback()
+	}
+}
+
+// Test_LatestRollValue checks the owner queries value a property at its
+// latest roll year, not at a roll value without one, which Postgres sorts
+// ahead of every year.
+func Test_LatestRollValue(t *testing.T) {
+	ctx := context.Background()
+	for d, db := range testDatabases(t) {
+		q := NewDialect(db, d)
+		err := q.InsertPropertyRecord(ctx, InsertPropertyRecordParams{
+			ID:           1,
+			OwnerID:      sql.NullInt32{Int32: 5, Valid: true},
+			Neighborhood: sql.NullString{String: "Elm Creek", Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.UpsertOwner(ctx, UpsertOwnerParams{ID: 5}); err != nil {
+			t.Fatal(err)
+		}
+		for _, rv := range []InsertRollValueParams{
+			{Year: sql.NullInt32{Int32: 2020, Valid: true}, Appraised: sql.NullInt32{Int32: 300, Valid: true}},
+			{Appraised: sql.NullInt32{Int32: 999, Valid: true}},
+		} {
+			rv.PropertyID = sql.NullInt32{Int32: 1, Valid: true}
+			if err := q.InsertRollValue(ctx, rv); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		holdings, err := q.GetOwnerHoldings(ctx, sql.NullInt32{Int32: 5, Valid: true})
+		if err != nil || len(holdings) != 1 || holdings[0].Appraised != 300 {
+			t.Errorf("%s GetOwnerHoldings(5) = %+v, %v, want the 2020 appraisal", d, holdings, err)
+		}
+		top, err := q.GetTopOwnersByNeighborhood(ctx, GetTopOwnersByNeighborhoodParams{Neighborhood: "Elm Creek", MaxResults: 10})
+		if err != nil || len(top) != 1 || top[0].TotalAppraised != 300 {
+			t.Errorf("%s GetTopOwnersByNeighborhood(Elm Creek) = %+v, %v, want the 2020 appraisal", d, top, err)
+		}
+	}
+}
+
+// Test_ColumnLists checks that the column lists in columns.go name every
+// column of their table, in table order, so a migration adding one fails
+// here until it is added there too.
+func Test_ColumnLists(t *testing.T) {
+	tables := map[string][]column{
+		"properties":         propertyColumns(&Property{}),
+		"roll_values":        rollValueColumns(&RollValue{}),
+		"land":               landColumns(&Land{}),
+		"jurisdictions":      jurisdictionColumns(&Jurisdiction{}),
+		"improvements":       improvementColumns(&Improvement{}),
+		"improvement_detail": improvementDetailColumns(&ImprovementDetail{}),
+	}
+	for d, db := range testDatabases(t) {
+		for table, cols := range tables {
+			rows, err := db.Query("select * from " + table + " limit 0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := rows.Columns()
+			rows.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := columnNames(cols); strings.Join(got, ", ") != want {
+				t.Errorf("%s %s columns = %v, columns.go has %s", d, table, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchProperties takes a varying set of conditions, so like batch.go it is
+// maintained by hand rather than generated.
+
+// SearchPropertiesParams narrows SearchProperties. Zero fields match every
+// property.
+type SearchPropertiesParams struct {
+	// Neighborhood and Street match the whole value, ignoring case.
+	Neighborhood string
+	Street       string
+	// Address matches anywhere in the address, ignoring case.
+	Address string
+	// Within keeps properties whose coordinates fall inside the box.
+	Within *BoundingBox
+	// ScrapedSince keeps properties scraped at or after it.
+	ScrapedSince time.Time
+	// Occupancy keeps properties of one occupancy class.
+	Occupancy string
+	// AfterID keeps properties with a greater id, for paging by id.
+	AfterID    int32
+	MaxResults int32
+	Skip       int32
+}
+
+// BoundingBox is an area between two latitudes and two longitudes.
+type BoundingBox struct {
+	MinLongitude, MinLatitude, MaxLongitude, MaxLatitude float64
+}
+
+func (q *Queries) SearchProperties(ctx context.Context, arg SearchPropertiesParams) ([]Property, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	cond := func(format string, vals ...interface{}) {
+		ph := make([]interface{}, len(vals))
+		for i, v := range vals {
+			args = append(args, v)
+			ph[i] = fmt.Sprintf("$%d", len(args))
+		}
+		where = append(where, fmt.Sprintf(format, ph...))
+	}
+	if arg.Neighborhood != "" {
+		cond("upper(neighborhood) = %s", strings.ToUpper(arg.Neighborhood))
+	}
+	if arg.Street != "" {
+		cond("upper(street) = %s", strings.ToUpper(arg.Street))
+	}
+	if arg.Address != "" {
+		cond("upper(address) like %s", "%"+strings.ToUpper(arg.Address)+"%")
+	}
+	if b := arg.Within; b != nil {
+		cond("latitude between %s and %s and longitude between %s and %s",
+			b.MinLatitude, b.MaxLatitude, b.MinLongitude, b.MaxLongitude)
+	}
+	if !arg.ScrapedSince.IsZero() {
+		// Stored in UTC, which SQLite compares as text.
+		cond("last_scraped_at >= %s", arg.ScrapedSince.UTC())
+	}
+	if arg.Occupancy != "" {
+		cond("occupancy = %s", arg.Occupancy)
+	}
+	if arg.AfterID != 0 {
+		cond("id > %s", arg.AfterID)
+	}
+
+	query := "select " + columnNames(propertyColumns(&Property{})) + " from properties"
+	if len(where) > 0 {
+		query += "\nwhere " + strings.Join(where, "\n  and ")
+	}
+	args = append(args, arg.MaxResults, arg.Skip)
+	query += fmt.Sprintf("\norder by id\nlimit $%d offset $%d", len(args)-1, len(args))
+
+	var items []Property
+	err := q.scanAll(ctx, query, args, func(rows *sql.Rows) error {
+		var i Property
+		if err := rows.Scan(fields(propertyColumns(&i))...); err != nil {
+			return err
+		}
+		items = append(items, i)
+		return nil
+	})
+	return items, err
+}
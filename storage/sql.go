@@ -0,0 +1,450 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// SQL is a PropertyStore and OwnerStore on the properties table, its child
+// tables and owners, in either dialect pgdb supports.
+type SQL struct {
+	db  *sql.DB
+	pdb *pgdb.Queries
+	now func() time.Time
+}
+
+func NewSQL(db *sql.DB) *SQL {
+	return &SQL{db: db, pdb: pgdb.NewFor(db), now: time.Now}
+}
+
+// Save writes every record on a single transaction, using multi-row inserts
+// for the properties and their child tables. Improvements still go in one at
+// a time since their details need the generated id.
+func (s *SQL) Save(ctx context.Context, records []tax.PropertyRecord) error {
+	latest := make(map[string]int, len(records))
+	for i, pr := range records {
+		latest[pr.PropertyID] = i
+	}
+
+	now := s.now()
+	var (
+		ids           []int32
+		props         = make([]pgdb.InsertPropertyRecordParams, 0, len(latest))
+		rollValues    []pgdb.InsertRollValueParams
+		jurisdictions []pgdb.InsertJurisdictionParams
+		lands         []pgdb.InsertLandParams
+		unique        []tax.PropertyRecord
+	)
+	for i, pr := range records {
+		if latest[pr.PropertyID] != i {
+			continue
+		}
+		unique = append(unique, pr)
+		ids = append(ids, stringToInt32(pr.PropertyID))
+		props = append(props, propertyParams(pr, now))
+		rollValues = append(rollValues, rollValueParams(pr)...)
+		jurisdictions = append(jurisdictions, jurisdictionParams(pr)...)
+		lands = append(lands, landParams(pr)...)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := s.pdb.InTx(tx)
+
+	zips, err := q.GetSitusZips(ctx)
+	if err != nil {
+		return fmt.Errorf("GetSitusZips: %w", err)
+	}
+	countyZIPs := make(map[string]bool, len(zips))
+	for _, z := range zips {
+		countyZIPs[z.String] = true
+	}
+	addZIPs(countyZIPs, unique)
+	for i, pr := range unique {
+		props[i].Occupancy = stringToNullString(string(classify(pr, countyZIPs)))
+		props[i].SitusZip = stringToNullString(occupancy.Parse(pr.Address).ZIP)
+	}
+
+	if err := q.DeletePropertyChildren(ctx, ids); err != nil {
+		return fmt.Errorf("DeletePropertyChildren: %w", err)
+	}
+	if err := q.UpsertOwners(ctx, ownerParams(unique)); err != nil {
+		return fmt.Errorf("UpsertOwners: %w", err)
+	}
+	if err := q.InsertPropertyRecords(ctx, props); err != nil {
+		return fmt.Errorf("InsertPropertyRecords: %w", err)
+	}
+	if err := q.InsertRollValues(ctx, rollValues); err != nil {
+		return fmt.Errorf("InsertRollValues: %w", err)
+	}
+	if err := q.InsertJurisdictions(ctx, jurisdictions); err != nil {
+		return fmt.Errorf("InsertJurisdictions: %w", err)
+	}
+	if err := q.InsertLands(ctx, lands); err != nil {
+		return fmt.Errorf("InsertLands: %w", err)
+	}
+
+	var details []pgdb.InsertImprovementDetailParams
+	for _, pr := range unique {
+		for _, i := range pr.Improvements {
+			id, err := q.InsertImprovement(ctx, improvementParams(pr, i))
+			if err != nil {
+				return fmt.Errorf("InsertImprovement: %w", err)
+			}
+			details = append(details, improvementDetailParams(id, i)...)
+		}
+	}
+	if err := q.InsertImprovementDetails(ctx, details); err != nil {
+		return fmt.Errorf("InsertImprovementDetails: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL) Property(ctx context.Context, id int32) (Property, error) {
+	props, err := s.Properties(ctx, []int32{id})
+	if err != nil {
+		return Property{}, err
+	}
+	if len(props) == 0 {
+		return Property{}, ErrNotFound
+	}
+	return props[0], nil
+}
+
+// Properties takes one query per table however many properties ids holds,
+// short of the few thousand it takes to split a query into chunks.
+func (s *SQL) Properties(ctx context.Context, ids []int32) ([]Property, error) {
+	// Sorted, so chunks of a long list come back in id order as well.
+	rows, err := s.pdb.GetPropertiesByIDs(ctx, sortedIDs(ids))
+	if err != nil {
+		return nil, err
+	}
+	return s.withChildren(ctx, rows)
+}
+
+// withChildren assembles rows into properties with their children.
+func (s *SQL) withChildren(ctx context.Context, rows []pgdb.Property) ([]Property, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	ids := make([]int32, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+
+	rollValues, err := s.pdb.GetRollValuesByPropertyIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	land, err := s.pdb.GetLandByPropertyIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	jurisdictions, err := s.pdb.GetJurisdictionsByPropertyIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	improvements, err := s.pdb.GetImprovementsByPropertyIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	details, err := s.pdb.GetImprovementDetailsByPropertyIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make([]Property, len(rows))
+	byID := make(map[int32]*Property, len(rows))
+	for i, r := range rows {
+		props[i] = fromRow(r)
+		byID[r.ID] = &props[i]
+	}
+	for _, r := range rollValues {
+		p := byID[r.PropertyID.Int32]
+		p.RollValue = append(p.RollValue, tax.FromRollValueDBModel([]pgdb.RollValue{r})...)
+	}
+	for _, l := range land {
+		p := byID[l.PropertyID.Int32]
+		p.Land = append(p.Land, tax.FromLandDBModel([]pgdb.Land{l})...)
+	}
+	for _, j := range jurisdictions {
+		p := byID[j.PropertyID.Int32]
+		p.Jurisdictions = append(p.Jurisdictions, tax.FromTaxingJurisdictionModel([]pgdb.Jurisdiction{j})...)
+	}
+	detailsOf := map[int32][]pgdb.ImprovementDetail{}
+	for _, d := range details {
+		detailsOf[d.ImprovementID.Int32] = append(detailsOf[d.ImprovementID.Int32], d)
+	}
+	for _, i := range improvements {
+		imp := tax.FromImprovementModel(i)
+		// FromImprovementModel names an improvement by its row id.
+		imp.Name = tax.NullStringToString(i.Name)
+		imp.Details = tax.FromImprovementDetailDBModel(detailsOf[i.ID])
+		p := byID[i.PropertyID.Int32]
+		p.Improvements = append(p.Improvements, imp)
+	}
+	return props, nil
+}
+
+func (s *SQL) List(ctx context.Context, f PropertyFilter) ([]Property, error) {
+	rows, err := s.pdb.SearchProperties(ctx, pgdb.SearchPropertiesParams{
+		Neighborhood: f.Neighborhood,
+		Street:       f.Street,
+		Address:      f.Address,
+		Within:       f.Within,
+		ScrapedSince: f.ScrapedSince,
+		Occupancy:    string(f.Occupancy),
+		AfterID:      f.AfterID,
+		MaxResults:   int32(f.limit()),
+		Skip:         int32(f.Offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if f.Children {
+		return s.withChildren(ctx, rows)
+	}
+	props := make([]Property, 0, len(rows))
+	for _, r := range rows {
+		props = append(props, fromRow(r))
+	}
+	return props, nil
+}
+
+// Delete removes the children itself, since SQLite only cascades when the
+// connection enables foreign keys.
+func (s *SQL) Delete(ctx context.Context, id int32) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := s.pdb.InTx(tx)
+	if err := q.DeletePropertyChildren(ctx, []int32{id}); err != nil {
+		return fmt.Errorf("DeletePropertyChildren: %w", err)
+	}
+	n, err := q.DeleteProperty(ctx, id)
+	if err != nil {
+		return fmt.Errorf("DeleteProperty: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func (s *SQL) History(ctx context.Context, id int32) ([]tax.RollValue, error) {
+	rows, err := s.pdb.GetRollValuesByPropertyID(ctx, sql.NullInt32{Int32: id, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		ok, err := s.pdb.IsExistingProperty(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotFound
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Year.Int32 < rows[j].Year.Int32 })
+	return tax.FromRollValueDBModel(rows), nil
+}
+
+func fromRow(row pgdb.Property) Property {
+	return Property{
+		PropertyRecord: tax.FromPropertyDBModel(row),
+		LastScrapedAt:  row.LastScrapedAt.Time,
+		Street:         row.Street.String,
+		Latitude:       row.Latitude,
+		Longitude:      row.Longitude,
+		Occupancy:      occupancy.Class(row.Occupancy.String),
+	}
+}
+
+func stringToNullInt32(s string) sql.NullInt32 {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		i = 0
+	}
+	return sql.NullInt32{
+		Int32: int32(i),
+		Valid: true,
+	}
+}
+
+// parseNullInt32 is stringToNullInt32 for keys: what doesn't parse is NULL
+// rather than 0, so it doesn't collide with other unreadable keys.
+func parseNullInt32(s string) sql.NullInt32 {
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(i), Valid: true}
+}
+
+func stringToFloat64(s string) sql.NullFloat64 {
+	i, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		i = 0.0
+	}
+	return sql.NullFloat64{
+		Float64: i,
+		Valid:   true,
+	}
+}
+
+func stringToNullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  true,
+	}
+}
+
+func stringToInt32(s string) int32 {
+
+	i, e := strconv.Atoi(s)
+	if e != nil {
+		return int32(0)
+	}
+	return int32(i)
+
+}
+
+// landParams keeps the last land segment of each number, since a property
+// has at most one per number. Segments without a readable number are all
+// kept, with a NULL number.
+func landParams(pr tax.PropertyRecord) []pgdb.InsertLandParams {
+	var params []pgdb.InsertLandParams
+	seen := map[int32]int{}
+	for _, i := range pr.Land {
+		p := pgdb.InsertLandParams{
+			Number:      parseNullInt32(i.Number),
+			LandType:    stringToNullString(i.Type),
+			Description: stringToNullString(i.Description),
+			Acres:       stringToFloat64(i.Acres),
+			SquareFeet:  stringToFloat64(i.Sqft),
+			EffFront:    stringToFloat64(i.EffFront),
+			EffDepth:    stringToFloat64(i.EffDepth),
+			MarketValue: stringToNullInt32(i.MarketValue),
+			PropertyID:  stringToNullInt32(pr.PropertyID),
+		}
+		if !p.Number.Valid {
+			params = append(params, p)
+			continue
+		}
+		if j, ok := seen[p.Number.Int32]; ok {
+			params[j] = p
+			continue
+		}
+		seen[p.Number.Int32] = len(params)
+		params = append(params, p)
+	}
+	return params
+}
+
+func improvementParams(pr tax.PropertyRecord, i tax.Improvement) pgdb.InsertImprovementParams {
+	return pgdb.InsertImprovementParams{
+		Name:        stringToNullString(i.Name),
+		Description: stringToNullString(i.Description),
+		StateCode:   stringToNullString(i.StateCode),
+		LivingArea:  stringToNullInt32(i.LivingArea),
+		Value:       stringToNullInt32(i.Value),
+		PropertyID:  stringToNullInt32(pr.PropertyID),
+	}
+}
+
+func improvementDetailParams(improvementID int32, i tax.Improvement) []pgdb.InsertImprovementDetailParams {
+	var params []pgdb.InsertImprovementDetailParams
+	for _, d := range i.Details {
+		params = append(params, pgdb.InsertImprovementDetailParams{
+			ImprovementID:   sql.NullInt32{Int32: improvementID, Valid: true},
+			ImprovementType: stringToNullString(d.Type),
+			Description:     stringToNullString(d.Description),
+			Class:           stringToNullString(d.Class),
+			ExteriorWall:    stringToNullString(d.ExteriorWall),
+			YearBuilt:       stringToNullInt32(d.YearBuilt),
+			SquareFeet:      stringToNullInt32(d.SqFt),
+		})
+	}
+	return params
+}
+
+func jurisdictionParams(pr tax.PropertyRecord) []pgdb.InsertJurisdictionParams {
+	var params []pgdb.InsertJurisdictionParams
+	for _, j := range pr.Jurisdictions {
+		params = append(params, pgdb.InsertJurisdictionParams{
+			Entity:         sql.NullString{},
+			Description:    sql.NullString{},
+			TaxRate:        stringToNullInt32(j.TaxRate),
+			AppraisedValue: stringToNullInt32(j.AppraisedValue),
+			TaxableValue:   stringToNullInt32(j.TaxableValue),
+			EstimatedTax:   stringToNullInt32(j.EstimatedTax),
+			PropertyID:     stringToNullInt32(pr.PropertyID),
+		})
+	}
+	return params
+}
+
+// rollValueParams keeps the last roll value of each year, since a property
+// has at most one per year. Roll values without a readable year are all
+// kept, with a NULL year.
+func rollValueParams(pr tax.PropertyRecord) []pgdb.InsertRollValueParams {
+	var params []pgdb.InsertRollValueParams
+	seen := map[int32]int{}
+	for _, r := range pr.RollValue {
+		p := pgdb.InsertRollValueParams{
+			Year:         parseNullInt32(r.Year),
+			Improvements: stringToNullInt32(r.Improvements),
+			LandMarket:   stringToNullInt32(r.LandMarket),
+			AgValuation:  stringToNullInt32(r.AgValuation),
+			Appraised:    stringToNullInt32(r.Appraised),
+			HomesteadCap: stringToNullInt32(r.HomesteadCap),
+			Assessed:     stringToNullInt32(r.Assessed),
+			PropertyID:   stringToNullInt32(pr.PropertyID),
+		}
+		if !p.Year.Valid {
+			params = append(params, p)
+			continue
+		}
+		if i, ok := seen[p.Year.Int32]; ok {
+			params[i] = p
+			continue
+		}
+		seen[p.Year.Int32] = len(params)
+		params = append(params, p)
+	}
+	return params
+}
+
+func propertyParams(pr tax.PropertyRecord, scrapedAt time.Time) pgdb.InsertPropertyRecordParams {
+	return pgdb.InsertPropertyRecordParams{
+		ID:                  stringToInt32(pr.PropertyID),
+		OwnerID:             stringToNullInt32(pr.OwnerID),
+		OwnerName:           stringToNullString(pr.OwnerName),
+		OwnerMailingAddress: stringToNullString(pr.OwnerMailingAddress),
+		Zoning:              stringToNullString(pr.Zoning),
+		NeighborhoodCd:      stringToNullString(pr.NeighborhoodCD),
+		Neighborhood:        stringToNullString(pr.Neighborhood),
+		Address:             stringToNullString(pr.Address),
+		LegalDescription:    stringToNullString(pr.LegalDescription),
+		GeographicID:        stringToNullString(pr.GeographicID),
+		Exemptions:          stringToNullString(pr.Exemptions),
+		OwnershipPercentage: stringToFloat64(pr.OwnershipPercentage),
+		MapscoMapID:         stringToNullString(pr.MapscoMapID),
+		LastScrapedAt:       sql.NullTime{Time: scrapedAt.UTC(), Valid: true},
+	}
+}
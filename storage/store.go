@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+// ErrNotFound is returned for a property or owner the store doesn't hold.
+var ErrNotFound = errors.New("not found")
+
+// Property is a stored property record with its child rows, as the scraper
+// last saw it.
+type Property struct {
+	tax.PropertyRecord
+	// LastScrapedAt is when the record was last saved.
+	LastScrapedAt time.Time
+	// Street and the coordinates come from geocoding the address, and are
+	// unset until it has run.
+	Street              string
+	Latitude, Longitude sql.NullFloat64
+	// Occupancy compares the owner's mailing address with the property's.
+	// It is set on saving, and empty for properties saved before it was.
+	Occupancy occupancy.Class
+}
+
+// BoundingBox is an area between two latitudes and two longitudes.
+type BoundingBox = pgdb.BoundingBox
+
+// PropertyFilter narrows List. Empty fields match everything.
+type PropertyFilter struct {
+	// Neighborhood matches the whole neighborhood name, ignoring case.
+	Neighborhood string
+	// Address matches anywhere in the address, ignoring case.
+	Address string
+	// Street matches the whole street name, ignoring case.
+	Street string
+	// Within keeps properties whose coordinates fall inside the box.
+	Within *BoundingBox
+	// ScrapedSince keeps properties saved at or after it.
+	ScrapedSince time.Time
+	// Occupancy keeps properties of one occupancy class.
+	Occupancy occupancy.Class
+	// AfterID keeps properties with a greater id, so a long listing can be
+	// paged through without offsets.
+	AfterID int32
+	// Limit caps the results; 0 means DefaultLimit.
+	Limit  int
+	Offset int
+	// Children loads each property's roll values, land, improvements and
+	// jurisdictions as well.
+	Children bool
+}
+
+// DefaultLimit is how many properties List returns when the filter sets no
+// limit.
+const DefaultLimit = 100
+
+func (f PropertyFilter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultLimit
+	}
+	return f.Limit
+}
+
+// PropertyStore keeps property records together with their roll values,
+// land, improvements and jurisdictions.
+type PropertyStore interface {
+	// Save replaces the stored copy of every record, children included, and
+	// stamps it as scraped now. If a property appears more than once the
+	// last copy wins.
+	Save(ctx context.Context, records []tax.PropertyRecord) error
+	// Property loads a property with its children, or returns ErrNotFound.
+	Property(ctx context.Context, id int32) (Property, error)
+	// Properties loads the properties in ids that exist, with their
+	// children, in id order.
+	Properties(ctx context.Context, ids []int32) ([]Property, error)
+	// List returns the properties matching f in id order, without their
+	// children unless f asks for them.
+	List(ctx context.Context, f PropertyFilter) ([]Property, error)
+	// Delete removes a property and its children, or returns ErrNotFound.
+	Delete(ctx context.Context, id int32) error
+	// History returns a property's roll values, oldest year first, or
+	// ErrNotFound.
+	History(ctx context.Context, id int32) ([]tax.RollValue, error)
+}
@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jason-costello/taxcollector/occupancy"
+	"github.com/jason-costello/taxcollector/storage/migrate/migratetest"
+	"github.com/jason-costello/taxcollector/storage/pgdb"
+	"github.com/jason-costello/taxcollector/tax"
+)
+
+func testStores(t *testing.T) map[string]PropertyStore {
+	t.Helper()
+	db := migratetest.Open(t)
+	return map[string]PropertyStore{"sql": NewSQL(db), "memory": NewMemory()}
+}
+
+// testRecord fills every field the SQL store keeps, with numbers where it
+// stores numbers, so a record reads back unchanged from either store.
+func testRecord(id, neighborhood, address string) tax.PropertyRecord {
+	return tax.PropertyRecord{
+		PropertyID:          id,
+		OwnerID:             "77",
+		OwnerName:           "DOE, JOHN",
+		OwnerMailingAddress: "PO BOX 1",
+		Zoning:              "R1",
+		NeighborhoodCD:      "N1",
+		Neighborhood:        neighborhood,
+		Address:             address,
+		LegalDescription:    "LOT 1",
+		GeographicID:        "G1",
+		Exemptions:          "HS",
+		OwnershipPercentage: "100",
+		MapscoMapID:         "M1",
+		RollValue: []tax.RollValue{
+			{Year: "2019", Improvements: "1", LandMarket: "2", AgValuation: "3", Appraised: "190000", HomesteadCap: "4", Assessed: "5"},
+			{Year: "2020", Improvements: "1", LandMarket: "2", AgValuation: "3", Appraised: "200000", HomesteadCap: "4", Assessed: "5"},
+		},
+		Land: []tax.Land{{Number: "1", Type: "A1", Description: "LOT", Acres: "0.25", Sqft: "10890", EffFront: "60", EffDepth: "180", MarketValue: "50000"}},
+		// The SQL store doesn't keep a jurisdiction's entity or description.
+		Jurisdictions: []tax.TaxingJurisdiction{{TaxRate: "2", AppraisedValue: "200000", TaxableValue: "190000", EstimatedTax: "4000"}},
+		Improvements: []tax.Improvement{{
+			Name:        "Residential",
+			Description: "HOUSE",
+			StateCode:   "A1",
+			LivingArea:  "1800",
+			Value:       "150000",
+			Details:     []tax.ImprovDetail{{Type: "MA", Description: "MAIN", Class: "R4", ExteriorWall: "BRICK", YearBuilt: "1998", SqFt: "1800"}},
+		}},
+	}
+}
+
+func Test_PropertyStore(t *testing.T) {
+	ctx := context.Background()
+	for name, ps := range testStores(t) {
+		before := time.Now().Add(-time.Second)
+		records := []tax.PropertyRecord{
+			testRecord("3", "Oak Hills", "12 OAK DR"),
+			testRecord("1", "Downtown", "1 MAIN ST"),
+			testRecord("2", "downtown", "9 MAPLE AVE"),
+		}
+		// History sorts by year whatever order the roll values came in.
+		records[1].RollValue = append(records[1].RollValue, tax.RollValue{Year: "2018"})
+		if err := ps.Save(ctx, records); err != nil {
+			t.Fatalf("%s Save: %v", name, err)
+		}
+		// Saving again replaces the children rather than adding to them.
+		records[0].OwnerName = "DOE, JANE"
+		if err := ps.Save(ctx, records[:1]); err != nil {
+			t.Fatalf("%s Save: %v", name, err)
+		}
+
+		p, err := ps.Property(ctx, 3)
+		if err != nil {
+			t.Fatalf("%s Property: %v", name, err)
+		}
+		if !reflect.DeepEqual(p.PropertyRecord, records[0]) {
+			t.Errorf("%s Property(3) = %+v, want %+v", name, p.PropertyRecord, records[0])
+		}
+		if p.LastScrapedAt.Before(before) {
+			t.Errorf("%s Property(3).LastScrapedAt = %v", name, p.LastScrapedAt)
+		}
+		if _, err := ps.Property(ctx, 4); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s Property(4) error = %v, want ErrNotFound", name, err)
+		}
+
+		tests := []struct {
+			filter PropertyFilter
+			want   []string
+		}{
+			{PropertyFilter{}, []string{"1", "2", "3"}},
+			{PropertyFilter{Neighborhood: "DOWNTOWN"}, []string{"1", "2"}},
+			{PropertyFilter{Address: "ma"}, []string{"1", "2"}},
+			{PropertyFilter{Neighborhood: "downtown", Address: "maple"}, []string{"2"}},
+			{PropertyFilter{Limit: 1, Offset: 1}, []string{"2"}},
+			{PropertyFilter{Neighborhood: "Westfield"}, nil},
+			{PropertyFilter{AfterID: 1, Limit: 1}, []string{"2"}},
+			{PropertyFilter{ScrapedSince: before}, []string{"1", "2", "3"}},
+			{PropertyFilter{ScrapedSince: time.Now().Add(time.Hour)}, nil},
+		}
+		for _, tt := range tests {
+			list, err := ps.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("%s List(%+v): %v", name, tt.filter, err)
+			}
+			var got []string
+			for _, p := range list {
+				got = append(got, p.PropertyID)
+				if p.RollValue != nil || p.Improvements != nil {
+					t.Errorf("%s List(%+v) returned children", name, tt.filter)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s List(%+v) = %v, want %v", name, tt.filter, got, tt.want)
+			}
+		}
+
+		history, err := ps.History(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s History: %v", name, err)
+		}
+		var years []string
+		for _, rv := range history {
+			years = append(years, rv.Year)
+		}
+		if !reflect.DeepEqual(years, []string{"2018", "2019", "2020"}) {
+			t.Errorf("%s History(1) years = %v", name, years)
+		}
+		if _, err := ps.History(ctx, 4); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s History(4) error = %v, want ErrNotFound", name, err)
+		}
+
+		if err := ps.Delete(ctx, 1); err != nil {
+			t.Fatalf("%s Delete: %v", name, err)
+		}
+		if _, err := ps.Property(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s Property after Delete error = %v, want ErrNotFound", name, err)
+		}
+		if err := ps.Delete(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s second Delete error = %v, want ErrNotFound", name, err)
+		}
+		if p, err := ps.Property(ctx, 2); err != nil || len(p.RollValue) != 2 {
+			t.Errorf("%s Delete(1) touched property 2: %+v, %v", name, p, err)
+		}
+	}
+}
+
+// countingDB counts the queries a Queries sends.
+type countingDB struct {
+	pgdb.DBTX
+	queries int
+}
+
+func (c *countingDB) QueryContext(ctx context.Context, q string, args ...interface{}) (*sql.Rows, error) {
+	c.queries++
+	return c.DBTX.QueryContext(ctx, q, args...)
+}
+
+func (c *countingDB) QueryRowContext(ctx context.Context, q string, args ...interface{}) *sql.Row {
+	c.queries++
+	return c.DBTX.QueryRowContext(ctx, q, args...)
+}
+
+func Test_Properties(t *testing.T) {
+	ctx := context.Background()
+	for name, ps := range testStores(t) {
+		var records []tax.PropertyRecord
+		for i := 1; i <= 20; i++ {
+			records = append(records, testRecord(strconv.Itoa(i), "Downtown", "1 MAIN ST"))
+		}
+		if err := ps.Save(ctx, records); err != nil {
+			t.Fatalf("%s Save: %v", name, err)
+		}
+
+		props, err := ps.Properties(ctx, []int32{20, 3, 99, 3, 1})
+		if err != nil {
+			t.Fatalf("%s Properties: %v", name, err)
+		}
+		var got []string
+		for _, p := range props {
+			got = append(got, p.PropertyID)
+			want := testRecord(p.PropertyID, "Downtown", "1 MAIN ST")
+			if !reflect.DeepEqual(p.PropertyRecord, want) {
+				t.Errorf("%s Properties: %+v, want %+v", name, p.PropertyRecord, want)
+			}
+		}
+		if !reflect.DeepEqual(got, []string{"1", "3", "20"}) {
+			t.Errorf("%s Properties ids = %v", name, got)
+		}
+
+		list, err := ps.List(ctx, PropertyFilter{Limit: 2, Children: true})
+		if err != nil {
+			t.Fatalf("%s List: %v", name, err)
+		}
+		if len(list) != 2 || len(list[1].Improvements) != 1 || len(list[1].Improvements[0].Details) != 1 {
+			t.Errorf("%s List with children = %+v", name, list)
+		}
+	}
+}
+
+// Test_PropertiesQueryCount checks the SQL store loads any number of
+// properties in the same handful of queries.
+func Test_PropertiesQueryCount(t *testing.T) {
+	ctx := context.Background()
+	ps := testStores(t)["sql"].(*SQL)
+	var records []tax.PropertyRecord
+	for i := 1; i <= 50; i++ {
+		records = append(records, testRecord(strconv.Itoa(i), "Downtown", "1 MAIN ST"))
+	}
+	if err := ps.Save(ctx, records); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{1, 50} {
+		db := &countingDB{DBTX: ps.db}
+		counted := &SQL{db: ps.db, pdb: pgdb.NewDialect(db, pgdb.SQLite), now: time.Now}
+		var ids []int32
+		for i := 1; i <= n; i++ {
+			ids = append(ids, int32(i))
+		}
+		props, err := counted.Properties(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(props) != n {
+			t.Fatalf("Properties(%d ids) returned %d", n, len(props))
+		}
+		if db.queries != 6 {
+			t.Errorf("Properties(%d ids) ran %d queries, want 6", n, db.queries)
+		}
+	}
+}
+
+func Test_ListGeocoded(t *testing.T) {
+	ctx := context.Background()
+	ps := testStores(t)["sql"].(*SQL)
+	if err := ps.Save(ctx, []tax.PropertyRecord{
+		testRecord("1", "Downtown", "1 MAIN ST"),
+		testRecord("2", "Downtown", "9 MAPLE AVE"),
+		testRecord("3", "Downtown", "12 OAK DR"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []struct {
+		id       int32
+		street   string
+		lat, lon float64
+	}{
+		{1, "MAIN ST", 32.1, -97.1},
+		{2, "MAPLE AVE", 32.9, -97.9},
+	} {
+		if err := ps.pdb.UpdatePropertySetAddressParts(ctx, pgdb.UpdatePropertySetAddressPartsParams{
+			ID:     g.id,
+			Street: sql.NullString{String: g.street, Valid: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ps.pdb.UpdatePropertyCoordinates(ctx, pgdb.UpdatePropertyCoordinatesParams{
+			ID:        g.id,
+			Latitude:  sql.NullFloat64{Float64: g.lat, Valid: true},
+			Longitude: sql.NullFloat64{Float64: g.lon, Valid: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter PropertyFilter
+		want   []string
+	}{
+		{PropertyFilter{Street: "main st"}, []string{"1"}},
+		{PropertyFilter{Within: &BoundingBox{MinLongitude: -97.5, MinLatitude: 32, MaxLongitude: -97, MaxLatitude: 32.5}}, []string{"1"}},
+		{PropertyFilter{Within: &BoundingBox{MinLongitude: -98, MinLatitude: 32, MaxLongitude: -97, MaxLatitude: 33}}, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		list, err := ps.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range list {
+			got = append(got, p.PropertyID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	p, err := ps.Property(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Street != "MAPLE AVE" || p.Latitude.Float64 != 32.9 || p.Longitude.Float64 != -97.9 {
+		t.Errorf("Property(2) = %q %v %v", p.Street, p.Latitude, p.Longitude)
+	}
+}
+
+func Test_OwnerStore(t *testing.T) {
+	ctx := context.Background()
+	owned := func(id, owner, neighborhood, appraised string) tax.PropertyRecord {
+		pr := testRecord(id, neighborhood, id+" MAIN ST")
+		pr.OwnerID = owner
+		pr.RollValue[1].Appraised = appraised
+		return pr
+	}
+	for name, ps := range testStores(t) {
+		owners := ps.(OwnerStore)
+		if err := ps.Save(ctx, []tax.PropertyRecord{
+			owned("1", "77", "Downtown", "100"),
+			owned("2", "77", "Downtown", "200"),
+			owned("3", "88", "downtown", "900"),
+			owned("4", "77", "Oak Hills", "400"),
+			owned("5", "", "Downtown", "500"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		// A later scrape renames the owner.
+		renamed := owned("2", "77", "Downtown", "200")
+		renamed.OwnerName = "  DOE,   JOHN\n J "
+		if err := ps.Save(ctx, []tax.PropertyRecord{renamed}); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := owners.Portfolio(ctx, 77)
+		if err != nil {
+			t.Fatalf("%s Portfolio(77): %v", name, err)
+		}
+		var ids []int32
+		for _, h := range p.Holdings {
+			ids = append(ids, h.PropertyID)
+		}
+		if p.Name != "DOE, JOHN J" || p.MailingAddress != "PO BOX 1" || p.Properties != 3 || p.TotalAppraised != 700 ||
+			!reflect.DeepEqual(ids, []int32{1, 2, 4}) {
+			t.Errorf("%s Portfolio(77) = %+v", name, p)
+		}
+		if h := p.Holdings[2]; h.Address != "4 MAIN ST" || h.Neighborhood != "Oak Hills" || h.Appraised != 400 || h.Latitude != nil {
+			t.Errorf("%s Portfolio(77) holding = %+v", name, h)
+		}
+		for _, id := range []int32{0, 99} {
+			if _, err := owners.Portfolio(ctx, id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s Portfolio(%d) error = %v, want ErrNotFound", name, id, err)
+			}
+		}
+
+		top, err := owners.TopOwners(ctx, "DOWNTOWN", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []OwnerHoldings{
+			{Owner: Owner{ID: 77, Name: "DOE, JOHN J", MailingAddress: "PO BOX 1"}, Properties: 2, TotalAppraised: 300},
+			{Owner: Owner{ID: 88, Name: "DOE, JOHN", MailingAddress: "PO BOX 1"}, Properties: 1, TotalAppraised: 900},
+		}
+		if !reflect.DeepEqual(top, want) {
+			t.Errorf("%s TopOwners(DOWNTOWN) = %+v, want %+v", name, top, want)
+		}
+		if top, err := owners.TopOwners(ctx, "Downtown", 1); err != nil || len(top) != 1 || top[0].ID != 77 {
+			t.Errorf("%s TopOwners(Downtown, 1) = %+v, %v", name, top, err)
+		}
+
+		// A roll value without a year is never the latest one, which Postgres
+		// would otherwise sort first.
+		yearless := owned("6", "99", "Elm Creek", "300")
+		yearless.RollValue = append(yearless.RollValue, tax.RollValue{Appraised: "999"})
+		if err := ps.Save(ctx, []tax.PropertyRecord{yearless}); err != nil {
+			t.Fatal(err)
+		}
+		if p, err := owners.Portfolio(ctx, 99); err != nil || p.TotalAppraised != 300 {
+			t.Errorf("%s Portfolio(99) = %+v, %v, want the 2020 appraisal", name, p, err)
+		}
+		if top, err := owners.TopOwners(ctx, "Elm Creek", 0); err != nil || len(top) != 1 || top[0].TotalAppraised != 300 {
+			t.Errorf("%s TopOwners(Elm Creek) = %+v, %v, want the 2020 appraisal", name, top, err)
+		}
+	}
+}
+
+func Test_Occupancy(t *testing.T) {
+	ctx := context.Background()
+	addressed := func(id, situs, mailing string) tax.PropertyRecord {
+		pr := testRecord(id, "Downtown", situs)
+		pr.OwnerMailingAddress = mailing
+		return pr
+	}
+	for name, ps := range testStores(t) {
+		if err := ps.Save(ctx, []tax.PropertyRecord{
+			addressed("1", "1 MAIN ST\nNEW BRAUNFELS, TX 78130", "1 Main Street\nNew Braunfels, TX 78130-1234"),
+			addressed("2", "2 MAIN ST\nNEW BRAUNFELS, TX 78130", "9 OAK DR\nNEW BRAUNFELS, TX 78130"),
+			// 78132 isn't known to be in the county yet.
+			addressed("3", "3 MAIN ST\nNEW BRAUNFELS, TX 78130", "PO BOX 5\nNEW BRAUNFELS, TX 78132"),
+			addressed("4", "4 MAIN ST\nNEW BRAUNFELS, TX 78130", "PO BOX 6\nTULSA, OK 74101"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		want := map[occupancy.Class][]string{
+			occupancy.OwnerOccupied: {"1"},
+			occupancy.LocalAbsentee: {"2"},
+			occupancy.OutOfCounty:   {"3"},
+			occupancy.OutOfState:    {"4"},
+		}
+		check := func(when string) {
+			for class, ids := range want {
+				list, err := ps.List(ctx, PropertyFilter{Occupancy: class})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, p := range list {
+					got = append(got, p.PropertyID)
+					if p.Occupancy != class {
+						t.Errorf("%s %s: property %s listed as %s has occupancy %s", name, when, p.PropertyID, class, p.Occupancy)
+					}
+				}
+				if !reflect.DeepEqual(got, ids) {
+					t.Errorf("%s %s: List(%s) = %v, want %v", name, when, class, got, ids)
+				}
+			}
+		}
+		check("first save")
+
+		if err := ps.Save(ctx, []tax.PropertyRecord{
+			addressed("5", "5 ELM ST\nNEW BRAUNFELS, TX 78132", "5 ELM ST\nNEW BRAUNFELS, TX 78132"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		counts, err := ps.(interface {
+			Reclassify(context.Context) (map[occupancy.Class]int, error)
+		}).Reclassify(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantCounts := map[occupancy.Class]int{occupancy.OwnerOccupied: 2, occupancy.LocalAbsentee: 2, occupancy.OutOfState: 1}
+		if !reflect.DeepEqual(counts, wantCounts) {
+			t.Errorf("%s Reclassify() = %v, want %v", name, counts, wantCounts)
+		}
+		want = map[occupancy.Class][]string{
+			occupancy.OwnerOccupied: {"1", "5"},
+			occupancy.LocalAbsentee: {"2", "3"},
+			occupancy.OutOfCounty:   nil,
+			occupancy.OutOfState:    {"4"},
+		}
+		check("reclassified")
+	}
+}
@@ -2,44 +2,133 @@ package useragents
 
 import (
 	"bufio"
+	_ "embed"
 	"errors"
+	"fmt"
+	"io"
 	"math/rand"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
-type UserAgentClient struct {
-	agentList []string
+// ErrEmpty is returned for a user agent list with nothing in it.
+var ErrEmpty = errors.New("user agent list is empty")
+
+// UserAgentProvider hands out the User-Agent for each scrape job. It is safe
+// for concurrent use.
+type UserAgentProvider interface {
+	UserAgent() string
 }
 
-func (u *UserAgentClient) LoadUserAgents(fp string) error {
-	var err error
-	if fp == "" {
-		return errors.New("no filename provided")
-	}
-	file, err := os.Open(fp)
-	if err != nil {
-		return err
+// New returns the provider the config asks for: always fixed when it is set,
+// otherwise a random agent from file, or from the built-in list when file is
+// empty too.
+func New(fixed, file string) (UserAgentProvider, error) {
+	switch {
+	case strings.TrimSpace(fixed) != "":
+		return Fixed(strings.TrimSpace(fixed)), nil
+	case file != "":
+		return LoadFile(file)
 	}
-	defer file.Close()
+	return Default(), nil
+}
+
+// Fixed sends the same identity on every request.
+type Fixed string
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		u.agentList = append(u.agentList, scanner.Text())
+func (f Fixed) UserAgent() string {
+	return string(f)
+}
+
+// List picks a random agent from a fixed set.
+type List struct {
+	mu     sync.Mutex
+	rnd    *rand.Rand
+	agents []string
+}
+
+// NewList returns a List of agents with duplicates dropped.
+func NewList(agents []string) (*List, error) {
+	seen := make(map[string]bool, len(agents))
+	var uniq []string
+	for _, a := range agents {
+		if !seen[a] {
+			seen[a] = true
+			uniq = append(uniq, a)
+		}
 	}
-	return scanner.Err()
+	if len(uniq) == 0 {
+		return nil, ErrEmpty
+	}
+	return &List{
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		agents: uniq,
+	}, nil
+}
 
+func (l *List) UserAgent() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.agents[l.rnd.Intn(len(l.agents))]
 }
 
-func (u *UserAgentClient) GetRandomUserAgent() (string, error) {
+// Len is the number of distinct agents in l.
+func (l *List) Len() int {
+	return len(l.agents)
+}
 
-	if u.agentList == nil {
-		return "", errors.New("agent list is nil")
+// Parse reads one agent per line, skipping blank lines and # comments.
+func Parse(r io.Reader) ([]string, error) {
+	var agents []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		agents = append(agents, line)
 	}
-	rand.Seed(time.Now().UnixNano())
+	return agents, sc.Err()
+}
 
-	i := rand.Intn(len(u.agentList)-0) + 0
+// LoadFile reads a List from a file in the format Parse reads.
+func LoadFile(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	agents, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	l, err := NewList(agents)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
 
-	return u.agentList[i], nil
+//go:embed useragents.txt
+var defaultAgents string
+
+var (
+	defaultOnce sync.Once
+	defaultList *List
+)
 
+// Default returns the built-in list of common browser agents.
+func Default() *List {
+	defaultOnce.Do(func() {
+		agents, err := Parse(strings.NewReader(defaultAgents))
+		if err == nil {
+			defaultList, err = NewList(agents)
+		}
+		if err != nil {
+			panic("useragents: built-in list: " + err.Error())
+		}
+	})
+	return defaultList
 }
@@ -0,0 +1,97 @@
+package useragents
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_LoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr error
+	}{
+		{"plain", "agent/1\nagent/2\n", []string{"agent/1", "agent/2"}, nil},
+		{"comments and blanks", "# browsers\n\nagent/1\n  # indented comment\n  agent/2  \n\n", []string{"agent/1", "agent/2"}, nil},
+		{"duplicates", "agent/1\nagent/2\nagent/1\n agent/2\n", []string{"agent/1", "agent/2"}, nil},
+		{"empty", "", nil, ErrEmpty},
+		{"only comments", "# nothing here\n\n", nil, ErrEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agents.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			l, err := LoadFile(path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("LoadFile() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(l.agents, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("agents = %q, want %q", l.agents, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ListConcurrent(t *testing.T) {
+	l, err := NewList([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	wg := &sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ua := l.UserAgent()
+				mu.Lock()
+				seen[ua] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 3 {
+		t.Errorf("agents handed out = %v, want all three", seen)
+	}
+}
+
+func Test_New(t *testing.T) {
+	p, err := New("  taxcollector/1.0  ", "does-not-exist.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ua := p.UserAgent(); ua != "taxcollector/1.0" {
+		t.Errorf("fixed UserAgent() = %q", ua)
+	}
+
+	if _, err := New("", "does-not-exist.txt"); err == nil {
+		t.Error("New() with a missing file succeeded")
+	}
+
+	p, err = New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := p.(*List); !ok || l.Len() < 100 {
+		t.Fatalf("default provider = %T, want the built-in list", p)
+	}
+	if p.UserAgent() == "" {
+		t.Error("default UserAgent() is empty")
+	}
+}